@@ -3,8 +3,11 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"time"
 
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
 	"github.com/ViniTamanhao/arcadio/pkg/models"
 	"github.com/spf13/cobra"
 )
@@ -12,12 +15,19 @@ import (
 var (
 	addTags []string
 	addRecursive bool
+	addFolder string
+	addName string
+	addExpires string
 )
 
 var addCmd = &cobra.Command{
 	Use: "add <arc-name-or-id> <file-or-directory>",
 	Short: "Add documents to an arc",
-	Long: `Add one or more documents to an encrypted arc. Documents will be encrypted before storage.`,
+	Long: `Add one or more documents to an encrypted arc. Documents will be encrypted before storage.
+
+Use - as the file to read the document from stdin, naming it with --name:
+
+  pg_dump mydb | arc add backups - --name db.sql`,
 	Args: cobra.ExactArgs(2),
 	RunE: runAdd,
 }
@@ -26,12 +36,24 @@ func init() {
 	rootCmd.AddCommand(addCmd)
 	addCmd.Flags().StringSliceVarP(&addTags, "tags", "t", []string{}, "Tags to add to the document(s)")
 	addCmd.Flags().BoolVarP(&addRecursive, "recursive", "r", false, "Add directory recursively")
+	addCmd.Flags().StringVar(&addFolder, "to", "", "Virtual folder inside the arc to add into")
+	addCmd.Flags().StringVar(&addName, "name", "", "Document name when reading from stdin")
+	addCmd.Flags().StringVar(&addExpires, "expires", "", "Expire the document(s) on a date (2026-12-31) or after a period (30d, 6mo, 7y)")
 }
 
 func runAdd(cmd *cobra.Command, args []string) error {
 	arcNameOrID := args[0]
 	path := args [1]
 
+	if path == "-" && addName == "" {
+		return fmt.Errorf("--name is required when reading from stdin")
+	}
+
+	expires, err := arcpkg.ParseExpiry(addExpires, time.Now())
+	if err != nil {
+		return err
+	}
+
 	entry, err := arcManager.FindArc(arcNameOrID)
 	if err != nil {
 		return err
@@ -46,36 +68,94 @@ func runAdd(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if expires != nil && arcpkg.Immutable(arc, time.Now()) {
+		return fmt.Errorf("cannot set --expires: %w until %s", arcpkg.ErrImmutable, arc.Policy.ImmutableUntil.Format("2006-01-02"))
+	}
+
+	var added []*models.Document
+	err = arcManager.Batch(entry.ID, arc, key, func() error {
+		added, err = addPath(entry.ID, arc, key, path, expires)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if machineOutput() {
+		return printOutput(documentViews(arc, added))
+	}
+	return nil
+}
+
+// addPath adds a file, a directory or stdin, setting the expiry of every
+// added document, and returns the added documents
+func addPath(arcID string, arc *models.Arc, key []byte, path string, expires *time.Time) ([]*models.Document, error) {
+	if path == "-" {
+		return addFromStdin(arcID, arc, key, expires)
+	}
 
 	fileInfo, err := os.Stat(path)
 	if err != nil {
-		return fmt.Errorf("failed to access path: %w", err)
+		return nil, fmt.Errorf("failed to access path: %w", err)
 	}
 
 	if fileInfo.IsDir() {
 		if !addRecursive {
-			return fmt.Errorf("path is a directory, use --recursive flag to add all files")
+			return nil, fmt.Errorf("path is a directory, use --recursive flag to add all files")
 		}
-		return addDirectory(arcNameOrID, arc, key, path)
+		return addDirectory(arcID, arc, key, path, expires)
 	}
 
-	doc, err := arcManager.AddDocument(entry.ID, arc, key, path, addTags)
+	doc, err := arcManager.AddDocument(arcID, arc, key, path, addFolder, addTags)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := setAddExpiry(arcID, arc, key, doc, expires); err != nil {
+		return nil, err
+	}
+	if machineOutput() {
+		return []*models.Document{doc}, nil
 	}
 
-	fmt.Printf("\nDocument added: %s\n", doc.Filename)
+	fmt.Printf("\nDocument added: %s\n", doc.Path())
 	fmt.Printf("	ID: %s\n", doc.ID)
 	fmt.Printf("	Size: %d bytes\n", doc.Size)
 	if len(addTags) > 0 {
 		fmt.Printf("	Tags: %v\n", addTags)
 	}
+	if t, reason, ok := arcpkg.ExpiryOf(arc, doc); ok {
+		fmt.Printf("	Expires: %s (%s)\n", t.Format("2006-01-02"), reason)
+	}
 
-	return nil
+	return []*models.Document{doc}, nil
+}
+
+// setAddExpiry applies --expires to a newly added document
+func setAddExpiry(arcID string, arc *models.Arc, key []byte, doc *models.Document, expires *time.Time) error {
+	if expires == nil {
+		return nil
+	}
+	return arcManager.SetExpiry(arcID, arc, key, doc.ID, expires)
 }
 
-func addDirectory(arcID string, arc *models.Arc, key []byte, dirPath string) error {
-	count := 0
+// addFromStdin stores stdin as a document named --name
+func addFromStdin(arcID string, arc *models.Arc, key []byte, expires *time.Time) ([]*models.Document, error) {
+	doc, err := arcManager.AddDocumentFromReader(arcID, arc, key, path.Join(addFolder, addName), os.Stdin, addTags)
+	if err != nil {
+		return nil, err
+	}
+	if err := setAddExpiry(arcID, arc, key, doc, expires); err != nil {
+		return nil, err
+	}
+
+	if !machineOutput() {
+		fmt.Printf("Document added: %s (%s)\n", doc.Path(), formatSize(doc.Size))
+	}
+	return []*models.Document{doc}, nil
+}
+
+func addDirectory(arcID string, arc *models.Arc, key []byte, dirPath string, expires *time.Time) ([]*models.Document, error) {
+	var added []*models.Document
 	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -85,20 +165,32 @@ func addDirectory(arcID string, arc *models.Arc, key []byte, dirPath string) err
 			return nil
 		}
 
-		fmt.Printf("\nAdding: %s\n", path)
-		_, err = arcManager.AddDocument(arcID, arc, key, path, addTags)
+		// Keep the layout below the added directory as virtual folders
+		rel, err := filepath.Rel(dirPath, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		folder := filepath.ToSlash(filepath.Join(addFolder, rel))
+
+		logf("\nAdding: %s\n", path)
+		doc, err := arcManager.AddDocument(arcID, arc, key, path, folder, addTags)
 		if err != nil {
-			fmt.Printf("Failed: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Failed: %s: %v\n", path, err)
 			return nil
 		}
-		count++
+		if err := setAddExpiry(arcID, arc, key, doc, expires); err != nil {
+			return err
+		}
+		added = append(added, doc)
 		return nil
 	})
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	fmt.Printf("\nAdded %d documents\n", count)
-	return nil
+	if !machineOutput() {
+		fmt.Printf("\nAdded %d documents\n", len(added))
+	}
+	return added, nil
 }
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var catCmd = &cobra.Command{
+	Use:   "cat <arc-name-or-id> <doc>",
+	Short: "Write a document to stdout",
+	Long: `Decrypt a document and write its content to stdout, so it can be piped
+into other commands:
+
+  arc cat secrets env | source /dev/stdin`,
+	Args: cobra.ExactArgs(2),
+	RunE: runCat,
+}
+
+func init() {
+	rootCmd.AddCommand(catCmd)
+}
+
+func runCat(cmd *cobra.Command, args []string) error {
+	entry, arc, key, doc, err := unlockDocument(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	data, err := arcManager.GetDocument(entry.ID, arc, key, doc.ID)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(data)
+	if hex.EncodeToString(hash[:]) != doc.ContentHash {
+		return fmt.Errorf("document integrity check failed - file may be corrupted")
+	}
+
+	if _, err := os.Stdout.Write(data); err != nil {
+		return fmt.Errorf("failed to write document: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var collectionCmd = &cobra.Command{
+	Use:   "collection",
+	Short: "Manage saved searches",
+	Long: `A collection is a named query stored in the arc's encrypted metadata. It is
+evaluated whenever it is used, so it always lists the current documents:
+
+  arc collection create finance "unpaid invoices" 'tag:invoice prop.paid=false'
+  arc docs finance --collection "unpaid invoices"
+  arc export finance --collection "unpaid invoices" ./unpaid
+
+Collections are accepted by arc docs, arc export and arc transfer.`,
+}
+
+var collectionCreateCmd = &cobra.Command{
+	Use:   "create <arc-name-or-id> <name> <query...>",
+	Short: "Save a query as a collection",
+	Args:  cobra.MinimumNArgs(3),
+	RunE:  runCollectionCreate,
+}
+
+var collectionListCmd = &cobra.Command{
+	Use:   "list <arc-name-or-id>",
+	Short: "List collections with their current document counts",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runCollectionList,
+}
+
+var collectionDeleteCmd = &cobra.Command{
+	Use:   "delete <arc-name-or-id> <name>",
+	Short: "Delete a collection, keeping its documents",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runCollectionDelete,
+}
+
+func init() {
+	rootCmd.AddCommand(collectionCmd)
+	collectionCmd.AddCommand(collectionCreateCmd)
+	collectionCmd.AddCommand(collectionListCmd)
+	collectionCmd.AddCommand(collectionDeleteCmd)
+}
+
+func runCollectionCreate(cmd *cobra.Command, args []string) error {
+	query := strings.Join(args[2:], " ")
+	if _, err := arcpkg.ParseQuery(query); err != nil {
+		return err
+	}
+
+	h, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+
+	c, err := arcManager.CreateCollection(h.ID, h.Arc, h.Key, args[1], query)
+	if err != nil {
+		return err
+	}
+
+	docs, err := collectionDocuments(h.Arc, c.Name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Collection created: %s (%d document(s) match now)\n", c.Name, len(docs))
+	return nil
+}
+
+func runCollectionList(cmd *cobra.Command, args []string) error {
+	h, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(h.Arc.Collections) == 0 {
+		fmt.Println("No collections in this arc.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDOCUMENTS\tQUERY")
+	fmt.Fprintln(w, "----\t---------\t-----")
+	for _, c := range h.Arc.Collections {
+		count := "?"
+		if docs, err := collectionDocuments(h.Arc, c.Name); err == nil {
+			count = fmt.Sprint(len(docs))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Name, count, c.Query)
+	}
+	w.Flush()
+	return nil
+}
+
+func runCollectionDelete(cmd *cobra.Command, args []string) error {
+	h, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := arcManager.DeleteCollection(h.ID, h.Arc, h.Key, args[1]); err != nil {
+		return err
+	}
+
+	fmt.Printf("Collection deleted: %s\n", args[1])
+	return nil
+}
+
+// collectionQueries returns the queries of the named collections, skipping
+// empty names
+func collectionQueries(arc *models.Arc, names ...string) ([]*arcpkg.Query, error) {
+	var queries []*arcpkg.Query
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		q, err := arcpkg.CollectionQuery(arc, name)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+	return queries, nil
+}
+
+// collectionDocuments evaluates a collection against the current documents
+func collectionDocuments(arc *models.Arc, name string) ([]*models.Document, error) {
+	q, err := arcpkg.CollectionQuery(arc, name)
+	if err != nil {
+		return nil, err
+	}
+	return q.Filter(arc, arcManager.ListDocuments(arc)), nil
+}
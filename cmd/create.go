@@ -2,10 +2,17 @@ package cmd
 
 import (
 	"fmt"
-	"syscall"
+	"os"
+	"strings"
 
+	"github.com/ViniTamanhao/arcadio/pkg/models"
 	"github.com/spf13/cobra"
-	"golang.org/x/term"
+)
+
+var (
+	createPacked     bool
+	securityQuestion string
+	answerFile       string
 )
 
 var createCmd = &cobra.Command{
@@ -19,64 +26,121 @@ var createCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(createCmd)
+	createCmd.Flags().BoolVar(&createPacked, "packed", false, "Store small documents in shared pack files")
+	addSecurityFlags(createCmd)
+}
+
+// addSecurityFlags adds the flags that give a new arc's security question
+// and answer without prompting
+func addSecurityFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&securityQuestion, "security-question", "", "Security question for the new arc")
+	cmd.Flags().StringVar(&answerFile, "answer-file", "", "Read the answer to the security question from this file")
 }
 
 func runCreate(cmd *cobra.Command, args []string) error {
 	name := args[0]
 
+	arc, password, err := createArc(name)
+	if err != nil {
+		return err
+	}
+
+	if createPacked {
+		arc, key, err := arcManager.Unlock(arc.ID, password)
+		if err != nil {
+			return err
+		}
+		if err := arcManager.EnablePacking(arc.ID, arc, key); err != nil {
+			return fmt.Errorf("failed to enable packed layout: %w", err)
+		}
+	}
+
+	fmt.Printf("\nArc created successfully!\n")
+	fmt.Printf("	ID: %s\n", arc.ID)
+	fmt.Printf("	Name: %s\n", arc.Name)
+	fmt.Printf("	Created: %s\n", arc.CreatedAt.Format("2006-01-02 15:04:05"))
+
+	return nil
+}
+
+// createArc prompts for a password and security question and creates an
+// arc, returning it with its password. Values given with flags are not
+// asked for.
+func createArc(name string) (*models.Arc, string, error) {
+	// Fail before any prompt when a script could not answer one
+	if noPrompt && securityQuestion == "" {
+		return nil, "", fmt.Errorf("creating an arc needs a security question: use --security-question with --no-prompt")
+	}
+	if noPrompt && answerFile == "" {
+		return nil, "", fmt.Errorf("creating an arc needs a security answer: use --answer-file with --no-prompt")
+	}
+
 	fmt.Printf("Creating arc: %s\n\n", name)
 
-	fmt.Print("Enter password: ")
-	passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
+	password, supplied, err := authManager.SuppliedPassword()
 	if err != nil {
-		return fmt.Errorf("failed to read password: %w", err)
+		return nil, "", err
+	}
+	if !supplied {
+		if password, err = promptNewPassword(); err != nil {
+			return nil, "", err
+		}
+	} else if len(password) < 8 {
+		return nil, "", fmt.Errorf("password must be at least 9 characters")
 	}
-	fmt.Println()
 
-	password := string(passwordBytes)
-	if len(password) < 8 {
-		return fmt.Errorf("password must be at least 9 characters")
+	question := strings.TrimSpace(securityQuestion)
+	if securityQuestion == "" {
+		if question, err = authManager.Prompt("Security question: "); err != nil {
+			return nil, "", fmt.Errorf("failed to read security question: %w", err)
+		}
 	}
 
-	fmt.Print("Confirm password: ")
-	confirmBytes, err := term.ReadPassword(int(syscall.Stdin))
-	if err != nil {
-		return fmt.Errorf("failed to read password: %w", err)
+	if question == "" {
+		return nil, "", fmt.Errorf("security question cannot be empty")
 	}
-	fmt.Println()
 
-	if password != string(confirmBytes) {
-		return fmt.Errorf("passwords do not match")
+	var answer string
+	if answerFile != "" {
+		data, err := os.ReadFile(answerFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read answer file: %w", err)
+		}
+		answer = strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+	} else if answer, err = authManager.PromptSecret("Answer: "); err != nil {
+		return nil, "", fmt.Errorf("failed to read answer: %w", err)
 	}
 
-	var securityQuestion string
-	fmt.Print("Security question: ")
-	fmt.Scanln(&securityQuestion)
+	if answer == "" {
+		return nil, "", fmt.Errorf("security answer cannot be empty")
+	}
 
-	if securityQuestion == "" {
-		return fmt.Errorf("security question cannot be empty")
+	arc, err := arcManager.Create(name, password, question, answer)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create arc: %w", err)
 	}
-	fmt.Print("Answer: ")
-	answerBytes, err := term.ReadPassword(int(syscall.Stdin))
+	return arc, password, nil
+}
+
+// promptNewPassword reads a new arc password and its confirmation
+func promptNewPassword() (string, error) {
+	password, err := authManager.PromptSecret("Enter password: ")
 	if err != nil {
-		return fmt.Errorf("failed to read answer: %w", err)
+		return "", fmt.Errorf("failed to read password: %w", err)
 	}
-	fmt.Println()
 
-	answer := string(answerBytes)
-	if answer == "" {
-		return fmt.Errorf("security answer cannot be empty")
+	if len(password) < 8 {
+		return "", fmt.Errorf("password must be at least 9 characters")
 	}
 
-	arc, err := arcManager.Create(name, password, securityQuestion, answer)
+	confirm, err := authManager.PromptSecret("Confirm password: ")
 	if err != nil {
-		return fmt.Errorf("failed to create arc: %w", err)
+		return "", fmt.Errorf("failed to read password: %w", err)
 	}
 
-	fmt.Printf("\nArc created successfully!\n")
-	fmt.Printf("	ID: %s\n", arc.ID)
-	fmt.Printf("	Name: %s\n", arc.Name)
-	fmt.Printf("	Created: %s\n", arc.CreatedAt.Format("2006-01-02 15:04:05"))
+	if password != confirm {
+		return "", fmt.Errorf("passwords do not match")
+	}
 
-	return nil
+	return password, nil
 }
@@ -40,12 +40,15 @@ func runDelete(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	_, _, err = arcManager.Unlock(entry.ID, password)
+	arc, _, err := arcManager.Unlock(entry.ID, password)
 	if err != nil {
 		return err
 	}
 
 	if !forceDelete {
+		if noPrompt {
+			return fmt.Errorf("deleting an arc needs confirmation: use --force with --no-prompt")
+		}
 		fmt.Printf("WARNING: This will permantently delete arc: '%s' and all its documents!\n", entry.Name)
 		fmt.Print("Type arc name to confirm: ")
 
@@ -59,7 +62,7 @@ func runDelete(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	if err := arcManager.Delete(entry.ID); err != nil {
+	if err := arcManager.Delete(entry.ID, arc); err != nil {
 		return fmt.Errorf("failed to delete arc: %w", err)
 	}
 
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/spf13/cobra"
+)
+
+var dupesAll bool
+
+var dupesCmd = &cobra.Command{
+	Use:   "dupes [arc-name-or-id...]",
+	Short: "List documents with identical content",
+	Long: `Group documents whose content is identical, comparing their stored
+SHA-256 hashes. Name several arcs, or use --all, to find copies across arcs.
+
+  arc dupes work-docs
+  arc dupes work-docs archive-2023
+  arc dupes --all`,
+	RunE: runDupes,
+}
+
+func init() {
+	rootCmd.AddCommand(dupesCmd)
+	dupesCmd.Flags().BoolVar(&dupesAll, "all", false, "Compare every registered arc")
+}
+
+func runDupes(cmd *cobra.Command, args []string) error {
+	refs := args
+	if dupesAll {
+		if len(args) > 0 {
+			return fmt.Errorf("name arcs or use --all, not both")
+		}
+		for _, entry := range arcManager.ListArcs() {
+			refs = append(refs, entry.ID)
+		}
+	}
+	if len(refs) == 0 {
+		return fmt.Errorf("name at least one arc, or use --all")
+	}
+
+	var handles []*arcpkg.ArcHandle
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		h, err := unlockHandle(ref)
+		if err != nil {
+			return err
+		}
+		if !seen[h.ID] {
+			seen[h.ID] = true
+			handles = append(handles, h)
+		}
+	}
+
+	groups := arcManager.FindDuplicates(handles)
+	if len(groups) == 0 {
+		fmt.Println("\nNo duplicate documents found.")
+		return nil
+	}
+
+	var reclaimable int64
+	for _, g := range groups {
+		reclaimable += g.Reclaimable()
+		fmt.Printf("\n%d copies of %s  (%s)\n", len(g.Copies), formatSize(g.Size), g.Hash[:12])
+		for _, c := range g.Copies {
+			if len(handles) > 1 {
+				fmt.Printf("	%s:/%s\n", c.Arc.Arc.Name, c.Doc.Path())
+			} else {
+				fmt.Printf("	/%s\n", c.Doc.Path())
+			}
+		}
+	}
+
+	fmt.Printf("\n%d group(s) of duplicates, %s reclaimable\n", len(groups), formatSize(reclaimable))
+	return nil
+}
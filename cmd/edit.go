@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/ViniTamanhao/arcadio/internal/securetmp"
+	"github.com/spf13/cobra"
+)
+
+var editCmd = &cobra.Command{
+	Use:   "edit <arc-name-or-id> <doc>",
+	Short: "Edit a document in place",
+	Long: `Decrypt a document into a private memory-backed directory, open it in
+$VISUAL or $EDITOR and store the result under the same document ID.
+
+The plaintext copy is overwritten and removed when the editor exits, when arc
+is interrupted or terminated, and on the next run after a crash.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runEdit,
+}
+
+func init() {
+	rootCmd.AddCommand(editCmd)
+}
+
+func runEdit(cmd *cobra.Command, args []string) error {
+	arcNameOrID := args[0]
+	docRef := args[1]
+
+	securetmp.CleanStale()
+
+	entry, arc, key, doc, err := unlockDocument(arcNameOrID, docRef)
+	if err != nil {
+		return err
+	}
+
+	if err := arcpkg.CheckChange(arc, doc); err != nil {
+		return err
+	}
+
+	data, err := arcManager.GetDocument(entry.ID, arc, key, doc.ID)
+	if err != nil {
+		return err
+	}
+	before := sha256.Sum256(data)
+	if fmt.Sprintf("%x", before) != doc.ContentHash {
+		return fmt.Errorf("document integrity check failed - file may be corrupted")
+	}
+
+	if _, inMemory := securetmp.Base(); !inMemory {
+		fmt.Fprintln(os.Stderr, "Warning: no memory-backed directory found, the plaintext copy is written to disk")
+	}
+
+	dir, err := securetmp.New()
+	if err != nil {
+		return err
+	}
+	defer dir.Shred()
+
+	path, err := dir.WriteFile(doc.Filename, data)
+	if err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	if err := runEditor(path, dir); err != nil {
+		return err
+	}
+
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read edited file: %w", err)
+	}
+	if after := sha256.Sum256(edited); bytes.Equal(before[:], after[:]) {
+		fmt.Println("No changes")
+		return nil
+	}
+
+	if err := arcManager.UpdateDocumentContent(entry.ID, arc, key, doc.ID, edited); err != nil {
+		return err
+	}
+
+	fmt.Printf("Saved: /%s (%s)\n", doc.Path(), formatSize(doc.Size))
+	return nil
+}
+
+// runEditor opens path in the user's editor. Ctrl-C is left to the editor;
+// if arc itself is terminated the editor is killed and the copy shredded.
+func runEditor(path string, dir *securetmp.Dir) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	parts := strings.Fields(editor)
+	editorCmd := exec.Command(parts[0], append(parts[1:], path)...)
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	if err := editorCmd.Start(); err != nil {
+		return fmt.Errorf("failed to start editor %q: %w", editor, err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- editorCmd.Wait() }()
+
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				return fmt.Errorf("editor exited with an error, changes discarded: %w", err)
+			}
+			return nil
+
+		case sig := <-sigs:
+			if sig == os.Interrupt {
+				continue
+			}
+			editorCmd.Process.Kill()
+			<-done
+			dir.Shred()
+			fmt.Fprintf(os.Stderr, "\nInterrupted, temporary copy removed\n")
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/spf13/cobra"
+)
+
+var expireDryRun bool
+
+var expireCmd = &cobra.Command{
+	Use:   "expire <arc-name-or-id>",
+	Short: "Remove expired documents",
+	Long: `List and remove documents whose expiry has passed. Every removal is
+recorded in the arc's encrypted purge log, shown by arc retention log.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runExpire,
+}
+
+func init() {
+	rootCmd.AddCommand(expireCmd)
+	expireCmd.Flags().BoolVarP(&expireDryRun, "dry-run", "n", false, "Only list the expired documents")
+}
+
+func runExpire(cmd *cobra.Command, args []string) error {
+	h, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	expired := arcManager.ExpiredDocuments(h.Arc, now)
+	if len(expired) == 0 {
+		fmt.Println("No expired documents")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tEXPIRED\tREASON")
+	fmt.Fprintln(w, "----\t-------\t------")
+	for _, doc := range expired {
+		t, reason, _ := arcpkg.ExpiryOf(h.Arc, doc)
+		fmt.Fprintf(w, "%s\t%s\t%s\n", doc.Path(), t.Format("2006-01-02 15:04"), reason)
+	}
+	w.Flush()
+
+	if expireDryRun {
+		fmt.Printf("\nDry run: %d document(s) would be purged\n", len(expired))
+		return nil
+	}
+
+	records, err := arcManager.PurgeExpired(h.ID, h.Arc, h.Key, now)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nPurged %d document(s)\n", len(records))
+	return nil
+}
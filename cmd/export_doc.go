@@ -3,24 +3,79 @@ package cmd
 import (
 	"fmt"
 
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
 	"github.com/spf13/cobra"
 )
 
+var (
+	exportRecursive  bool
+	exportOnConflict string
+	exportFixExt     bool
+	exportQuery      string
+	exportCollection string
+)
+
 var exportDocCmd = &cobra.Command{
-	Use:   "export <arc-name-or-id> <doc-id> <output-path>",
+	Use:   "export <arc-name-or-id> <doc> <output-path> | --query <query> <dest-dir> | --collection <name> <dest-dir>",
 	Short: "Export a document from an arc",
-	Args:  cobra.ExactArgs(3),
-	RunE:  runExportDoc,
+	Long: `Export a document from an arc. With --recursive, export every document under
+a folder into a directory, recreating the folder hierarchy:
+
+  arc export --recursive <arc-name-or-id> <folder> <dest-dir>
+
+With --query, export every document matching a query into a directory,
+recreating their folders:
+
+  arc export <arc-name-or-id> --query 'tag:tax added>=2024-01-01' <dest-dir>
+
+--collection does the same for the documents of a saved collection.
+
+With --fix-ext, files without an extension get the one matching their
+detected content type (e.g. "scan" is written as "scan.pdf").`,
+	Args: cobra.RangeArgs(2, 3),
+	RunE: runExportDoc,
 }
 
 func init() {
 	rootCmd.AddCommand(exportDocCmd)
+	exportDocCmd.Flags().BoolVarP(&exportRecursive, "recursive", "r", false, "Export a whole folder")
+	exportDocCmd.Flags().StringVar(&exportOnConflict, "on-conflict", "fail", "When a file exists: fail, skip, overwrite or rename")
+	exportDocCmd.Flags().StringVar(&exportQuery, "query", "", "Export the documents matching this query into a directory")
+	exportDocCmd.Flags().StringVar(&exportCollection, "collection", "", "Export the documents of this saved collection into a directory")
+	exportDocCmd.Flags().BoolVar(&exportFixExt, "fix-ext", false, "Add a missing file extension based on the detected type")
 }
 
 func runExportDoc(cmd *cobra.Command, args []string) error {
+	selecting := exportQuery != "" || exportCollection != ""
+	if selecting {
+		if len(args) != 2 {
+			return fmt.Errorf("with --query or --collection, give the arc and a destination directory")
+		}
+		if exportRecursive {
+			return fmt.Errorf("--query and --collection cannot be combined with --recursive")
+		}
+	} else if len(args) != 3 {
+		return fmt.Errorf("give the arc, the document and the output path")
+	}
+
 	arcNameOrID := args[0]
-	docID := args[1]
-	outputPath := args[2]
+	docRef, outputPath := "", args[len(args)-1]
+	if len(args) == 3 {
+		docRef = args[1]
+	}
+
+	queries, err := parseQueries(exportQuery)
+	if err != nil {
+		return err
+	}
+
+	policy := arcpkg.ConflictPolicy(exportOnConflict)
+	switch policy {
+	case arcpkg.ConflictFail, arcpkg.ConflictSkip, arcpkg.ConflictOverwrite, arcpkg.ConflictRename:
+	default:
+		return fmt.Errorf("invalid --on-conflict value: %s", exportOnConflict)
+	}
 
 	entry, err := arcManager.FindArc(arcNameOrID)
 	if err != nil {
@@ -29,7 +84,7 @@ func runExportDoc(cmd *cobra.Command, args []string) error {
 
 	fmt.Printf("Exporting arc: %s\n", entry.Name)
 
-	password, err := authManager.GetPassword(entry.Name, entry.ID, true)
+	password, err := authManager.GetPassword(entry.ID, entry.Name, true)
 	if err != nil {
 		return err
 	}
@@ -39,7 +94,55 @@ func runExportDoc(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if err := arcManager.ExportDocument(entry.ID, arc, key, docID, outputPath); err != nil {
+	if exportRecursive || selecting {
+		opts := arcpkg.ExportOptions{
+			Policy:        policy,
+			FixExtensions: exportFixExt,
+		}
+
+		var stats *arcpkg.ExportStats
+		if selecting {
+			collections, err := collectionQueries(arc, exportCollection)
+			if err != nil {
+				return err
+			}
+
+			var docs []*models.Document
+			if docs, err = selectDocuments(arc, nil, nil, append(queries, collections...), nil); err != nil {
+				return err
+			}
+			if len(docs) == 0 {
+				fmt.Println("No documents match the query")
+				return nil
+			}
+			stats, err = arcManager.ExportDocuments(entry.ID, arc, key, docs, "", outputPath, opts)
+		} else {
+			stats, err = arcManager.ExportFolder(entry.ID, arc, key, docRef, outputPath, opts)
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("\nExported %d documents to %s\n", stats.Exported, outputPath)
+		if stats.Skipped > 0 {
+			fmt.Printf("	Skipped: %d\n", stats.Skipped)
+		}
+		if stats.Renamed > 0 {
+			fmt.Printf("	Renamed: %d\n", stats.Renamed)
+		}
+		return nil
+	}
+
+	doc, err := arcManager.FindDocument(arc, docRef)
+	if err != nil {
+		return err
+	}
+
+	if exportFixExt {
+		outputPath = arcpkg.WithExtension(outputPath, doc)
+	}
+
+	if err := arcManager.ExportDocument(entry.ID, arc, key, doc.ID, outputPath); err != nil {
 		return err
 	}
 
@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
 
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
 	"github.com/spf13/cobra"
 )
 
@@ -28,7 +31,7 @@ func runInfo(cmd *cobra.Command, args []string) error {
 
 
 
-	password, err := authManager.GetPassword(entry.Name, entry.ID, true)
+	password, err := authManager.GetPassword(entry.ID, entry.Name, true)
 	if err != nil {
 		return err
 	}
@@ -38,6 +41,10 @@ func runInfo(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to unlock arc: %w", err)
 	}
 
+	if machineOutput() {
+		return printOutput(newInfoView(arc))
+	}
+
 	fmt.Printf("\nArc Information:\n")
 	fmt.Printf("================\n")
 	fmt.Printf("Name:         %s\n", arc.Name)
@@ -47,6 +54,11 @@ func runInfo(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Documents:    %d\n", len(arc.Documents))
 	fmt.Printf("Tags:         %d unique\n", countUniqueTags(arc.Tags))
 	fmt.Printf("Encryption:   %s\n", arc.EncryptionVersion)
+	if arc.Packs != nil {
+		fmt.Printf("Layout:       packed (%d packs)\n", len(arc.Packs.Sizes))
+	} else {
+		fmt.Printf("Layout:       loose\n")
+	}
 
 	var totalSize int64
 	for _, doc := range arc.Documents {
@@ -54,9 +66,76 @@ func runInfo(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Printf("Total Size:   %s\n", formatSize(totalSize))
 
+	if len(arc.Documents) > 0 {
+		fmt.Printf("\nBy Type:\n")
+		for _, t := range typeBreakdown(arc) {
+			fmt.Printf("  %-40s %5d  %s\n", t.contentType, t.count, formatSize(t.size))
+		}
+	}
+
 	return nil
 }
 
+// newInfoView collects what arc info shows for machine-readable output
+func newInfoView(arc *models.Arc) infoView {
+	view := infoView{
+		ID:         arc.ID,
+		Name:       arc.Name,
+		CreatedAt:  arc.CreatedAt,
+		ModifiedAt: arc.ModifiedAt,
+		Documents:  len(arc.Documents),
+		Tags:       countUniqueTags(arc.Tags),
+		Encryption: arc.EncryptionVersion,
+		Layout:     "loose",
+		Types:      []typeView{},
+	}
+	if arc.Packs != nil {
+		view.Layout = "packed"
+		view.Packs = len(arc.Packs.Sizes)
+	}
+	for _, doc := range arc.Documents {
+		view.TotalSize += doc.Size
+	}
+	for _, t := range typeBreakdown(arc) {
+		view.Types = append(view.Types, typeView{ContentType: t.contentType, Count: t.count, Size: t.size})
+	}
+	return view
+}
+
+// typeCount is one row of the content type breakdown
+type typeCount struct {
+	contentType string
+	count       int
+	size        int64
+}
+
+// typeBreakdown groups documents by content type, most common first
+func typeBreakdown(arc *models.Arc) []typeCount {
+	byType := make(map[string]*typeCount)
+	for _, doc := range arc.Documents {
+		contentType := arcpkg.ContentTypeOf(doc)
+		t, ok := byType[contentType]
+		if !ok {
+			t = &typeCount{contentType: contentType}
+			byType[contentType] = t
+		}
+		t.count++
+		t.size += doc.Size
+	}
+
+	counts := make([]typeCount, 0, len(byType))
+	for _, t := range byType {
+		counts = append(counts, *t)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].count != counts[j].count {
+			return counts[i].count > counts[j].count
+		}
+		return counts[i].contentType < counts[j].contentType
+	})
+	return counts
+}
+
 func countUniqueTags(tagMap map[string][]string) int {
 	uniqueTags := make(map[string]bool)
 	for _, tags := range tagMap {
@@ -2,10 +2,8 @@ package cmd
 
 import (
 	"fmt"
-	"syscall"
 
 	"github.com/spf13/cobra"
-	"golang.org/x/term"
 )
 
 var keyringCmd = &cobra.Command{
@@ -93,15 +91,10 @@ func runKeyringSave(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	
-	// Prompt for password
-	fmt.Print("Enter password: ")
-	passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
+	password, err := authManager.ReadPassword(entry.ID, entry.Name)
 	if err != nil {
-		return fmt.Errorf("failed to read password: %w", err)
+		return err
 	}
-	fmt.Println()
-	
-	password := string(passwordBytes)
 	
 	// Verify password by trying to unlock
 	_, _, err = arcManager.Unlock(entry.ID, password)
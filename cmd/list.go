@@ -13,7 +13,6 @@ var listCmd = &cobra.Command{
 	Use: "list",
 	Short: "List all arcs",
 	Long: "List all arcs in the local storage directory.",
-	Aliases: []string{"ls"},
 	RunE: runList,
 }
 
@@ -24,15 +23,23 @@ func init() {
 func runList(cmd *cobra.Command, args []string) error {
 	arcs := arcManager.ListArcs()
 
+	sort.Slice(arcs, func(i, j int) bool {
+		return arcs[i].CreatedAt.After(arcs[j].CreatedAt)
+	})
+
+	if machineOutput() {
+		views := make([]arcView, len(arcs))
+		for i, arc := range arcs {
+			views[i] = arcView{ID: arc.ID, Name: arc.Name, CreatedAt: arc.CreatedAt}
+		}
+		return printOutput(views)
+	}
+
 	if len(arcs) == 0 {
 		fmt.Println("No arcs found. Create one with: arc create <name>")
 		return nil
 	}
 
-	sort.Slice(arcs, func(i, j int) bool {
-		return arcs[i].CreatedAt.After(arcs[j].CreatedAt)
-	})
-
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
 	fmt.Fprintln(w, "NAME\tID\tCREATED")
 	fmt.Fprintln(w, "----\t--\t-------")
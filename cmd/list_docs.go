@@ -7,29 +7,87 @@ import (
 	"text/tabwriter"
 	"time"
 
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
 	"github.com/spf13/cobra"
 )
 
+var (
+	docsColumns    string
+	docsWhere      []string
+	docsType       string
+	docsFilter     string
+	docsTags       []string
+	docsAnyTags    []string
+	docsCollection string
+)
+
+// defaultDocColumns is what arc docs shows without --columns
+const defaultDocColumns = "id,path,size,added,tags"
+
 var listDocsCmd = &cobra.Command{
 	Use:   "docs <arc-name-or-id>",
 	Short: "List documents in an arc",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runListDocs,
+	Long: `List documents in an arc.
+
+Columns can be any of id, path, filename, folder, size, type, added,
+modified, expires, hash and tags, or the name of a custom property. When any
+document expires, an EXPIRES column is added and documents expiring within a
+week are flagged. Filters compare a property
+with =, !=, >, >=, <, <= or ~ (contains), e.g. --where 'amount>1000'.
+Several --where flags must all match. --type accepts a full content type
+(application/pdf), a family (image) or a short name (pdf, jpg). --filter takes
+a query as accepted by arc search, e.g. --filter 'tag:legal size>1MB'.
+Documents must carry every --tag and at least one --any-tag. --collection
+lists the documents currently matching a saved collection.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runListDocs,
 }
 
 func init() {
 	rootCmd.AddCommand(listDocsCmd)
+
+	listDocsCmd.Flags().StringVar(&docsColumns, "columns", defaultDocColumns, "Comma-separated columns to show")
+	listDocsCmd.Flags().StringArrayVar(&docsWhere, "where", nil, "Only show documents whose property matches, e.g. amount>1000 (repeatable)")
+	listDocsCmd.Flags().StringVar(&docsType, "type", "", "Only show documents of this content type, e.g. pdf or image")
+	listDocsCmd.Flags().StringVar(&docsFilter, "filter", "", "Only show documents matching this query, e.g. 'tag:legal NOT name:*.tmp'")
+	listDocsCmd.Flags().StringSliceVar(&docsTags, "tag", nil, "Only show documents with all of these tags (repeatable)")
+	listDocsCmd.Flags().StringSliceVar(&docsAnyTags, "any-tag", nil, "Only show documents with at least one of these tags (repeatable)")
+	listDocsCmd.Flags().StringVar(&docsCollection, "collection", "", "Only show documents in this saved collection")
 }
 
 func runListDocs(cmd *cobra.Command, args []string) error {
 	arcNameOrID := args[0]
 
+	var filters []*arcpkg.PropertyFilter
+	for _, expr := range docsWhere {
+		filter, err := arcpkg.ParseWhere(expr)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, filter)
+	}
+
+	var q *arcpkg.Query
+	if docsFilter != "" {
+		var err error
+		if q, err = arcpkg.ParseQuery(docsFilter); err != nil {
+			return err
+		}
+	}
+
+	columns := parseColumns(docsColumns)
+	showExpiry := !cmd.Flags().Changed("columns")
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns given")
+	}
+
 	entry, err := arcManager.FindArc(arcNameOrID)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Getting docs for arc: %s\n", entry.Name)
+	logf("Getting docs for arc: %s\n", entry.Name)
 
 	password, err := authManager.GetPassword(entry.ID, entry.Name, true)
 	if err != nil {
@@ -41,40 +99,165 @@ func runListDocs(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	docs := arcManager.ListDocuments(arc)
+	docs := filterDocuments(arcManager.ListDocuments(arc), docsType, filters)
+	if q != nil {
+		docs = q.Filter(arc, docs)
+	}
+	if docsCollection != "" {
+		cq, err := arcpkg.CollectionQuery(arc, docsCollection)
+		if err != nil {
+			return err
+		}
+		docs = cq.Filter(arc, docs)
+	}
+	if len(docsTags) > 0 || len(docsAnyTags) > 0 {
+		docs = filterByTags(arc, docs, docsTags, docsAnyTags)
+	}
+
+	if machineOutput() {
+		return printOutput(documentViews(arc, docs))
+	}
 
 	if len(docs) == 0 {
-		fmt.Println("No documents in this arc.")
+		if len(filters) > 0 || docsType != "" || q != nil || len(docsTags) > 0 || len(docsAnyTags) > 0 || docsCollection != "" {
+			fmt.Println("No documents match the filters.")
+		} else {
+			fmt.Println("No documents in this arc.")
+		}
 		return nil
 	}
 
+	if showExpiry && hasExpiringDocuments(arc, docs) {
+		columns = append(columns, "expires")
+	}
+
 	fmt.Printf("\nArc : %s\n", arc.Name)
 	fmt.Printf("Documents: %d\n\n", len(docs))
 
+	headers := make([]string, len(columns))
+	rules := make([]string, len(columns))
+	for i, col := range columns {
+		headers[i] = strings.ToUpper(col)
+		rules[i] = strings.Repeat("-", len(col))
+	}
+
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "ID\tFILENAME\tSIZE\tADDED\tTAGS")
-	fmt.Fprintln(w, "--\t--------\t----\t-----\t----")
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	fmt.Fprintln(w, strings.Join(rules, "\t"))
 
 	for _, doc := range docs {
-		tags := arc.Tags[doc.ID]
-		tagStr := ""
-		if len(tags) > 0 {
-			tagStr = fmt.Sprintf("[%s]", strings.Join(tags, ", "))
+		cells := make([]string, len(columns))
+		for i, col := range columns {
+			cells[i] = docColumn(arc, doc, col)
 		}
-
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
-			doc.ID,
-			doc.Filename,
-			formatSize(doc.Size),
-			formatTime(doc.AddedAt),
-			tagStr,
-		)
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
 	}
 
 	w.Flush()
 	return nil
 }
 
+// filterByTags keeps the documents carrying every tag in allTags and, when
+// anyTags is given, at least one of them
+func filterByTags(arc *models.Arc, docs []*models.Document, allTags, anyTags []string) []*models.Document {
+	var kept []*models.Document
+	for _, doc := range docs {
+		tags := arc.Tags[doc.ID]
+		if !arcpkg.HasAllTags(tags, allTags) {
+			continue
+		}
+		if len(anyTags) > 0 && !arcpkg.HasAnyTag(tags, anyTags) {
+			continue
+		}
+		kept = append(kept, doc)
+	}
+	return kept
+}
+
+// hasExpiringDocuments reports whether any of docs has an expiry
+func hasExpiringDocuments(arc *models.Arc, docs []*models.Document) bool {
+	for _, doc := range docs {
+		if _, _, ok := arcpkg.ExpiryOf(arc, doc); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parseColumns splits a comma-separated column list
+func parseColumns(spec string) []string {
+	var columns []string
+	for _, col := range strings.Split(spec, ",") {
+		if col = strings.TrimSpace(col); col != "" {
+			columns = append(columns, col)
+		}
+	}
+	return columns
+}
+
+// filterDocuments keeps the documents of the given type matching every
+// property filter
+func filterDocuments(docs []*models.Document, contentType string, filters []*arcpkg.PropertyFilter) []*models.Document {
+	if len(filters) == 0 && contentType == "" {
+		return docs
+	}
+
+	var matched []*models.Document
+	for _, doc := range docs {
+		ok := arcpkg.MatchesType(doc, contentType)
+		for _, filter := range filters {
+			if !ok || !filter.Match(doc) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matched = append(matched, doc)
+		}
+	}
+	return matched
+}
+
+// docColumn renders one column of the docs listing. Unknown columns are
+// treated as custom property names.
+func docColumn(arc *models.Arc, doc *models.Document, col string) string {
+	switch strings.ToLower(col) {
+	case "id":
+		return doc.ID
+	case "path":
+		return doc.Path()
+	case "filename", "name":
+		return doc.Filename
+	case "folder":
+		return "/" + doc.Folder
+	case "size":
+		return formatSize(doc.Size)
+	case "type":
+		return arcpkg.ContentTypeOf(doc)
+	case "added":
+		return formatTime(doc.AddedAt)
+	case "modified":
+		return formatTime(doc.ModifiedAt)
+	case "hash":
+		return doc.ContentHash
+	case "expires":
+		if t, _, ok := arcpkg.ExpiryOf(arc, doc); ok {
+			return formatExpiry(t, time.Now())
+		}
+		return ""
+	case "tags":
+		if tags := arc.Tags[doc.ID]; len(tags) > 0 {
+			return fmt.Sprintf("[%s]", strings.Join(tags, ", "))
+		}
+		return ""
+	}
+
+	if prop, exists := doc.Properties[col]; exists {
+		return prop.Value
+	}
+	return "-"
+}
+
 func formatSize(bytes int64) string {
 	const unit = 1024
 	if bytes < unit {
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/spf13/cobra"
+)
+
+var lsCmd = &cobra.Command{
+	Use:   "ls [arc-name-or-id] [folder]",
+	Short: "Browse the folders of an arc",
+	Long: `List the subfolders and documents of arc folder inside an arc.
+Without arguments it lists all arcs, like 'arc list'.`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: runLs,
+}
+
+func init() {
+	rootCmd.AddCommand(lsCmd)
+}
+
+func runLs(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return runList(cmd, args)
+	}
+
+	arcNameOrID := args[0]
+	folder := ""
+	if len(args) > 1 {
+		folder = args[1]
+	}
+
+	folder, err := arcpkg.CleanFolder(folder)
+	if err != nil {
+		return err
+	}
+
+	entry, err := arcManager.FindArc(arcNameOrID)
+	if err != nil {
+		return err
+	}
+
+	password, err := authManager.GetPassword(entry.ID, entry.Name, true)
+	if err != nil {
+		return err
+	}
+
+	arc, _, err := arcManager.Unlock(entry.ID, password)
+	if err != nil {
+		return err
+	}
+
+	folders, docs := arcManager.ListFolder(arc, folder)
+	if len(folders) == 0 && len(docs) == 0 {
+		fmt.Printf("Folder is empty: /%s\n", folder)
+		return nil
+	}
+
+	fmt.Printf("\n%s:/%s\n\n", arc.Name, folder)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tSIZE\tADDED\tTAGS")
+	fmt.Fprintln(w, "----\t--\t----\t-----\t----")
+
+	for _, name := range folders {
+		fmt.Fprintf(w, "%s/\t\t\t\t\n", name)
+	}
+
+	for _, doc := range docs {
+		tags := arc.Tags[doc.ID]
+		tagStr := ""
+		if len(tags) > 0 {
+			tagStr = fmt.Sprintf("[%s]", strings.Join(tags, ", "))
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			doc.Filename,
+			doc.ID[:8],
+			formatSize(doc.Size),
+			formatTime(doc.AddedAt),
+			tagStr,
+		)
+	}
+
+	w.Flush()
+	return nil
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/spf13/cobra"
+)
+
+var mergeInto string
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge <arc> <arc> [arc...] --into <new-arc>",
+	Short: "Merge arcs into one",
+	Long: `Copy every document of the given arcs into another arc, re-encrypting
+them under its key. The target is created (asking for its password) unless it
+already exists. Tags, properties, folders and timestamps are kept; documents
+whose path is already taken get a " (2)" suffix. The source arcs are left
+untouched.
+
+If the merge is interrupted, run the same command again to resume it.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runMerge,
+}
+
+func init() {
+	rootCmd.AddCommand(mergeCmd)
+	mergeCmd.Flags().StringVar(&mergeInto, "into", "", "Arc to merge into (created if it does not exist)")
+	mergeCmd.MarkFlagRequired("into")
+	addSecurityFlags(mergeCmd)
+}
+
+func runMerge(cmd *cobra.Command, args []string) error {
+	var sources []*arcpkg.ArcHandle
+	seen := make(map[string]bool)
+	for _, ref := range args {
+		src, err := unlockHandle(ref)
+		if err != nil {
+			return err
+		}
+		if seen[src.ID] {
+			return fmt.Errorf("arc %s is listed twice", src.Arc.Name)
+		}
+		seen[src.ID] = true
+		sources = append(sources, src)
+	}
+
+	dst, err := openOrCreateHandle(mergeInto)
+	if err != nil {
+		return err
+	}
+
+	stats, err := arcManager.Merge(dst, sources)
+	if stats != nil {
+		printReorgStats(stats)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nMerged %d arcs into %s\n", len(sources), dst.Arc.Name)
+	return nil
+}
+
+// openOrCreateHandle unlocks an arc, creating it first if no arc has that name
+func openOrCreateHandle(name string) (*arcpkg.ArcHandle, error) {
+	if _, err := arcManager.FindArc(name); err == nil {
+		return unlockHandle(name)
+	}
+
+	arc, password, err := createArc(name)
+	if err != nil {
+		return nil, err
+	}
+
+	arc, key, err := arcManager.Unlock(arc.ID, password)
+	if err != nil {
+		return nil, err
+	}
+	return &arcpkg.ArcHandle{ID: arc.ID, Arc: arc, Key: key}, nil
+}
+
+// printReorgStats reports the outcome of a merge or split
+func printReorgStats(stats *arcpkg.ReorgStats) {
+	fmt.Printf("\nCopied:  %d\n", stats.Copied)
+	if stats.Skipped > 0 {
+		fmt.Printf("Already copied: %d\n", stats.Skipped)
+	}
+	if stats.Renamed > 0 {
+		fmt.Printf("Renamed: %d\n", stats.Renamed)
+	}
+	if stats.Removed > 0 {
+		fmt.Printf("Removed from source: %d\n", stats.Removed)
+	}
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mvRegex  string
+	mvDryRun bool
+)
+
+var mvCmd = &cobra.Command{
+	Use:   "mv <arc-name-or-id> <doc> <new-name-or-path>",
+	Short: "Rename or move a document",
+	Long: `Rename a document or move it to another virtual folder. Only metadata
+changes; the document keeps its ID, content and tags.
+
+  arc mv work-docs scan.pdf invoice-2024.pdf      rename in place
+  arc mv work-docs scan.pdf archive/2023/         move, keeping the name
+  arc mv work-docs scan.pdf archive/invoice.pdf   move and rename
+
+A target ending in / or naming an existing folder keeps the filename. Use /
+for the root.
+
+With --regex, rename in bulk with a sed-style substitution on the filenames
+of the given documents, or of every document when none are given:
+
+  arc mv photos --regex 's/IMG_/vacation_/' --dry-run`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if mvRegex != "" {
+			return cobra.MinimumNArgs(1)(cmd, args)
+		}
+		return cobra.ExactArgs(3)(cmd, args)
+	},
+	RunE: runMv,
+}
+
+func init() {
+	rootCmd.AddCommand(mvCmd)
+	mvCmd.Flags().StringVar(&mvRegex, "regex", "", "Rename with a sed-style substitution, e.g. 's/IMG_/vacation_/'")
+	mvCmd.Flags().BoolVarP(&mvDryRun, "dry-run", "n", false, "Show what would be renamed without changing anything")
+}
+
+// rename is one planned document rename
+type rename struct {
+	doc      *models.Document
+	folder   string
+	filename string
+}
+
+func (r rename) path() string {
+	return path.Join(r.folder, r.filename)
+}
+
+func runMv(cmd *cobra.Command, args []string) error {
+	arcNameOrID := args[0]
+
+	var sub *arcpkg.Substitution
+	if mvRegex != "" {
+		var err error
+		if sub, err = arcpkg.ParseSubstitution(mvRegex); err != nil {
+			return err
+		}
+	}
+
+	entry, err := arcManager.FindArc(arcNameOrID)
+	if err != nil {
+		return err
+	}
+
+	password, err := authManager.GetPassword(entry.ID, entry.Name, true)
+	if err != nil {
+		return err
+	}
+
+	arc, key, err := arcManager.Unlock(entry.ID, password)
+	if err != nil {
+		return err
+	}
+
+	var plan []rename
+	if sub != nil {
+		plan, err = planRegexRenames(arc, args[1:], sub)
+	} else {
+		plan, err = planRename(arc, args[1], args[2])
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(plan) == 0 {
+		fmt.Println("Nothing to rename")
+		return nil
+	}
+
+	for _, r := range plan {
+		fmt.Printf("/%s -> /%s\n", r.doc.Path(), r.path())
+	}
+	if mvDryRun {
+		fmt.Printf("\nDry run: %d document(s) would be renamed\n", len(plan))
+		return nil
+	}
+
+	if err := arcManager.RenameDocuments(entry.ID, arc, key, renamePlan(plan)); err != nil {
+		return err
+	}
+
+	if len(plan) > 1 {
+		fmt.Printf("\nRenamed %d documents\n", len(plan))
+	}
+	return nil
+}
+
+// planRename resolves a single rename or move
+func planRename(arc *models.Arc, docRef, target string) ([]rename, error) {
+	doc, err := arcManager.FindDocument(arc, docRef)
+	if err != nil {
+		return nil, err
+	}
+
+	folder, filename, err := arcpkg.ResolveTarget(arc, doc, target)
+	if err != nil {
+		return nil, err
+	}
+
+	r := rename{doc: doc, folder: folder, filename: filename}
+	if r.path() == doc.Path() {
+		return nil, nil
+	}
+	return []rename{r}, checkRenames(arc, []rename{r})
+}
+
+// planRegexRenames applies a substitution to the filenames of the given
+// documents, or of all documents
+func planRegexRenames(arc *models.Arc, docRefs []string, sub *arcpkg.Substitution) ([]rename, error) {
+	docs := arcManager.ListDocuments(arc)
+	if len(docRefs) > 0 {
+		docs = docs[:0]
+		for _, ref := range docRefs {
+			doc, err := arcManager.FindDocument(arc, ref)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+	}
+
+	var plan []rename
+	for _, doc := range docs {
+		filename := sub.Apply(doc.Filename)
+		if filename == doc.Filename {
+			continue
+		}
+		if filename == "" {
+			return nil, fmt.Errorf("pattern leaves /%s without a name", doc.Path())
+		}
+		plan = append(plan, rename{doc: doc, folder: doc.Folder, filename: filename})
+	}
+	return plan, checkRenames(arc, plan)
+}
+
+// checkRenames rejects plans where two documents would end up at the same
+// path, including documents the plan does not touch
+func checkRenames(arc *models.Arc, plan []rename) error {
+	return arcpkg.CheckRenames(arc, renamePlan(plan))
+}
+
+// renamePlan converts a plan for RenameDocuments
+func renamePlan(plan []rename) []arcpkg.Rename {
+	renames := make([]arcpkg.Rename, len(plan))
+	for i, r := range plan {
+		renames[i] = arcpkg.Rename{DocID: r.doc.ID, Folder: r.folder, Filename: r.filename}
+	}
+	return renames
+}
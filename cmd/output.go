@@ -0,0 +1,318 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Formats accepted by --output
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputCSV   = "csv"
+)
+
+var (
+	outputFormat string
+	quiet        bool
+)
+
+// checkOutputFlags validates --output and routes progress messages: they go
+// to stderr, and --quiet drops them
+func checkOutputFlags() error {
+	switch outputFormat {
+	case outputTable, outputJSON, outputYAML, outputCSV:
+	default:
+		return fmt.Errorf("invalid --output value: %s (use table, json, yaml or csv)", outputFormat)
+	}
+	if arcManager != nil {
+		arcManager.SetOutput(progressOutput())
+	}
+	return nil
+}
+
+// progressOutput is where progress messages go: stderr, or nowhere with --quiet
+func progressOutput() io.Writer {
+	if quiet {
+		return io.Discard
+	}
+	return os.Stderr
+}
+
+// machineOutput reports whether results are printed as json, yaml or csv
+func machineOutput() bool {
+	return outputFormat != outputTable
+}
+
+// logf prints a progress message to stderr, unless --quiet is set
+func logf(format string, args ...any) {
+	fmt.Fprintf(progressOutput(), format, args...)
+}
+
+// printOutput writes v to stdout in the machine-readable format selected
+// with --output. v is a view struct or a slice of them.
+func printOutput(v any) error {
+	switch outputFormat {
+	case outputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case outputYAML:
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	case outputCSV:
+		return writeCSV(os.Stdout, v)
+	}
+	return fmt.Errorf("no table output for this result")
+}
+
+// writeCSV writes a view struct, or a slice of them, as CSV with a header
+// of the json field names. Lists are joined with ";", maps become
+// "key=value" pairs and nested views are left out.
+func writeCSV(out io.Writer, v any) error {
+	rows := reflect.ValueOf(v)
+	if rows.Kind() != reflect.Slice {
+		rows = reflect.Append(reflect.MakeSlice(reflect.SliceOf(rows.Type()), 0, 1), rows)
+	}
+
+	w := csv.NewWriter(out)
+	if err := w.Write(csvHeader(rows.Type().Elem())); err != nil {
+		return err
+	}
+	for i := 0; i < rows.Len(); i++ {
+		if err := w.Write(csvRecord(rows.Index(i))); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// csvFields calls fn for every CSV column of a view struct, flattening
+// embedded views
+func csvFields(t reflect.Type, v reflect.Value, fn func(name string, field reflect.Value)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		var fv reflect.Value
+		if v.IsValid() {
+			fv = v.Field(i)
+		}
+		if f.Anonymous {
+			csvFields(f.Type, fv, fn)
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !csvScalar(f.Type) {
+			continue
+		}
+		fn(name, fv)
+	}
+}
+
+func csvHeader(t reflect.Type) []string {
+	var header []string
+	csvFields(t, reflect.Value{}, func(name string, _ reflect.Value) {
+		header = append(header, name)
+	})
+	return header
+}
+
+func csvRecord(v reflect.Value) []string {
+	var record []string
+	csvFields(v.Type(), v, func(_ string, field reflect.Value) {
+		record = append(record, csvValue(field))
+	})
+	return record
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// csvScalar reports whether a field fits in one CSV cell
+func csvScalar(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return t == timeType
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && t.Elem().Kind() == reflect.String
+	}
+	return true
+}
+
+func csvValue(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Float64, reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Slice:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = v.Index(i).String()
+		}
+		return strings.Join(parts, ";")
+	case reflect.Map:
+		var parts []string
+		for _, k := range v.MapKeys() {
+			parts = append(parts, k.String()+"="+v.MapIndex(k).String())
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, ";")
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			return t.Format(time.RFC3339)
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// arcView is the machine-readable form of a registered arc
+type arcView struct {
+	ID        string    `json:"id" yaml:"id"`
+	Name      string    `json:"name" yaml:"name"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+}
+
+// documentView is the machine-readable form of a document
+type documentView struct {
+	ID          string            `json:"id" yaml:"id"`
+	Path        string            `json:"path" yaml:"path"`
+	Filename    string            `json:"filename" yaml:"filename"`
+	Folder      string            `json:"folder" yaml:"folder"`
+	Size        int64             `json:"size" yaml:"size"`
+	ContentType string            `json:"content_type" yaml:"content_type"`
+	AddedAt     time.Time         `json:"added_at" yaml:"added_at"`
+	ModifiedAt  time.Time         `json:"modified_at" yaml:"modified_at"`
+	ExpiresAt   *time.Time        `json:"expires_at" yaml:"expires_at"` // effective expiry, null when the document does not expire
+	Hash        string            `json:"hash" yaml:"hash"`
+	Tags        []string          `json:"tags" yaml:"tags"`
+	Properties  map[string]string `json:"properties" yaml:"properties"`
+	LegalHold   bool              `json:"legal_hold" yaml:"legal_hold"`
+}
+
+func newDocumentView(arc *models.Arc, doc *models.Document) documentView {
+	view := documentView{
+		ID:          doc.ID,
+		Path:        doc.Path(),
+		Filename:    doc.Filename,
+		Folder:      doc.Folder,
+		Size:        doc.Size,
+		ContentType: arcpkg.ContentTypeOf(doc),
+		AddedAt:     doc.AddedAt,
+		ModifiedAt:  doc.ModifiedAt,
+		Hash:        doc.ContentHash,
+		Tags:        append([]string{}, arc.Tags[doc.ID]...),
+		Properties:  make(map[string]string, len(doc.Properties)),
+		LegalHold:   doc.LegalHold,
+	}
+	if t, _, ok := arcpkg.ExpiryOf(arc, doc); ok {
+		view.ExpiresAt = &t
+	}
+	for name, prop := range doc.Properties {
+		view.Properties[name] = prop.Value
+	}
+	return view
+}
+
+func documentViews(arc *models.Arc, docs []*models.Document) []documentView {
+	views := make([]documentView, len(docs))
+	for i, doc := range docs {
+		views[i] = newDocumentView(arc, doc)
+	}
+	return views
+}
+
+// searchResultView is a search hit: the document, its score and, for
+// content searches, the text around the match
+type searchResultView struct {
+	Arc          string  `json:"arc,omitempty" yaml:"arc,omitempty"` // set when searching several arcs
+	Score        float64 `json:"score" yaml:"score"`
+	Snippet      string  `json:"snippet,omitempty" yaml:"snippet,omitempty"`
+	documentView `yaml:",inline"`
+}
+
+// tagView is a tag and its document count
+type tagView struct {
+	Tag   string `json:"tag" yaml:"tag"`
+	Count int    `json:"count" yaml:"count"`
+}
+
+// tagNodeView is one level of the tag hierarchy
+type tagNodeView struct {
+	Tag      string        `json:"tag" yaml:"tag"`
+	Name     string        `json:"name" yaml:"name"`
+	Count    int           `json:"count" yaml:"count"` // documents tagged exactly Tag
+	Total    int           `json:"total" yaml:"total"` // including descendants
+	Children []tagNodeView `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+func tagNodeViews(nodes []*arcpkg.TagNode) []tagNodeView {
+	views := make([]tagNodeView, len(nodes))
+	for i, n := range nodes {
+		views[i] = tagNodeView{Tag: n.Tag, Name: n.Name, Count: n.Count, Total: n.Total, Children: tagNodeViews(n.Children)}
+	}
+	return views
+}
+
+// flattenTagNodes lists a tag hierarchy depth first, for CSV
+func flattenTagNodes(nodes []tagNodeView) []tagNodeView {
+	flat := make([]tagNodeView, 0, len(nodes))
+	for _, n := range nodes {
+		flat = append(flat, n)
+		flat = append(flat, flattenTagNodes(n.Children)...)
+	}
+	return flat
+}
+
+// infoView is the machine-readable form of arc info
+type infoView struct {
+	ID         string     `json:"id" yaml:"id"`
+	Name       string     `json:"name" yaml:"name"`
+	CreatedAt  time.Time  `json:"created_at" yaml:"created_at"`
+	ModifiedAt time.Time  `json:"modified_at" yaml:"modified_at"`
+	Documents  int        `json:"documents" yaml:"documents"`
+	Tags       int        `json:"tags" yaml:"tags"`
+	Encryption string     `json:"encryption" yaml:"encryption"`
+	Layout     string     `json:"layout" yaml:"layout"`
+	Packs      int        `json:"packs" yaml:"packs"`
+	TotalSize  int64      `json:"total_size" yaml:"total_size"`
+	Types      []typeView `json:"types" yaml:"types"`
+}
+
+// typeView is one row of the content type breakdown
+type typeView struct {
+	ContentType string `json:"content_type" yaml:"content_type"`
+	Count       int    `json:"count" yaml:"count"`
+	Size        int64  `json:"size" yaml:"size"`
+}
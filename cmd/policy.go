@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/spf13/cobra"
+)
+
+var (
+	policyImmutableUntil string
+	policyMaxSize        string
+	policySetAdmin       bool
+)
+
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Manage write-once policies and legal holds",
+	Long: `Make an arc write-once (WORM): while it is immutable, documents cannot be
+removed, changed, renamed or untagged, and the arc cannot be deleted.
+Documents under legal hold are protected the same way regardless of the
+date. A size limit caps the total size of the documents.
+
+Tightening a policy is always allowed. Loosening it (an earlier or cleared
+date, a higher or cleared limit, releasing a hold, replacing the admin
+passphrase) requires the admin passphrase, which is separate from the arc
+password. Without an admin passphrase, a policy can never be loosened.`,
+}
+
+var policyShowCmd = &cobra.Command{
+	Use:   "show <arc-name-or-id>",
+	Short: "Show an arc's policy",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPolicyShow,
+}
+
+var policySetCmd = &cobra.Command{
+	Use:   "set <arc-name-or-id>",
+	Short: "Change an arc's policy",
+	Long: `Change an arc's policy.
+
+  arc policy set legal --immutable-until 2031-12-31 --admin
+  arc policy set legal --max-size 50GB`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPolicySet,
+}
+
+var policyHoldCmd = &cobra.Command{
+	Use:   "hold <arc-name-or-id> <doc> [doc...]",
+	Short: "Place documents under legal hold",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runPolicyHold,
+}
+
+var policyReleaseCmd = &cobra.Command{
+	Use:   "release <arc-name-or-id> <doc> [doc...]",
+	Short: "Release a legal hold (needs the admin passphrase)",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runPolicyRelease,
+}
+
+func init() {
+	rootCmd.AddCommand(policyCmd)
+	policyCmd.AddCommand(policyShowCmd)
+	policyCmd.AddCommand(policySetCmd)
+	policyCmd.AddCommand(policyHoldCmd)
+	policyCmd.AddCommand(policyReleaseCmd)
+
+	policySetCmd.Flags().StringVar(&policyImmutableUntil, "immutable-until", "", "Lock documents until a date (2031-12-31), for a period (7y), or never")
+	policySetCmd.Flags().StringVar(&policyMaxSize, "max-size", "", "Limit the total document size, e.g. 50GB, or none")
+	policySetCmd.Flags().BoolVar(&policySetAdmin, "admin", false, "Set or replace the admin passphrase")
+}
+
+func runPolicyShow(cmd *cobra.Command, args []string) error {
+	h, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+	arc := h.Arc
+
+	fmt.Printf("\nPolicy for %s\n", arc.Name)
+	fmt.Printf("================\n")
+
+	policy := arc.Policy
+	switch {
+	case policy == nil || policy.ImmutableUntil == nil:
+		fmt.Printf("Immutable:    no\n")
+	case arcpkg.Immutable(arc, time.Now()):
+		fmt.Printf("Immutable:    until %s\n", policy.ImmutableUntil.Format("2006-01-02 15:04"))
+	default:
+		fmt.Printf("Immutable:    expired on %s\n", policy.ImmutableUntil.Format("2006-01-02 15:04"))
+	}
+
+	size := arcpkg.ArcSize(arc)
+	if policy != nil && policy.MaxSize > 0 {
+		fmt.Printf("Size limit:   %s (%s used)\n", formatSize(policy.MaxSize), formatSize(size))
+	} else {
+		fmt.Printf("Size limit:   none (%s used)\n", formatSize(size))
+	}
+
+	if arcpkg.NeedsAdmin(arc) {
+		fmt.Printf("Admin:        passphrase set\n")
+	} else {
+		fmt.Printf("Admin:        none (policy can only be tightened)\n")
+	}
+
+	var held []string
+	for _, doc := range arcManager.ListDocuments(arc) {
+		if doc.LegalHold {
+			held = append(held, doc.Path())
+		}
+	}
+	fmt.Printf("Legal holds:  %d\n", len(held))
+	for _, p := range held {
+		fmt.Printf("	/%s\n", p)
+	}
+	return nil
+}
+
+func runPolicySet(cmd *cobra.Command, args []string) error {
+	var update arcpkg.PolicyUpdate
+
+	if cmd.Flags().Changed("immutable-until") {
+		until, err := arcpkg.ParseExpiry(policyImmutableUntil, time.Now())
+		if err != nil {
+			return err
+		}
+		if until == nil {
+			until = &time.Time{}
+		}
+		update.ImmutableUntil = until
+	}
+
+	if cmd.Flags().Changed("max-size") {
+		size, err := parseSize(policyMaxSize)
+		if err != nil {
+			return err
+		}
+		update.MaxSize = &size
+	}
+
+	if update.ImmutableUntil == nil && update.MaxSize == nil && !policySetAdmin {
+		return fmt.Errorf("nothing to change: use --immutable-until, --max-size or --admin")
+	}
+
+	h, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+
+	if policySetAdmin {
+		fmt.Println("New admin passphrase")
+		if update.AdminPassphrase, err = promptNewPassword(); err != nil {
+			return err
+		}
+	}
+
+	err = withAdmin(h, func(admin string) error {
+		return arcManager.SetPolicy(h.ID, h.Arc, h.Key, update, admin)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Policy updated")
+	return nil
+}
+
+func runPolicyHold(cmd *cobra.Command, args []string) error {
+	return setLegalHold(args[0], args[1:], true)
+}
+
+func runPolicyRelease(cmd *cobra.Command, args []string) error {
+	return setLegalHold(args[0], args[1:], false)
+}
+
+// setLegalHold places or releases holds on the referenced documents
+func setLegalHold(arcNameOrID string, docRefs []string, hold bool) error {
+	h, err := unlockHandle(arcNameOrID)
+	if err != nil {
+		return err
+	}
+
+	docs, err := selectDocuments(h.Arc, docRefs, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	docIDs := make([]string, len(docs))
+	for i, doc := range docs {
+		docIDs[i] = doc.ID
+	}
+
+	err = withAdmin(h, func(admin string) error {
+		return arcManager.SetLegalHold(h.ID, h.Arc, h.Key, docIDs, hold, admin)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range docs {
+		if hold {
+			fmt.Printf("Held: /%s\n", doc.Path())
+		} else {
+			fmt.Printf("Released: /%s\n", doc.Path())
+		}
+	}
+	return nil
+}
+
+// withAdmin runs fn without the admin passphrase first and, if the change
+// turns out to loosen the policy, prompts for it and runs fn again
+func withAdmin(h *arcpkg.ArcHandle, fn func(admin string) error) error {
+	err := fn("")
+	if !errors.Is(err, arcpkg.ErrAdminRequired) || !arcpkg.NeedsAdmin(h.Arc) {
+		return err
+	}
+
+	admin, err := authManager.PromptSecret("This loosens the policy. Admin passphrase: ")
+	if err != nil {
+		return fmt.Errorf("failed to read passphrase: %w", err)
+	}
+
+	return fn(admin)
+}
+
+// parseSize reads a byte size such as 500MB or 2GB; "none" is 0
+func parseSize(s string) (int64, error) {
+	if strings.EqualFold(strings.TrimSpace(s), "none") {
+		return 0, nil
+	}
+	return arcpkg.ParseSize(s)
+}
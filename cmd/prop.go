@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var propType string
+
+var propCmd = &cobra.Command{
+	Use:   "prop",
+	Short: "Manage custom document properties",
+	Long: `Attach typed key-value properties to documents.
+
+Types are string, number, date (YYYY-MM-DD) and bool. When --type is not
+given the type is inferred from the value, as long as the value stays exactly
+as written: 02134 or 1.50 are kept as strings unless --type number is given.`,
+}
+
+var propSetCmd = &cobra.Command{
+	Use:   "set <arc-name-or-id> <doc> <key=value> [key=value...]",
+	Short: "Set properties on a document",
+	Args:  cobra.MinimumNArgs(3),
+	RunE:  runPropSet,
+}
+
+var propGetCmd = &cobra.Command{
+	Use:   "get <arc-name-or-id> <doc> [key]",
+	Short: "Show the properties of a document",
+	Args:  cobra.RangeArgs(2, 3),
+	RunE:  runPropGet,
+}
+
+var propUnsetCmd = &cobra.Command{
+	Use:   "unset <arc-name-or-id> <doc> <key> [key...]",
+	Short: "Remove properties from a document",
+	Args:  cobra.MinimumNArgs(3),
+	RunE:  runPropUnset,
+}
+
+func init() {
+	rootCmd.AddCommand(propCmd)
+	propCmd.AddCommand(propSetCmd)
+	propCmd.AddCommand(propGetCmd)
+	propCmd.AddCommand(propUnsetCmd)
+
+	propSetCmd.Flags().StringVarP(&propType, "type", "t", "", "Property type: string, number, date or bool (inferred by default)")
+}
+
+// unlockDocument unlocks an arc and resolves a document reference in it
+func unlockDocument(arcNameOrID, docRef string) (*arcpkg.ArcEntry, *models.Arc, []byte, *models.Document, error) {
+	entry, err := arcManager.FindArc(arcNameOrID)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	password, err := authManager.GetPassword(entry.ID, entry.Name, true)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	arc, key, err := arcManager.Unlock(entry.ID, password)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	doc, err := arcManager.FindDocument(arc, docRef)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return entry, arc, key, doc, nil
+}
+
+func runPropSet(cmd *cobra.Command, args []string) error {
+	// Parse everything before unlocking so typos fail fast
+	names := make([]string, 0, len(args)-2)
+	props := make(map[string]*models.Property)
+	for _, arg := range args[2:] {
+		name, raw, ok := strings.Cut(arg, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid property %q: expected key=value", arg)
+		}
+		prop, err := arcpkg.ParseProperty(raw, propType)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+		if _, seen := props[name]; !seen {
+			names = append(names, name)
+		}
+		props[name] = prop
+	}
+
+	entry, arc, key, doc, err := unlockDocument(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	err = arcManager.Batch(entry.ID, arc, key, func() error {
+		for _, name := range names {
+			if err := arcManager.SetProperty(entry.ID, arc, key, doc.ID, name, props[name]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		fmt.Printf("Set %s = %s (%s)\n", name, props[name].Value, props[name].Type)
+	}
+	return nil
+}
+
+func runPropGet(cmd *cobra.Command, args []string) error {
+	_, _, _, doc, err := unlockDocument(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 3 {
+		prop, exists := doc.Properties[args[2]]
+		if !exists {
+			return fmt.Errorf("property not set: %s", args[2])
+		}
+		fmt.Println(prop.Value)
+		return nil
+	}
+
+	if len(doc.Properties) == 0 {
+		fmt.Printf("No properties on /%s\n", doc.Path())
+		return nil
+	}
+
+	names := make([]string, 0, len(doc.Properties))
+	for name := range doc.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tTYPE")
+	fmt.Fprintln(w, "---\t-----\t----")
+	for _, name := range names {
+		prop := doc.Properties[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, prop.Value, prop.Type)
+	}
+	w.Flush()
+	return nil
+}
+
+func runPropUnset(cmd *cobra.Command, args []string) error {
+	entry, arc, key, doc, err := unlockDocument(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	names := args[2:]
+	for _, name := range names {
+		if _, exists := doc.Properties[name]; !exists {
+			return fmt.Errorf("property not set: %s", name)
+		}
+	}
+
+	if err := arcManager.UnsetProperty(entry.ID, arc, key, doc.ID, names); err != nil {
+		return err
+	}
+
+	fmt.Printf("Properties removed: %v\n", names)
+	return nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex <arc-name-or-id>",
+	Short: "Rebuild the full-text search index of an arc",
+	Long: `Extract the text of every document again and rebuild the encrypted index
+used by 'arc search --content'. Needed for documents added before the index
+existed, or after an interrupted write left the index behind.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runReindex,
+}
+
+func init() {
+	rootCmd.AddCommand(reindexCmd)
+}
+
+func runReindex(cmd *cobra.Command, args []string) error {
+	arcNameOrID := args[0]
+
+	entry, err := arcManager.FindArc(arcNameOrID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Reindexing arc: %s\n", entry.Name)
+
+	password, err := authManager.GetPassword(entry.ID, entry.Name, true)
+	if err != nil {
+		return err
+	}
+
+	arc, key, err := arcManager.Unlock(entry.ID, password)
+	if err != nil {
+		return err
+	}
+
+	withText, err := arcManager.Reindex(entry.ID, arc, key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nIndex rebuilt\n")
+	fmt.Printf("	Documents: %d\n", len(arc.Documents))
+	fmt.Printf("	With text: %d\n", withText)
+	return nil
+}
@@ -1,33 +1,59 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+var (
+	removeQuery string
+	removeForce bool
+)
+
 var removeCmd = &cobra.Command{
-	Use:   "remove <arc-name-or-id> <doc-id>",
-	Short: "Remove a document from an arc",
+	Use:   "remove <arc-name-or-id> [doc...]",
+	Short: "Remove documents from an arc",
+	Long: `Remove documents from an arc, named directly or selected with --query:
+
+  arc remove inbox scan.pdf
+  arc remove inbox --query 'tag:temp added<30d'
+
+Removing more than one document lists them and asks for confirmation
+unless --force is given.`,
 	Aliases: []string{"rm"},
-	Args:  cobra.ExactArgs(2),
-	RunE:  runRemove,
+	Args:    cobra.MinimumNArgs(1),
+	RunE:    runRemove,
 }
 
 func init() {
 	rootCmd.AddCommand(removeCmd)
+	removeCmd.Flags().StringVar(&removeQuery, "query", "", "Remove the documents matching this query")
+	removeCmd.Flags().BoolVarP(&removeForce, "force", "f", false, "Skip the confirmation prompt")
 }
 
 func runRemove(cmd *cobra.Command, args []string) error {
 	arcNameOrID := args[0]
-	docID := args[1]
+	docRefs := args[1:]
+
+	if len(docRefs) == 0 && removeQuery == "" {
+		return fmt.Errorf("name the documents to remove or select them with --query")
+	}
+
+	queries, err := parseQueries(removeQuery)
+	if err != nil {
+		return err
+	}
 
 	entry, err := arcManager.FindArc(arcNameOrID)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Removing from arc: %s\n", entry.Name)
+	logf("Removing from arc: %s\n", entry.Name)
 
 	password, err := authManager.GetPassword(entry.ID, entry.Name, true)
 	if err != nil {
@@ -39,9 +65,56 @@ func runRemove(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if err := arcManager.RemoveDocument(entry.ID, arc, key, docID); err != nil {
+	docs, err := selectDocuments(arc, docRefs, nil, queries, nil)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		if machineOutput() {
+			return printOutput([]documentView{})
+		}
+		fmt.Println("No documents selected")
+		return nil
+	}
+
+	// The prompt goes to stderr so stdout only carries the result
+	if len(docs) > 1 && !removeForce {
+		if noPrompt {
+			return fmt.Errorf("removing %d documents needs confirmation: use --force with --no-prompt", len(docs))
+		}
+		fmt.Fprintf(os.Stderr, "\nThis will remove %d documents:\n", len(docs))
+		for _, doc := range docs {
+			fmt.Fprintf(os.Stderr, "	/%s\n", doc.Path())
+		}
+		fmt.Fprint(os.Stderr, "Remove them? [y/N]: ")
+
+		reader := bufio.NewReader(os.Stdin)
+		answer, _ := reader.ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Fprintln(os.Stderr, "Removal cancelled.")
+			if machineOutput() {
+				return printOutput([]documentView{})
+			}
+			return nil
+		}
+	}
+
+	// Views are taken first, the documents are gone afterwards
+	removed := documentViews(arc, docs)
+	err = arcManager.Batch(entry.ID, arc, key, func() error {
+		for _, doc := range docs {
+			if err := arcManager.RemoveDocument(entry.ID, arc, key, doc.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
+	if machineOutput() {
+		return printOutput(removed)
+	}
 	return nil
 }
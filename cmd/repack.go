@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var repackConvert bool
+
+var repackCmd = &cobra.Command{
+	Use:   "repack <arc-name-or-id>",
+	Short: "Compact the pack files of an arc",
+	Long: `Rewrite pack files that contain removed documents and fold small loose
+documents into packs. Use --convert to switch an arc to the packed layout.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRepack,
+}
+
+func init() {
+	rootCmd.AddCommand(repackCmd)
+	repackCmd.Flags().BoolVar(&repackConvert, "convert", false, "Switch the arc to the packed layout first")
+}
+
+func runRepack(cmd *cobra.Command, args []string) error {
+	arcNameOrID := args[0]
+
+	entry, err := arcManager.FindArc(arcNameOrID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Repacking arc: %s\n", entry.Name)
+
+	password, err := authManager.GetPassword(entry.ID, entry.Name, true)
+	if err != nil {
+		return err
+	}
+
+	arc, key, err := arcManager.Unlock(entry.ID, password)
+	if err != nil {
+		return err
+	}
+
+	if arc.Packs == nil {
+		if !repackConvert {
+			return fmt.Errorf("arc uses the loose layout, use --convert to switch to packs")
+		}
+		if err := arcManager.EnablePacking(entry.ID, arc, key); err != nil {
+			return fmt.Errorf("failed to enable packed layout: %w", err)
+		}
+	}
+
+	stats, err := arcManager.Repack(entry.ID, arc, key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nRepack complete\n")
+	fmt.Printf("	Documents packed: %d\n", stats.Packed)
+	fmt.Printf("	Packs removed: %d\n", stats.PacksRemoved)
+	fmt.Printf("	Loose files folded: %d\n", stats.LooseRemoved)
+	fmt.Printf("	Reclaimed: %s\n", formatSize(stats.BytesReclaimed))
+	return nil
+}
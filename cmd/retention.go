@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/spf13/cobra"
+)
+
+var retentionCmd = &cobra.Command{
+	Use:   "retention",
+	Short: "Manage document retention",
+	Long: `Manage when documents expire. A document expires at its own expiry date
+or when a retention rule for one of its tags runs out, whichever comes first.
+Expired documents are removed with arc expire.
+
+Periods are written as 36h, 30d, 6w, 18mo or 7y.`,
+}
+
+var retentionRulesCmd = &cobra.Command{
+	Use:   "rules <arc-name-or-id>",
+	Short: "List retention rules",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRetentionRules,
+}
+
+var retentionAddCmd = &cobra.Command{
+	Use:   "add <arc-name-or-id> <tag> <period>",
+	Short: "Expire documents with a tag a period after they were added",
+	Args:  cobra.ExactArgs(3),
+	RunE:  runRetentionAdd,
+}
+
+var retentionRemoveCmd = &cobra.Command{
+	Use:   "remove <arc-name-or-id> <tag>",
+	Short: "Remove the retention rule for a tag",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runRetentionRemove,
+}
+
+var retentionSetCmd = &cobra.Command{
+	Use:   "set <arc-name-or-id> <doc> <date|period|never>",
+	Short: "Set when a document expires",
+	Args:  cobra.ExactArgs(3),
+	RunE:  runRetentionSet,
+}
+
+var retentionLogCmd = &cobra.Command{
+	Use:   "log <arc-name-or-id>",
+	Short: "Show documents purged because they expired",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRetentionLog,
+}
+
+func init() {
+	rootCmd.AddCommand(retentionCmd)
+	retentionCmd.AddCommand(retentionRulesCmd)
+	retentionCmd.AddCommand(retentionAddCmd)
+	retentionCmd.AddCommand(retentionRemoveCmd)
+	retentionCmd.AddCommand(retentionSetCmd)
+	retentionCmd.AddCommand(retentionLogCmd)
+}
+
+func runRetentionRules(cmd *cobra.Command, args []string) error {
+	h, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(h.Arc.RetentionRules) == 0 {
+		fmt.Println("No retention rules")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TAG\tEXPIRES AFTER")
+	fmt.Fprintln(w, "---\t-------------")
+	for _, rule := range h.Arc.RetentionRules {
+		fmt.Fprintf(w, "%s\t%s\n", rule.Tag, rule.After)
+	}
+	w.Flush()
+	return nil
+}
+
+func runRetentionAdd(cmd *cobra.Command, args []string) error {
+	h, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := arcManager.SetRetentionRule(h.ID, h.Arc, h.Key, args[1], args[2]); err != nil {
+		return err
+	}
+
+	fmt.Printf("Documents tagged %s now expire %s after they were added\n", args[1], args[2])
+	return nil
+}
+
+func runRetentionRemove(cmd *cobra.Command, args []string) error {
+	h, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := arcManager.RemoveRetentionRule(h.ID, h.Arc, h.Key, args[1]); err != nil {
+		return err
+	}
+
+	fmt.Printf("Retention rule removed: %s\n", args[1])
+	return nil
+}
+
+func runRetentionSet(cmd *cobra.Command, args []string) error {
+	expires, err := arcpkg.ParseExpiry(args[2], time.Now())
+	if err != nil {
+		return err
+	}
+
+	entry, arc, key, doc, err := unlockDocument(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	if err := arcManager.SetExpiry(entry.ID, arc, key, doc.ID, expires); err != nil {
+		return err
+	}
+
+	if t, reason, ok := arcpkg.ExpiryOf(arc, doc); ok {
+		fmt.Printf("/%s expires %s (%s)\n", doc.Path(), t.Format("2006-01-02 15:04"), reason)
+	} else {
+		fmt.Printf("/%s does not expire\n", doc.Path())
+	}
+	return nil
+}
+
+func runRetentionLog(cmd *cobra.Command, args []string) error {
+	h, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+
+	records, err := arcManager.PurgeLog(h.ID, h.Key)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		fmt.Println("No documents have been purged")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PURGED\tPATH\tEXPIRED\tREASON\tSHA-256")
+	fmt.Fprintln(w, "------\t----\t-------\t------\t-------")
+	for _, rec := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			rec.PurgedAt.Format("2006-01-02 15:04"),
+			rec.Path,
+			rec.ExpiredAt.Format("2006-01-02"),
+			rec.Reason,
+			rec.ContentHash[:12],
+		)
+	}
+	w.Flush()
+	return nil
+}
+
+// formatExpiry renders an expiry for listings, flagging documents that
+// expire within a week
+func formatExpiry(t time.Time, now time.Time) string {
+	left := t.Sub(now)
+	switch {
+	case left <= 0:
+		return "⚠ EXPIRED"
+	case left < 24*time.Hour:
+		return "⚠ today"
+	case left < 7*24*time.Hour:
+		return fmt.Sprintf("⚠ in %d days", int(math.Ceil(left.Hours()/24)))
+	}
+	return t.Format("2006-01-02")
+}
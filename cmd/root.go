@@ -8,6 +8,7 @@ import (
 
 	"github.com/ViniTamanhao/arcadio/internal/arc"
 	"github.com/ViniTamanhao/arcadio/internal/auth"
+	"github.com/ViniTamanhao/arcadio/internal/storage"
 	"github.com/spf13/cobra"
 )
 
@@ -15,6 +16,10 @@ var (
 	arcManager  *arc.Manager
 	authManager *auth.Manager
 	baseDir     string
+
+	passwordFile string
+	passwordFD   int
+	noPrompt     bool
 )
 
 var rootCmd = &cobra.Command{
@@ -22,6 +27,14 @@ var rootCmd = &cobra.Command{
 	Short: "arcadio - Secure encrypted document archives",
 	Long: `arcadio (arc) is a CLI tool for creating and managing encrypted document archives.
 Each arc is a secure arc that protects your documents with strong encryption.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Arguments are valid by now, so later errors are not usage mistakes
+		cmd.SilenceUsage = true
+		if err := checkOutputFlags(); err != nil {
+			return err
+		}
+		return configureAuth(cmd)
+	},
 }
 
 func Execute() error {
@@ -31,27 +44,48 @@ func Execute() error {
 func init() {
 	cobra.OnInitialize(initConfig)
 	
-	rootCmd.PersistentFlags().StringVar(&baseDir, "base-dir", "", "Base directory for arcs (default: ~/.arcadio)")
+	rootCmd.PersistentFlags().StringVar(&baseDir, "base-dir", "", "Base directory for arcs, or s3://bucket/prefix (default: ~/.arcadio)")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputTable, "Output format: table, json, yaml or csv")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Suppress progress messages")
+	rootCmd.PersistentFlags().StringVar(&passwordFile, "password-file", "", "Read the arc password from this file")
+	rootCmd.PersistentFlags().IntVar(&passwordFD, "password-fd", -1, "Read the arc password from this open file descriptor")
+	rootCmd.PersistentFlags().BoolVar(&noPrompt, "no-prompt", false, "Fail instead of prompting when a password or answer is needed")
+	rootCmd.MarkFlagsMutuallyExclusive("password-file", "password-fd")
+}
+
+// configureAuth passes the password flags to the auth manager
+func configureAuth(cmd *cobra.Command) error {
+	if passwordFile != "" {
+		authManager.SetPasswordFile(passwordFile)
+	}
+	if cmd.Flags().Changed("password-fd") {
+		if passwordFD < 0 {
+			return fmt.Errorf("invalid --password-fd: %d", passwordFD)
+		}
+		authManager.SetPasswordFD(passwordFD)
+	}
+	authManager.SetNoPrompt(noPrompt)
+	return nil
 }
 
 func initConfig() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting home directory: %v\n", err)
+		os.Exit(1)
+	}
+
 	if baseDir == "" {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error getting home directory: %v\n", err)
-			os.Exit(1)
-		}
 		baseDir = filepath.Join(home, ".arcadio", "arcs")
 	}
 
-	// Create base directory
-	if err := os.MkdirAll(baseDir, 0700); err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating base directory: %v\n", err)
-		os.Exit(1)
+	// Local config lives next to the arcs; remote arcs keep it in the home directory
+	configDir := filepath.Join(baseDir, "..")
+	if storage.IsRemote(baseDir) {
+		configDir = filepath.Join(home, ".arcadio")
 	}
 
 	// Initialize managers
-	var err error
 	arcManager, err = arc.NewManager(baseDir)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing arc manager: %v\n", err)
@@ -59,5 +93,5 @@ func initConfig() {
 	}
 
 	// Initialize auth manager
-	authManager = auth.NewManager(filepath.Join(baseDir, ".."))
+	authManager = auth.NewManager(configDir)
 }
@@ -1,73 +1,557 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"text/tabwriter"
 
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/ViniTamanhao/arcadio/internal/auth"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	searchType     string
+	searchLimit    int
+	searchContent  bool
+	searchAll      bool
+	searchArcs     []string
 )
 
 var searchCmd = &cobra.Command{
-	Use:   "search <arc-name-or-id> <query>",
+	Use:   "search <arc-name-or-id> <query...>",
 	Short: "Search for documents in an arc",
-	Args:  cobra.ExactArgs(2),
-	RunE:  runSearch,
+	Long: `Search document paths and tags. Matching ignores case and accents and
+tolerates small typos; every word of the query must match. Results are
+ranked best match first.
+
+Words can be mixed with field comparisons and combined with AND, OR, NOT
+(or a leading -) and parentheses:
+
+  arc search legal 'tag:contract AND (name:*.pdf OR type:image) size>1MB NOT tag:draft'
+  arc search photos vacation added>=2024-06-01 added<2024-09-01
+
+Fields are tag, name, path, folder, type, size, added, modified, expires and
+prop.<name>. Text fields take : (contains, or a glob with * ? [ ]), = or !=;
+size, dates and properties also take >, >=, < and <=. Dates can be given as
+2025-01-31, today or a period such as 30d.
+
+With --content, search the text of the documents instead: plain text,
+Markdown, HTML and PDFs with a text layer are indexed when added.
+
+With --all or --arcs, search several arcs at once and leave out the arc
+argument. Saved passwords are used where available and the rest are asked
+for, or skipped with --no-prompt:
+
+  arc search --all invoice 2024
+  arc search --arcs finance,legal --no-prompt 'tag:contract'`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if searchAll || len(searchArcs) > 0 {
+			return cobra.MinimumNArgs(1)(cmd, args)
+		}
+		return cobra.MinimumNArgs(2)(cmd, args)
+	},
+	RunE: runSearch,
 }
 
 func init() {
 	rootCmd.AddCommand(searchCmd)
+
+	searchCmd.Flags().StringVar(&searchType, "type", "", "Only match documents of this content type, e.g. pdf or image")
+	searchCmd.Flags().BoolVar(&searchContent, "content", false, "Search document text instead of names and tags")
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 20, "Maximum number of results, 0 for all")
+	searchCmd.Flags().BoolVar(&searchAll, "all", false, "Search every registered arc")
+	searchCmd.Flags().StringSliceVar(&searchArcs, "arcs", nil, "Search these arcs (comma-separated names or IDs)")
+	searchCmd.MarkFlagsMutuallyExclusive("all", "arcs")
 }
 
 func runSearch(cmd *cobra.Command, args []string) error {
+	if searchAll || len(searchArcs) > 0 {
+		return searchManyArcs(strings.Join(args, " "))
+	}
+
 	arcNameOrID := args [0]
-	query := args[1]
+	query := strings.Join(args[1:], " ")
+
+	var q *arcpkg.Query
+	if !searchContent {
+		var err error
+		if q, err = arcpkg.ParseQuery(query); err != nil {
+			return err
+		}
+	}
 
 	entry, err := arcManager.FindArc(arcNameOrID)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Searching arc: %s\n", entry.Name)
+	logf("Searching arc: %s\n", entry.Name)
 
 	password, err := authManager.GetPassword(entry.ID, entry.Name, true)
 	if err != nil {
 		return err
 	}
 
-	arc, _, err := arcManager.Unlock(entry.ID, password)
+	arc, key, err := arcManager.Unlock(entry.ID, password)
 	if err != nil {
 		return err
 	}
 
-	results := arcManager.SearchDocuments(arc, query)
+	if searchContent {
+		return searchDocumentText(entry, arc, key, query)
+	}
+
+	results := filterResultsByType(arcManager.Search(arc, q, 0))
+
+	if machineOutput() {
+		views := make([]searchResultView, 0, len(results))
+		for _, result := range results[:searchCount(len(results))] {
+			views = append(views, newSearchResultView("", arc, result.Doc, result.Score, ""))
+		}
+		return printOutput(views)
+	}
+
 	if len(results) == 0 {
 		fmt.Printf("No documents found matching: %s\n", query)
 		return nil
 	}
 
-	fmt.Printf("\nFound %d document(s) matching: %s\n\n", len(results), query)
+	total := len(results)
+	if searchLimit > 0 && total > searchLimit {
+		results = results[:searchLimit]
+	}
+
+	if len(results) < total {
+		fmt.Printf("\nShowing %d of %d document(s) matching: %s\n\n", len(results), total, query)
+	} else {
+		fmt.Printf("\nFound %d document(s) matching: %s\n\n", total, query)
+	}
+
+	// PATH goes last so highlighting escapes don't upset column alignment
+	highlight := term.IsTerminal(int(os.Stdout.Fd()))
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "ID\tFILENAME\tSIZE\tTAGS")
-	fmt.Fprintln(w, "--\t--------\t----\t----")
+	fmt.Fprintln(w, "ID\tSCORE\tTYPE\tSIZE\tTAGS\tPATH")
+	fmt.Fprintln(w, "--\t-----\t----\t----\t----\t----")
 
-	for _, doc := range results {
+	for _, result := range results {
+		doc := result.Doc
 		tags := arc.Tags[doc.ID]
 		tagStr := ""
 		if len(tags) > 0 {
 			tagStr = fmt.Sprintf("[%s]", strings.Join(tags, ", "))
 		}
 
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n",
+		path := doc.Path()
+		if highlight {
+			path = highlightSpans(path, result.Spans)
+		}
+
+		fmt.Fprintf(w, "%s\t%.2f\t%s\t%s\t%s\t%s\n",
+			doc.ID[:8]+"...",
+			result.Score,
+			arcpkg.ContentTypeOf(doc),
+			formatSize(doc.Size),
+			tagStr,
+			path,
+		)
+	}
+
+	w.Flush()
+	return nil
+}
+
+// searchDocumentText runs a full-text search and prints each hit with the
+// text around the match
+func searchDocumentText(entry *arcpkg.ArcEntry, arc *models.Arc, key []byte, query string) error {
+	found, unindexed, err := arcManager.SearchContent(entry.ID, arc, key, query, 0)
+	if err != nil {
+		return err
+	}
+
+	results := filterContentByType(found)
+
+	if machineOutput() {
+		views := make([]searchResultView, 0, len(results))
+		for _, result := range results[:searchCount(len(results))] {
+			views = append(views, newSearchResultView("", arc, result.Doc, result.Score, result.Snippet))
+		}
+		if err := printOutput(views); err != nil {
+			return err
+		}
+		if unindexed > 0 {
+			logf("Note: %d document(s) are not in the search index, run 'arc reindex %s' to include them\n", unindexed, entry.Name)
+		}
+		return nil
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("No documents found containing: %s\n", query)
+	} else {
+		total := len(results)
+		if searchLimit > 0 && total > searchLimit {
+			results = results[:searchLimit]
+		}
+		if len(results) < total {
+			fmt.Printf("\nShowing %d of %d document(s) containing: %s\n", len(results), total, query)
+		} else {
+			fmt.Printf("\nFound %d document(s) containing: %s\n", total, query)
+		}
+
+		highlight := term.IsTerminal(int(os.Stdout.Fd()))
+		for _, result := range results {
+			snippet := result.Snippet
+			if highlight {
+				snippet = highlightSpans(snippet, result.Spans)
+			}
+			fmt.Printf("\n/%s  (%s, score %.2f)\n", result.Doc.Path(), result.Doc.ID[:8], result.Score)
+			fmt.Printf("    %s\n", snippet)
+		}
+	}
+
+	if unindexed > 0 {
+		fmt.Printf("\nNote: %d document(s) are not in the search index, run 'arc reindex %s' to include them\n", unindexed, entry.Name)
+	}
+	return nil
+}
+
+// searchCount is how many of total results --limit lets through
+func searchCount(total int) int {
+	if searchLimit > 0 && total > searchLimit {
+		return searchLimit
+	}
+	return total
+}
+
+// newSearchResultView builds the machine-readable form of a hit. arcName is
+// only set when searching several arcs.
+func newSearchResultView(arcName string, arc *models.Arc, doc *models.Document, score float64, snippet string) searchResultView {
+	return searchResultView{
+		Arc:          arcName,
+		Score:        score,
+		Snippet:      snippet,
+		documentView: newDocumentView(arc, doc),
+	}
+}
+
+// filterResultsByType keeps the results whose document matches --type
+func filterResultsByType(found []arcpkg.SearchResult) []arcpkg.SearchResult {
+	var results []arcpkg.SearchResult
+	for _, result := range found {
+		if arcpkg.MatchesType(result.Doc, searchType) {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// filterContentByType keeps the full-text hits whose document matches --type
+func filterContentByType(found []arcpkg.ContentResult) []arcpkg.ContentResult {
+	var results []arcpkg.ContentResult
+	for _, result := range found {
+		if arcpkg.MatchesType(result.Doc, searchType) {
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+// arcSearch is the search of one arc in a multi-arc search
+type arcSearch struct {
+	entry     *arcpkg.ArcEntry
+	password  string
+	arc       *models.Arc
+	results   []arcpkg.SearchResult
+	content   []arcpkg.ContentResult
+	unindexed int
+	err       error
+}
+
+// searchManyArcs searches the arcs picked by --all or --arcs. Passwords are
+// gathered one arc at a time, since prompts can't overlap, then the arcs are
+// unlocked and searched in parallel. An arc that can't be searched is
+// reported without stopping the others.
+func searchManyArcs(query string) error {
+	var q *arcpkg.Query
+	if !searchContent {
+		var err error
+		if q, err = arcpkg.ParseQuery(query); err != nil {
+			return err
+		}
+	}
+
+	entries, err := searchTargets()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		if machineOutput() {
+			return printOutput([]searchResultView{})
+		}
+		fmt.Println("No arcs to search.")
+		return nil
+	}
+
+	searches := make([]*arcSearch, len(entries))
+	for i, entry := range entries {
+		s := &arcSearch{entry: entry}
+		s.password, s.err = authManager.GetPassword(entry.ID, entry.Name, true)
+		if errors.Is(s.err, auth.ErrNoPrompt) {
+			s.err = fmt.Errorf("no saved password")
+		}
+		searches[i] = s
+	}
+
+	logf("Searching %d arc(s)\n", len(entries))
+
+	// Progress messages of parallel unlocks would interleave
+	arcManager.SetOutput(io.Discard)
+	defer arcManager.SetOutput(progressOutput())
+
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, runtime.NumCPU())
+	for _, s := range searches {
+		if s.err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func(s *arcSearch) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			arc, key, err := arcManager.Unlock(s.entry.ID, s.password)
+			if err != nil {
+				s.err = err
+				return
+			}
+			s.arc = arc
+			if searchContent {
+				found, unindexed, err := arcManager.SearchContent(s.entry.ID, arc, key, query, 0)
+				s.content, s.unindexed, s.err = filterContentByType(found), unindexed, err
+				return
+			}
+			s.results = filterResultsByType(arcManager.Search(arc, q, 0))
+		}(s)
+	}
+	wg.Wait()
+
+	if searchContent {
+		err = printContentAcrossArcs(searches, query)
+	} else {
+		err = printResultsAcrossArcs(searches, query)
+	}
+	if err != nil {
+		return err
+	}
+
+	var failed []*arcSearch
+	for _, s := range searches {
+		if s.err != nil {
+			failed = append(failed, s)
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(os.Stderr, "\nSkipped %d arc(s):\n", len(failed))
+		for _, s := range failed {
+			fmt.Fprintf(os.Stderr, "  %s: %v\n", s.entry.Name, s.err)
+		}
+	}
+	return nil
+}
+
+// searchTargets returns the arcs named by --arcs, or every arc for --all,
+// sorted by name
+func searchTargets() ([]*arcpkg.ArcEntry, error) {
+	var entries []*arcpkg.ArcEntry
+	if searchAll {
+		entries = arcManager.ListArcs()
+	} else {
+		seen := make(map[string]bool)
+		for _, ref := range searchArcs {
+			entry, err := arcManager.FindArc(strings.TrimSpace(ref))
+			if err != nil {
+				return nil, err
+			}
+			if !seen[entry.ID] {
+				seen[entry.ID] = true
+				entries = append(entries, entry)
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// arcResult is a search hit together with the arc it came from
+type arcResult struct {
+	search *arcSearch
+	result arcpkg.SearchResult
+}
+
+// printResultsAcrossArcs prints the merged name and tag hits of several
+// arcs, best match first
+func printResultsAcrossArcs(searches []*arcSearch, query string) error {
+	var results []arcResult
+	for _, s := range searches {
+		for _, result := range s.results {
+			results = append(results, arcResult{s, result})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].result.Score != results[j].result.Score {
+			return results[i].result.Score > results[j].result.Score
+		}
+		return results[i].search.entry.Name < results[j].search.entry.Name
+	})
+
+	if machineOutput() {
+		views := make([]searchResultView, 0, len(results))
+		for _, r := range results[:searchCount(len(results))] {
+			views = append(views, newSearchResultView(r.search.entry.Name, r.search.arc, r.result.Doc, r.result.Score, ""))
+		}
+		return printOutput(views)
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("No documents found matching: %s\n", query)
+		return nil
+	}
+
+	total := len(results)
+	if searchLimit > 0 && total > searchLimit {
+		results = results[:searchLimit]
+	}
+	if len(results) < total {
+		fmt.Printf("\nShowing %d of %d document(s) matching: %s\n\n", len(results), total, query)
+	} else {
+		fmt.Printf("\nFound %d document(s) matching: %s\n\n", total, query)
+	}
+
+	highlight := term.IsTerminal(int(os.Stdout.Fd()))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ARC\tID\tSCORE\tTYPE\tSIZE\tTAGS\tPATH")
+	fmt.Fprintln(w, "---\t--\t-----\t----\t----\t----\t----")
+
+	for _, r := range results {
+		doc := r.result.Doc
+		tags := r.search.arc.Tags[doc.ID]
+		tagStr := ""
+		if len(tags) > 0 {
+			tagStr = fmt.Sprintf("[%s]", strings.Join(tags, ", "))
+		}
+
+		path := doc.Path()
+		if highlight {
+			path = highlightSpans(path, r.result.Spans)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\t%s\t%s\t%s\n",
+			r.search.entry.Name,
 			doc.ID[:8]+"...",
-			doc.Filename,
+			r.result.Score,
+			arcpkg.ContentTypeOf(doc),
 			formatSize(doc.Size),
 			tagStr,
+			path,
 		)
 	}
 
 	w.Flush()
 	return nil
 }
+
+// printContentAcrossArcs prints the merged full-text hits of several arcs,
+// best match first
+func printContentAcrossArcs(searches []*arcSearch, query string) error {
+	type hit struct {
+		search *arcSearch
+		result arcpkg.ContentResult
+	}
+	var hits []hit
+	for _, s := range searches {
+		for _, result := range s.content {
+			hits = append(hits, hit{s, result})
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].result.Score != hits[j].result.Score {
+			return hits[i].result.Score > hits[j].result.Score
+		}
+		return hits[i].search.entry.Name < hits[j].search.entry.Name
+	})
+
+	if machineOutput() {
+		views := make([]searchResultView, 0, len(hits))
+		for _, h := range hits[:searchCount(len(hits))] {
+			views = append(views, newSearchResultView(h.search.entry.Name, h.search.arc, h.result.Doc, h.result.Score, h.result.Snippet))
+		}
+		if err := printOutput(views); err != nil {
+			return err
+		}
+		for _, s := range searches {
+			if s.unindexed > 0 {
+				logf("Note: %d document(s) in %s are not in the search index, run 'arc reindex %s' to include them\n", s.unindexed, s.entry.Name, s.entry.Name)
+			}
+		}
+		return nil
+	}
+
+	if len(hits) == 0 {
+		fmt.Printf("No documents found containing: %s\n", query)
+	} else {
+
+		total := len(hits)
+		if searchLimit > 0 && total > searchLimit {
+			hits = hits[:searchLimit]
+		}
+		if len(hits) < total {
+			fmt.Printf("\nShowing %d of %d document(s) containing: %s\n", len(hits), total, query)
+		} else {
+			fmt.Printf("\nFound %d document(s) containing: %s\n", total, query)
+		}
+
+		highlight := term.IsTerminal(int(os.Stdout.Fd()))
+		for _, h := range hits {
+			snippet := h.result.Snippet
+			if highlight {
+				snippet = highlightSpans(snippet, h.result.Spans)
+			}
+			fmt.Printf("\n%s:/%s  (%s, score %.2f)\n", h.search.entry.Name, h.result.Doc.Path(), h.result.Doc.ID[:8], h.result.Score)
+			fmt.Printf("    %s\n", snippet)
+		}
+	}
+
+	for _, s := range searches {
+		if s.unindexed > 0 {
+			fmt.Printf("\nNote: %d document(s) in %s are not in the search index, run 'arc reindex %s' to include them\n", s.unindexed, s.entry.Name, s.entry.Name)
+		}
+	}
+	return nil
+}
+
+// highlightSpans wraps the matched byte ranges of s in bold
+func highlightSpans(s string, spans [][2]int) string {
+	var b strings.Builder
+	last := 0
+	for _, span := range spans {
+		b.WriteString(s[last:span[0]])
+		b.WriteString("\x1b[1;33m")
+		b.WriteString(s[span[0]:span[1]])
+		b.WriteString("\x1b[0m")
+		last = span[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/spf13/cobra"
+)
+
+var (
+	splitInto   string
+	splitTags   []string
+	splitSearch string
+	splitQuery  string
+	splitWhere  []string
+)
+
+var splitCmd = &cobra.Command{
+	Use:   "split <arc> [doc...] --into <new-arc>",
+	Short: "Move part of an arc into a separate arc",
+	Long: `Move the selected documents into another arc, which is created with its
+own password unless it already exists. Select documents by name or with
+--tag, --search, --query and --where. Documents are removed from the source only after
+all of them are stored in the target.
+
+  arc split company --tag hr --into hr
+
+If the split is interrupted, run the same command again to resume it.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runSplit,
+}
+
+func init() {
+	rootCmd.AddCommand(splitCmd)
+	splitCmd.Flags().StringVar(&splitInto, "into", "", "Arc to move the documents into (created if it does not exist)")
+	splitCmd.Flags().StringArrayVar(&splitTags, "tag", nil, "Select documents with this tag (repeatable)")
+	splitCmd.Flags().StringVar(&splitSearch, "search", "", "Select documents whose path matches this search")
+	splitCmd.Flags().StringVar(&splitQuery, "query", "", "Select documents matching this query, e.g. 'tag:hr OR folder:hr'")
+	splitCmd.Flags().StringArrayVar(&splitWhere, "where", nil, "Select documents whose property matches, e.g. dept=hr (repeatable)")
+	splitCmd.MarkFlagRequired("into")
+	addSecurityFlags(splitCmd)
+}
+
+func runSplit(cmd *cobra.Command, args []string) error {
+	docRefs := args[1:]
+	if len(docRefs) == 0 && len(splitTags) == 0 && splitSearch == "" && splitQuery == "" && len(splitWhere) == 0 {
+		return fmt.Errorf("name the documents to split off or select them with --tag, --search, --query or --where")
+	}
+
+	queries, err := parseQueries(splitSearch, splitQuery)
+	if err != nil {
+		return err
+	}
+
+	var filters []*arcpkg.PropertyFilter
+	for _, expr := range splitWhere {
+		filter, err := arcpkg.ParseWhere(expr)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, filter)
+	}
+
+	src, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+
+	docs, err := selectDocuments(src.Arc, docRefs, splitTags, queries, filters)
+	if err != nil {
+		return err
+	}
+
+	if _, err := arcManager.FindArc(splitInto); err != nil && len(docs) == 0 {
+		fmt.Println("No documents selected")
+		return nil
+	}
+
+	dst, err := openOrCreateHandle(splitInto)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 && dst.Arc.Journal == nil {
+		fmt.Println("No documents selected")
+		return nil
+	}
+
+	docIDs := make([]string, len(docs))
+	for i, doc := range docs {
+		docIDs[i] = doc.ID
+	}
+
+	stats, err := arcManager.Split(src, dst, docIDs)
+	if stats != nil {
+		printReorgStats(stats)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nSplit %d documents from %s into %s\n", stats.Copied+stats.Skipped, src.Arc.Name, dst.Arc.Name)
+	return nil
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusFolder  string
+	statusVerbose bool
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status <arc-name-or-id> <local-dir>",
+	Short: "Compare a local folder with an arc",
+	Long: `Hash every file below a local folder and report whether it is archived.
+Files are compared with the path arc add --recursive gives them, below the
+folder set with --to:
+
+  archived   the same content is stored at the same path
+  elsewhere  the same content is stored under another path
+  changed    the path is stored, but the file changed since
+  new        neither the path nor the content is stored
+
+The command fails when any file is changed or new, so it can confirm that a
+folder is fully backed up:
+
+  arc status backups ~/Documents --to laptop && echo "safe to wipe"`,
+	Args: cobra.ExactArgs(2),
+	RunE: runStatus,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().StringVar(&statusFolder, "to", "", "Folder inside the arc the local folder was added into")
+	statusCmd.Flags().BoolVarP(&statusVerbose, "verbose", "v", false, "List archived files too")
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	dir := args[1]
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to access directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", dir)
+	}
+
+	h, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+
+	statuses, err := arcManager.Status(h.Arc, dir, statusFolder)
+	if err != nil {
+		return err
+	}
+
+	byState := make(map[string][]arcpkg.FileStatus)
+	for _, s := range statuses {
+		byState[s.State] = append(byState[s.State], s)
+	}
+
+	if statusVerbose && len(byState[arcpkg.FileArchived]) > 0 {
+		fmt.Println("\nArchived:")
+		for _, s := range byState[arcpkg.FileArchived] {
+			fmt.Printf("	%s\n", s.Path)
+		}
+	}
+	if len(byState[arcpkg.FileElsewhere]) > 0 {
+		fmt.Println("\nArchived under another path:")
+		for _, s := range byState[arcpkg.FileElsewhere] {
+			fmt.Printf("	%s -> /%s\n", s.Path, s.Doc.Path())
+		}
+	}
+	if len(byState[arcpkg.FileChanged]) > 0 {
+		fmt.Println("\nChanged since archiving:")
+		for _, s := range byState[arcpkg.FileChanged] {
+			fmt.Printf("	%s\n", s.Path)
+		}
+	}
+	if len(byState[arcpkg.FileNew]) > 0 {
+		fmt.Println("\nNot archived:")
+		for _, s := range byState[arcpkg.FileNew] {
+			fmt.Printf("	%s\n", s.Path)
+		}
+	}
+
+	archived := len(byState[arcpkg.FileArchived]) + len(byState[arcpkg.FileElsewhere])
+	pending := len(byState[arcpkg.FileChanged]) + len(byState[arcpkg.FileNew])
+	fmt.Printf("\n%d file(s): %d archived, %d changed, %d new\n",
+		len(statuses), archived, len(byState[arcpkg.FileChanged]), len(byState[arcpkg.FileNew]))
+
+	if pending > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d file(s) in %s are not archived", pending, dir)
+	}
+	fmt.Printf("Everything in %s is archived in %s\n", dir, h.Arc.Name)
+	return nil
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncFolder  string
+	syncInclude []string
+	syncExclude []string
+	syncDeleted string
+	syncDryRun  bool
+)
+
+var syncDirCmd = &cobra.Command{
+	Use:   "sync-dir <arc-name-or-id> <local-dir>",
+	Short: "Mirror a local folder into an arc",
+	Long: `Mirror a local folder into an arc, one way. New files are added, changed
+files are updated in place (keeping their ID, tags and properties) and
+unchanged files are skipped, so running it again only stores what changed.
+Files keep their path relative to the folder, below --to.
+
+A file whose size and modification time match the last sync is not read
+again; otherwise it is hashed and only updated when its content differs.
+
+--include and --exclude take globs matched against the relative path and
+each of its elements: * and ? stay within one element and ** crosses them.
+--deleted decides what happens to documents whose file is gone: ignore
+(default), mark (tag them sync/deleted) or remove.
+
+  arc sync-dir vault ~/Documents/tax --to tax --exclude '*.tmp' --exclude .git
+  arc sync-dir vault ~/notes --include '**/*.md' --deleted mark --dry-run`,
+	Args: cobra.ExactArgs(2),
+	RunE: runSyncDir,
+}
+
+func init() {
+	rootCmd.AddCommand(syncDirCmd)
+	syncDirCmd.Flags().StringVar(&syncFolder, "to", "", "Folder inside the arc to mirror into")
+	syncDirCmd.Flags().StringArrayVar(&syncInclude, "include", nil, "Only sync files matching this glob (repeatable)")
+	syncDirCmd.Flags().StringArrayVar(&syncExclude, "exclude", nil, "Skip files and folders matching this glob (repeatable)")
+	syncDirCmd.Flags().StringVar(&syncDeleted, "deleted", arcpkg.SyncDeletedIgnore, "Documents whose file is gone: ignore, mark or remove")
+	syncDirCmd.Flags().BoolVar(&syncDryRun, "dry-run", false, "Show what would change without changing anything")
+}
+
+func runSyncDir(cmd *cobra.Command, args []string) error {
+	dir := args[1]
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to access directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", dir)
+	}
+
+	switch syncDeleted {
+	case arcpkg.SyncDeletedIgnore, arcpkg.SyncDeletedMark, arcpkg.SyncDeletedRemove:
+	default:
+		return fmt.Errorf("invalid --deleted value: %s (use ignore, mark or remove)", syncDeleted)
+	}
+
+	h, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+
+	// The itemized changes below replace the per-document progress messages
+	arcManager.SetOutput(io.Discard)
+	defer arcManager.SetOutput(progressOutput())
+
+	stats, err := arcManager.SyncDirectory(h.ID, h.Arc, h.Key, dir, arcpkg.SyncOptions{
+		Folder:  syncFolder,
+		Include: syncInclude,
+		Exclude: syncExclude,
+		Deleted: syncDeleted,
+		DryRun:  syncDryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(stats.Changes) > 0 {
+		fmt.Println()
+	}
+	for _, change := range stats.Changes {
+		if change.Err != nil {
+			fmt.Printf("	%-7s /%s  failed: %v\n", change.Action, change.Path, change.Err)
+		} else {
+			fmt.Printf("	%-7s /%s  (%s)\n", change.Action, change.Path, formatSize(change.Size))
+		}
+	}
+
+	verb := "Synced"
+	if syncDryRun {
+		verb = "Dry run"
+	}
+	fmt.Printf("\n%s %s into %s: %d added, %d updated, %d unchanged",
+		verb, dir, h.Arc.Name,
+		stats.Count(arcpkg.SyncAdd), stats.Count(arcpkg.SyncUpdate), stats.Unchanged)
+	switch syncDeleted {
+	case arcpkg.SyncDeletedMark:
+		fmt.Printf(", %d marked deleted", stats.Count(arcpkg.SyncMark))
+	case arcpkg.SyncDeletedRemove:
+		fmt.Printf(", %d removed", stats.Count(arcpkg.SyncRemove))
+	}
+	fmt.Println()
+
+	if stats.Failed > 0 {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d file(s) could not be synced", stats.Failed)
+	}
+	return nil
+}
@@ -2,14 +2,13 @@ package cmd
 
 import (
 	"fmt"
-	"syscall"
 
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
 	"github.com/spf13/cobra"
-	"golang.org/x/term"
 )
 
 var tagCmd = &cobra.Command{
-	Use:   "tag <arc-name-or-id> <doc-id> <tag1> [tag2...]",
+	Use:   "tag <arc-name-or-id> <doc> <tag1> [tag2...]",
 	Short: "Add tags to a document",
 	Args:  cobra.MinimumNArgs(3),
 	RunE:  runTag,
@@ -21,7 +20,7 @@ func init() {
 
 func runTag(cmd *cobra.Command, args []string) error {
 	arcNameOrID := args[0]
-	docID := args[1]
+	docRef := args[1]
 	tags := args[2:]
 
 	entry, err := arcManager.FindArc(arcNameOrID)
@@ -29,26 +28,26 @@ func runTag(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	fmt.Printf("Adding tags to document in arc: %s\n", entry.Name)
-	fmt.Print("Enter password: ")
-	passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
+	password, err := authManager.GetPassword(entry.ID, entry.Name, true)
 	if err != nil {
-		return fmt.Errorf("failed to read password: %w", err)
+		return err
 	}
-	fmt.Println()
-
-	password := string(passwordBytes)
 
 	arc, key, err := arcManager.Unlock(entry.ID, password)
 	if err != nil {
 		return err
 	}
 
-	if err := arcManager.AddTags(entry.ID, arc, key, docID, tags); err != nil {
+	doc, err := arcManager.FindDocument(arc, docRef)
+	if err != nil {
+		return err
+	}
+
+	if err := arcManager.AddTags(entry.ID, arc, key, doc.ID, tags); err != nil {
 		return err
 	}
 
-	fmt.Printf("Tags added: %v\n", tags)
+	fmt.Printf("Tags added: %v\n", arcpkg.NormalizeTags(tags))
 	return nil
 }
 
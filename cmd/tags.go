@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/spf13/cobra"
+)
+
+var tagsTree bool
+
+var tagsCmd = &cobra.Command{
+	Use:   "tags <arc-name-or-id>",
+	Short: "List tags with document counts, or rename and merge them",
+	Long: `List every tag in an arc with the number of documents carrying it.
+
+Tags are stored trimmed and lowercase. A / makes them hierarchical, as in
+finance/taxes/2024: filtering on finance includes its descendants, --tree
+shows the hierarchy with counts rolled up to each parent, and renaming a
+parent renames its children too. Rename a tag on all documents with
+'arc tags rename', or fold a misspelled tag into the right one with
+'arc tags merge':
+
+  arc tags rename work-docs invoices billing
+  arc tags merge work-docs finace finance`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTags,
+}
+
+var tagsRenameCmd = &cobra.Command{
+	Use:   "rename <arc-name-or-id> <old> <new>",
+	Short: "Rename a tag on every document",
+	Args:  cobra.ExactArgs(3),
+	RunE:  runTagsRename,
+}
+
+var tagsMergeCmd = &cobra.Command{
+	Use:   "merge <arc-name-or-id> <from> <into>",
+	Short: "Replace one tag with another on every document",
+	Args:  cobra.ExactArgs(3),
+	RunE:  runTagsMerge,
+}
+
+func init() {
+	rootCmd.AddCommand(tagsCmd)
+	tagsCmd.AddCommand(tagsRenameCmd)
+	tagsCmd.AddCommand(tagsMergeCmd)
+
+	tagsCmd.Flags().BoolVar(&tagsTree, "tree", false, "Show hierarchical tags as a tree with rolled-up counts")
+}
+
+func runTags(cmd *cobra.Command, args []string) error {
+	h, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+
+	tags := arcManager.ListTags(h.Arc)
+
+	if machineOutput() {
+		if tagsTree {
+			nodes := tagNodeViews(arcManager.TagTree(h.Arc))
+			if outputFormat == outputCSV {
+				return printOutput(flattenTagNodes(nodes))
+			}
+			return printOutput(nodes)
+		}
+		views := make([]tagView, len(tags))
+		for i, tag := range tags {
+			views[i] = tagView{Tag: tag.Tag, Count: tag.Count}
+		}
+		return printOutput(views)
+	}
+
+	if len(tags) == 0 {
+		fmt.Println("No tags in this arc.")
+		return nil
+	}
+
+	if tagsTree {
+		printTagTree(arcManager.TagTree(h.Arc), "")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TAG\tDOCUMENTS")
+	fmt.Fprintln(w, "---\t---------")
+	for _, tag := range tags {
+		fmt.Fprintf(w, "%s\t%d\n", tag.Tag, tag.Count)
+	}
+	w.Flush()
+	return nil
+}
+
+func runTagsRename(cmd *cobra.Command, args []string) error {
+	h, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+
+	n, err := arcManager.RenameTag(h.ID, h.Arc, h.Key, args[1], args[2])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Renamed tag %s to %s on %d document(s)\n", arcpkg.NormalizeTag(args[1]), arcpkg.NormalizeTag(args[2]), n)
+	return nil
+}
+
+func runTagsMerge(cmd *cobra.Command, args []string) error {
+	h, err := unlockHandle(args[0])
+	if err != nil {
+		return err
+	}
+
+	n, err := arcManager.MergeTags(h.ID, h.Arc, h.Key, args[1], args[2])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Merged tag %s into %s on %d document(s)\n", arcpkg.NormalizeTag(args[1]), arcpkg.NormalizeTag(args[2]), n)
+	return nil
+}
+
+// printTagTree renders tag nodes and their children. The count is every
+// document under the tag; documents tagged with it directly are shown too
+// when they differ.
+func printTagTree(nodes []*arcpkg.TagNode, indent string) {
+	for i, node := range nodes {
+		branch, next := treeBranch(i == len(nodes)-1)
+		if node.Count != node.Total {
+			fmt.Printf("%s%s%s  (%d, %d direct)\n", indent, branch, node.Name, node.Total, node.Count)
+		} else {
+			fmt.Printf("%s%s%s  (%d)\n", indent, branch, node.Name, node.Total)
+		}
+		printTagTree(node.Children, indent+next)
+	}
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"fmt"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var (
+	transferMove       bool
+	transferTags       []string
+	transferSearch     string
+	transferQuery      string
+	transferWhere      []string
+	transferFolder     string
+	transferCollection string
+)
+
+var transferCmd = &cobra.Command{
+	Use:   "transfer <src-arc> [doc...] <dst-arc>",
+	Short: "Copy or move documents to another arc",
+	Long: `Copy documents to another arc, re-encrypting them in memory under the
+destination arc's key. Tags, properties and folders are kept.
+
+Documents can be named directly or selected with --tag, --search, --query,
+--collection and --where; with several selectors a document must match all of them. With --move the
+documents are removed from the source once the destination has stored them.
+
+  arc transfer drafts contract.pdf legal --move
+  arc transfer inbox legal --tag contract --to 2024
+  arc transfer inbox legal --query 'tag:contract added>=2024-01-01'`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: runTransfer,
+}
+
+func init() {
+	rootCmd.AddCommand(transferCmd)
+	transferCmd.Flags().BoolVar(&transferMove, "move", false, "Remove the documents from the source arc afterwards")
+	transferCmd.Flags().StringArrayVar(&transferTags, "tag", nil, "Select documents with this tag (repeatable)")
+	transferCmd.Flags().StringVar(&transferSearch, "search", "", "Select documents whose path matches this search")
+	transferCmd.Flags().StringVar(&transferQuery, "query", "", "Select documents matching this query, e.g. 'tag:legal AND name:*.pdf'")
+	transferCmd.Flags().StringVar(&transferCollection, "collection", "", "Select the documents of this saved collection")
+	transferCmd.Flags().StringArrayVar(&transferWhere, "where", nil, "Select documents whose property matches, e.g. amount>1000 (repeatable)")
+	transferCmd.Flags().StringVar(&transferFolder, "to", "", "Folder in the destination arc (default: keep each document's folder)")
+}
+
+func runTransfer(cmd *cobra.Command, args []string) error {
+	srcRef := args[0]
+	dstRef := args[len(args)-1]
+	docRefs := args[1 : len(args)-1]
+
+	if len(docRefs) == 0 && len(transferTags) == 0 && transferSearch == "" && transferQuery == "" && transferCollection == "" && len(transferWhere) == 0 {
+		return fmt.Errorf("name the documents to transfer or select them with --tag, --search, --query, --collection or --where")
+	}
+
+	queries, err := parseQueries(transferSearch, transferQuery)
+	if err != nil {
+		return err
+	}
+
+	var filters []*arcpkg.PropertyFilter
+	for _, expr := range transferWhere {
+		filter, err := arcpkg.ParseWhere(expr)
+		if err != nil {
+			return err
+		}
+		filters = append(filters, filter)
+	}
+
+	src, err := unlockHandle(srcRef)
+	if err != nil {
+		return err
+	}
+	dst, err := unlockHandle(dstRef)
+	if err != nil {
+		return err
+	}
+
+	collections, err := collectionQueries(src.Arc, transferCollection)
+	if err != nil {
+		return err
+	}
+
+	docs, err := selectDocuments(src.Arc, docRefs, transferTags, append(queries, collections...), filters)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 {
+		fmt.Println("No documents selected")
+		return nil
+	}
+
+	docIDs := make([]string, len(docs))
+	for i, doc := range docs {
+		docIDs[i] = doc.ID
+	}
+
+	opts := arcpkg.TransferOptions{Move: transferMove}
+	if cmd.Flags().Changed("to") {
+		opts.Folder = &transferFolder
+	}
+
+	copies, err := arcManager.TransferDocuments(src, dst, docIDs, opts)
+	if err != nil {
+		return err
+	}
+
+	verb := "Copied"
+	if transferMove {
+		verb = "Moved"
+	}
+	fmt.Printf("\n%s %d document(s) from %s to %s\n", verb, len(copies), src.Arc.Name, dst.Arc.Name)
+	for _, doc := range copies {
+		fmt.Printf("	/%s\n", doc.Path())
+	}
+	return nil
+}
+
+// unlockHandle unlocks an arc by name or ID
+func unlockHandle(arcNameOrID string) (*arcpkg.ArcHandle, error) {
+	entry, err := arcManager.FindArc(arcNameOrID)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := authManager.GetPassword(entry.ID, entry.Name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	arc, key, err := arcManager.Unlock(entry.ID, password)
+	if err != nil {
+		return nil, err
+	}
+	return &arcpkg.ArcHandle{ID: entry.ID, Arc: arc, Key: key}, nil
+}
+
+// parseQueries parses the non-empty query flags
+func parseQueries(exprs ...string) ([]*arcpkg.Query, error) {
+	var queries []*arcpkg.Query
+	for _, expr := range exprs {
+		if expr == "" {
+			continue
+		}
+		q, err := arcpkg.ParseQuery(expr)
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+	}
+	return queries, nil
+}
+
+// selectDocuments resolves named documents and narrows them (or, with no
+// names, the whole arc) to those matching every selector
+func selectDocuments(arc *models.Arc, refs, tags []string, queries []*arcpkg.Query, filters []*arcpkg.PropertyFilter) ([]*models.Document, error) {
+	var docs []*models.Document
+	if len(refs) > 0 {
+		seen := make(map[string]bool)
+		for _, ref := range refs {
+			doc, err := arcManager.FindDocument(arc, ref)
+			if err != nil {
+				return nil, err
+			}
+			if !seen[doc.ID] {
+				seen[doc.ID] = true
+				docs = append(docs, doc)
+			}
+		}
+	} else {
+		docs = arcManager.ListDocuments(arc)
+	}
+
+	var selected []*models.Document
+	for _, doc := range filterDocuments(docs, "", filters) {
+		if !matchesAll(arc, doc, queries) {
+			continue
+		}
+		if !arcpkg.HasAllTags(arc.Tags[doc.ID], tags) {
+			continue
+		}
+		selected = append(selected, doc)
+	}
+	return selected, nil
+}
+
+// matchesAll reports whether doc matches every query
+func matchesAll(arc *models.Arc, doc *models.Document, queries []*arcpkg.Query) bool {
+	for _, q := range queries {
+		if !q.Match(arc, doc) {
+			return false
+		}
+	}
+	return true
+}
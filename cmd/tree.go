@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+var treeCmd = &cobra.Command{
+	Use:   "tree <arc-name-or-id> [folder]",
+	Short: "Show the folder tree of an arc",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE:  runTree,
+}
+
+func init() {
+	rootCmd.AddCommand(treeCmd)
+}
+
+func runTree(cmd *cobra.Command, args []string) error {
+	arcNameOrID := args[0]
+	folder := ""
+	if len(args) > 1 {
+		folder = args[1]
+	}
+
+	folder, err := arcpkg.CleanFolder(folder)
+	if err != nil {
+		return err
+	}
+
+	entry, err := arcManager.FindArc(arcNameOrID)
+	if err != nil {
+		return err
+	}
+
+	password, err := authManager.GetPassword(entry.ID, entry.Name, true)
+	if err != nil {
+		return err
+	}
+
+	arc, _, err := arcManager.Unlock(entry.ID, password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%s:/%s\n", arc.Name, folder)
+	printTree(arc, folder, "")
+	return nil
+}
+
+// printTree renders the subfolders and documents of folder, recursively
+func printTree(arc *models.Arc, folder, indent string) {
+	folders, docs := arcManager.ListFolder(arc, folder)
+	total := len(folders) + len(docs)
+
+	for i, name := range folders {
+		branch, next := treeBranch(i == total-1)
+		fmt.Printf("%s%s%s/\n", indent, branch, name)
+		printTree(arc, strings.TrimPrefix(folder+"/"+name, "/"), indent+next)
+	}
+
+	for i, doc := range docs {
+		branch, _ := treeBranch(len(folders)+i == total-1)
+		fmt.Printf("%s%s%s  (%s)\n", indent, branch, doc.Filename, formatSize(doc.Size))
+	}
+}
+
+// treeBranch returns the connector for an entry and the indent for its children
+func treeBranch(last bool) (string, string) {
+	if last {
+		return "└── ", "    "
+	}
+	return "├── ", "│   "
+}
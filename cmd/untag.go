@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+
+	arcpkg "github.com/ViniTamanhao/arcadio/internal/arc"
+	"github.com/spf13/cobra"
+)
+
+var untagCmd = &cobra.Command{
+	Use:   "untag <arc-name-or-id> <doc> <tag1> [tag2...]",
+	Short: "Remove tags from a document",
+	Args:  cobra.MinimumNArgs(3),
+	RunE:  runUntag,
+}
+
+func init() {
+	rootCmd.AddCommand(untagCmd)
+}
+
+func runUntag(cmd *cobra.Command, args []string) error {
+	entry, arc, key, doc, err := unlockDocument(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	var tags, missing []string
+	for _, tag := range arcpkg.NormalizeTags(args[2:]) {
+		// Exact tags only: untagging finance leaves finance/taxes alone
+		if slices.Contains(arcpkg.NormalizeTags(arc.Tags[doc.ID]), tag) {
+			tags = append(tags, tag)
+		} else {
+			missing = append(missing, tag)
+		}
+	}
+	if len(tags) == 0 {
+		return fmt.Errorf("/%s has none of the tags %v", doc.Path(), missing)
+	}
+
+	if err := arcManager.RemoveTags(entry.ID, arc, key, doc.ID, tags); err != nil {
+		return err
+	}
+
+	fmt.Printf("Tags removed from /%s: %v\n", doc.Path(), tags)
+	if len(missing) > 0 {
+		fmt.Printf("Not tagged: %v\n", missing)
+	}
+	return nil
+}
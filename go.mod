@@ -5,8 +5,11 @@ go 1.25.4
 require (
 	github.com/google/uuid v1.6.0
 	github.com/spf13/cobra v1.10.1
+	github.com/zalando/go-keyring v0.2.6
 	golang.org/x/crypto v0.45.0
 	golang.org/x/term v0.37.0
+	golang.org/x/text v0.38.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -15,6 +18,5 @@ require (
 	github.com/godbus/dbus/v5 v5.1.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
-	github.com/zalando/go-keyring v0.2.6 // indirect
 	golang.org/x/sys v0.38.0 // indirect
 )
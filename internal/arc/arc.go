@@ -2,30 +2,85 @@
 package arc
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/ViniTamanhao/arcadio/internal/crypto"
+	"github.com/ViniTamanhao/arcadio/internal/storage"
 	"github.com/ViniTamanhao/arcadio/pkg/models"
 	"github.com/google/uuid"
 )
 
 type Manager struct {
-	baseDir string
+	store    storage.Storage
 	registry *Registry
+
+	mu      sync.Mutex
+	meta    map[string]*metaState      // arc ID -> stored metadata state
+	batches map[string]map[string]bool // arc ID -> documents changed in the open batch
+
+	indexPending map[string][]*indexRecord // arc ID -> index entries held by the open batch
+	packPending  map[string]*pendingPack   // arc ID -> pack being filled on stores without appends
+	tornLogs     map[string]bool           // log keys read with a torn tail, cut before the next append
+
+	log io.Writer // progress messages, kept off stdout so it can carry data
 }
 
-// NewManager creates a NewManager instance
+// NewManager creates a NewManager instance for baseDir, which is either a
+// local directory or an s3://bucket/prefix URL
 func NewManager(baseDir string) (*Manager, error) {
-	registry, err := NewRegistry(baseDir)
+	store, err := storage.Open(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+
+	// Locally the registry lives next to the arcs directory; remote stores
+	// keep it at the root of the prefix
+	registryStore := store
+	if !storage.IsRemote(baseDir) {
+		registryStore, err = storage.NewLocal(filepath.Join(baseDir, ".."))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open registry storage: %w", err)
+		}
+	}
+
+	registry, err := NewRegistry(registryStore)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load registry: %w", err)
 	}
 
-	return &Manager{baseDir: baseDir, registry: registry}, nil
+	return NewManagerWithStorage(store, registry), nil
+}
+
+// NewManagerWithStorage creates a Manager over an already opened store
+func NewManagerWithStorage(store storage.Storage, registry *Registry) *Manager {
+	return &Manager{
+		store:    store,
+		registry: registry,
+		meta:     make(map[string]*metaState),
+		batches:  make(map[string]map[string]bool),
+		log:      os.Stderr,
+
+		indexPending: make(map[string][]*indexRecord),
+		packPending:  make(map[string]*pendingPack),
+		tornLogs:     make(map[string]bool),
+	}
+}
+
+// SetOutput redirects progress messages, e.g. to io.Discard
+func (m *Manager) SetOutput(w io.Writer) {
+	m.log = w
+}
+
+// logf writes a progress message
+func (m *Manager) logf(format string, args ...any) {
+	fmt.Fprintf(m.log, format, args...)
 }
 
 // Create creates a new arc
@@ -57,25 +112,15 @@ func (m *Manager) Create(name, password, securityQuestion, securityAnswer string
 		KeyDerivation: "argon2id",
 	}
 
-	arcDir := filepath.Join(m.baseDir, arc.ID)
-	if err := os.MkdirAll(arcDir, 0700); err != nil {
-		return nil, fmt.Errorf("failed to create arc directory: %w", err)
-	}
-
-	docsDir := filepath.Join(arcDir, "documents")
-	if err := os.MkdirAll(docsDir, 0700); err != nil {
-		return nil, fmt.Errorf("failed to create documents directory: %w", err)
-	}
-
-	if err := m.saveSecurityConfig(arcDir, secConfig); err != nil {
+	if err := m.saveSecurityConfig(arc.ID, secConfig); err != nil {
 		return nil, fmt.Errorf("failed to save security confi: %w", err)
 	}
 
-	if err := m.saveArcMetadata(arcDir, arc, key); err != nil {
+	if err := m.saveArcMetadata(arc.ID, arc, key); err != nil {
 		return nil, fmt.Errorf("failed to save arc metadata: %w", err)
 	}
 
-	fmt.Println("Registering arc...")
+	m.logf("Registering arc...\n")
 	if err := m.registry.Register(arc.ID, arc.Name, arc.CreatedAt); err != nil {
 		return nil,fmt.Errorf("failed to register arc: %w", err)
 	}
@@ -83,16 +128,19 @@ func (m *Manager) Create(name, password, securityQuestion, securityAnswer string
 	return arc, nil
 }
 
-// Delete removes an arc completely
-func (m *Manager) Delete(idOrName string) error {
-	entry, err := m.registry.FindArc(idOrName)
+// Delete removes an arc completely. The unlocked arc is needed to check
+// its policy.
+func (m *Manager) Delete(arcID string, arc *models.Arc) error {
+	entry, err := m.registry.FindArc(arcID)
 	if err != nil {
 		return err
 	}
+	if err := checkDeletable(arc); err != nil {
+		return err
+	}
 
-	arcDir := filepath.Join(m.baseDir, entry.ID)
-	if err := os.RemoveAll(arcDir); err != nil {
-		return fmt.Errorf("failed to delete arc directory: %w", err)
+	if err := storage.RemoveAll(m.store, entry.ID+"/"); err != nil {
+		return fmt.Errorf("failed to delete arc objects: %w", err)
 	}
 
 	if err := m.registry.Unregister(entry.ID); err != nil {
@@ -119,56 +167,68 @@ func (m *Manager) Unlock(idOrName, password string) (*models.Arc, []byte, error)
 		return nil, nil, err
 	}
 
-	arcDir := filepath.Join(m.baseDir, entry.ID)
+	return m.open(entry.ID, password)
+}
 
-	fmt.Println("Loading security configuration...")
-	secConfig, err := m.loadSecurityConfig(arcDir)
+// open verifies password against the stored security config and decrypts
+// the metadata of the arc stored under arcID
+func (m *Manager) open(arcID, password string) (*models.Arc, []byte, error) {
+	m.logf("Loading security configuration...\n")
+	secConfig, err := m.loadSecurityConfig(arcID)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to load security config: %w", err)
 	}
 
 	// Derive key from provided password
-	fmt.Println("Deriving encryption key...")
+	m.logf("Deriving encryption key...\n")
 	key := crypto.DeriveKey(password, secConfig.Salt)
 	
 	// Verify password by comparing hashes
-	fmt.Println("Verifying password...")
+	m.logf("Verifying password...\n")
 	passwordHash := crypto.HashAnswer(password)
 	if !bytesEqual(passwordHash, secConfig.PasswordHash) {
 		return nil, nil, fmt.Errorf("invalid password")
 	}
 
 	// Load and decrypt arc metadata
-	fmt.Println("Decrypting arc metadata...")
-	arc, err := m.loadArcMetadata(arcDir, key)
+	m.logf("Decrypting arc metadata...\n")
+	arc, err := m.loadArcMetadata(arcID, key)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to load arc: %w", err)
 	}
 
-	fmt.Println("Arc unlocked successfully")
+	m.logf("Arc unlocked successfully\n")
 	return arc, key, nil
 }
 
-// GetDocumentPath gets the path of a certain document
-func (m *Manager) GetDocumentPath(arcID, docID string) string {
-	return filepath.Join(m.baseDir, arcID, "documents", docID+".bin")
+// securityKey is the storage key of an arc's security config
+func securityKey(arcID string) string {
+	return storage.Join(arcID, "arc.sec")
+}
+
+// metadataKey is the storage key of an arc's encrypted metadata
+func metadataKey(arcID string) string {
+	return storage.Join(arcID, "arc.meta")
+}
+
+// documentKey is the storage key of an encrypted document
+func documentKey(arcID, docID string) string {
+	return storage.Join(arcID, "documents", docID+".bin")
 }
 
 // saveSecurityConfig saves the security config of a certain arc
-func (m *Manager) saveSecurityConfig(arcDir string, config *models.SecurityConfig) error {
+func (m *Manager) saveSecurityConfig(arcID string, config *models.SecurityConfig) error {
 	data, err := json.MarshalIndent(config, "", " ")
 	if err != nil {
 		return err
 	}
 
-	path := filepath.Join(arcDir, "arc.sec")
-	return os.WriteFile(path, data, 0600)
+	return m.store.Put(securityKey(arcID), bytes.NewReader(data))
 }
 
 // loadSecurityConfig loads the security config of a certain arc
-func (m *Manager) loadSecurityConfig(arcDir string) (*models.SecurityConfig, error) {
-	path := filepath.Join(arcDir, "arc.sec")
-	data, err := os.ReadFile(path)
+func (m *Manager) loadSecurityConfig(arcID string) (*models.SecurityConfig, error) {
+	data, err := storage.ReadAll(m.store, securityKey(arcID))
 	if err != nil {
 		return nil, err
 	}
@@ -181,48 +241,89 @@ func (m *Manager) loadSecurityConfig(arcDir string) (*models.SecurityConfig, err
 	return &config, nil
 }
 
-// saveArcMetadata saves the metadata of a certain arc
-func (m *Manager) saveArcMetadata(arcDir string, arc *models.Arc, key []byte) error {
-	data, err := json.MarshalIndent(arc, "", " ")
-	if err != nil {
+// saveArcMetadata writes a full metadata snapshot and starts a new log
+// generation, folding in every change logged so far
+func (m *Manager) saveArcMetadata(arcID string, arc *models.Arc, key []byte) error {
+	if err := m.flushPack(arcID); err != nil {
 		return err
 	}
 
-	encrypted, err := crypto.Encrypt(key, data)
+	m.mu.Lock()
+	generation := int64(1)
+	if state, ok := m.meta[arcID]; ok {
+		generation = state.generation + 1
+	}
+	m.mu.Unlock()
+
+	encrypted, err := encodeSnapshot(arc, generation, key)
 	if err != nil {
 		return err
 	}
 
-	path := filepath.Join(arcDir, "arc.meta")
-	return os.WriteFile(path, encrypted, 0600)
+	if err := m.store.Put(metadataKey(arcID), bytes.NewReader(encrypted)); err != nil {
+		return err
+	}
+
+	// Records of older generations are ignored on load, so a failure here
+	// only leaves a stale log behind
+	if err := m.rewriteLog(logKey(arcID), nil); err != nil {
+		return fmt.Errorf("failed to reset metadata log: %w", err)
+	}
+
+	m.mu.Lock()
+	m.meta[arcID] = &metaState{generation: generation, snapshotSize: int64(len(encrypted))}
+	m.mu.Unlock()
+	return nil
 }
 
-// loadArcMetadata loads the metadata of a certain arc
-func (m *Manager) loadArcMetadata(arcDir string, key []byte) (*models.Arc, error) {
-	path := filepath.Join(arcDir, "arc.meta")
-	encrypted, err := os.ReadFile(path)
+// loadArcMetadata loads the metadata snapshot of a certain arc and replays
+// its log. Snapshots from before the log existed are migrated on the spot.
+func (m *Manager) loadArcMetadata(arcID string, key []byte) (*models.Arc, error) {
+	encrypted, err := storage.ReadAll(m.store, metadataKey(arcID))
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := crypto.Decrypt(key, encrypted)
+	snap, legacy, err := decodeSnapshot(encrypted, key)
 	if err != nil {
 		return nil, err
 	}
+	arc := snap.Arc
+	if arc.Documents == nil {
+		arc.Documents = make(map[string]*models.Document)
+	}
+	if arc.Tags == nil {
+		arc.Tags = make(map[string][]string)
+	}
 
-	var arc models.Arc
-	if err := json.Unmarshal(data, &arc); err != nil {
+	if legacy {
+		m.logf("Migrating arc metadata...\n")
+		if err := m.saveArcMetadata(arcID, arc, key); err != nil {
+			return nil, fmt.Errorf("failed to migrate arc metadata: %w", err)
+		}
+		return arc, nil
+	}
+
+	logSize, err := m.replayLog(arcID, arc, key, snap.Generation)
+	if err != nil {
 		return nil, err
 	}
 
-	return &arc, nil
+	m.mu.Lock()
+	m.meta[arcID] = &metaState{
+		generation:   snap.Generation,
+		snapshotSize: int64(len(encrypted)),
+		logSize:      logSize,
+	}
+	m.mu.Unlock()
+
+	return arc, nil
 }
 
-// Update updates the metadata of an arc
+// Update writes the complete metadata of an arc as a fresh snapshot
 func (m *Manager) Update(arcID string, arc *models.Arc, key []byte) error {
-	arcDir := filepath.Join(m.baseDir, arcID)
 	arc.ModifiedAt = time.Now()
-	return m.saveArcMetadata(arcDir, arc, key)
+	return m.saveArcMetadata(arcID, arc, key)
 }
 
 // bytesEqual compares two bytes
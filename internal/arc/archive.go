@@ -0,0 +1,249 @@
+package arc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ViniTamanhao/arcadio/internal/arcx"
+	"github.com/ViniTamanhao/arcadio/internal/crypto"
+	"github.com/ViniTamanhao/arcadio/internal/storage"
+	"github.com/google/uuid"
+)
+
+// UnpackOptions controls how an .arcx container is imported
+type UnpackOptions struct {
+	Name        string // register under this name instead of the packed one
+	NewID       bool   // assign a fresh arc ID, e.g. when the packed ID already exists
+	Password    string // password of the packed arc, needed for NewID, renaming and re-keying
+	NewPassword string // re-encrypt everything under this password when set
+}
+
+// PackArc streams every object of an arc into an .arcx container. The
+// objects are copied as stored, so no password is needed.
+func (m *Manager) PackArc(idOrName string, w io.Writer) (int, error) {
+	entry, err := m.registry.FindArc(idOrName)
+	if err != nil {
+		return 0, err
+	}
+
+	prefix := entry.ID + "/"
+	keys, err := m.store.List(prefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list arc objects: %w", err)
+	}
+	if len(keys) == 0 {
+		return 0, fmt.Errorf("arc %s has no stored objects", entry.Name)
+	}
+
+	aw, err := arcx.NewWriter(w, arcx.Header{
+		ArcID:      entry.ID,
+		Name:       entry.Name,
+		CreatedAt:  entry.CreatedAt,
+		ExportedAt: time.Now(),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to write container header: %w", err)
+	}
+
+	for _, key := range keys {
+		m.logf("Packing: %s\n", key)
+		if err := m.packObject(aw, key, strings.TrimPrefix(key, prefix)); err != nil {
+			return 0, fmt.Errorf("failed to pack %s: %w", key, err)
+		}
+	}
+
+	if err := aw.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write container index: %w", err)
+	}
+
+	return len(keys), nil
+}
+
+// packObject copies one stored object into the container
+func (m *Manager) packObject(aw *arcx.Writer, key, name string) error {
+	rc, err := m.store.Get(key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return aw.Add(name, rc)
+}
+
+// UnpackArc imports an .arcx container and registers the arc it holds.
+// Nothing is left behind if the container turns out to be corrupt.
+func (m *Manager) UnpackArc(r io.Reader, opts UnpackOptions) (*ArcEntry, error) {
+	ar, err := arcx.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	header := ar.Header()
+
+	if _, err := uuid.Parse(header.ArcID); err != nil {
+		return nil, fmt.Errorf("container has an invalid arc ID: %q", header.ArcID)
+	}
+
+	arcID := header.ArcID
+	if _, exists := m.registry.GetByID(arcID); exists {
+		if !opts.NewID {
+			return nil, fmt.Errorf("an arc with ID %s is already registered (import it with a new ID)", arcID)
+		}
+	}
+	if opts.NewID {
+		arcID = uuid.New().String()
+	}
+
+	name := header.Name
+	if opts.Name != "" {
+		name = opts.Name
+	}
+	if _, exists := m.registry.GetByName(name); exists {
+		return nil, fmt.Errorf("an arc named %q already exists (choose another name)", name)
+	}
+
+	if (opts.NewID || opts.NewPassword != "" || name != header.Name) && opts.Password == "" {
+		return nil, fmt.Errorf("the arc password is required to change its ID, key or name")
+	}
+
+	// Objects left under the ID by an unregistered arc are not ours to
+	// overwrite, and the cleanup below would delete them
+	prefix := arcID + "/"
+	existing, err := m.store.List(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check storage for arc %s: %w", arcID, err)
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("storage already holds objects for arc ID %s that are not registered (import it with a new ID)", arcID)
+	}
+	cleanup := func(cause error) (*ArcEntry, error) {
+		storage.RemoveAll(m.store, prefix)
+		return nil, cause
+	}
+
+	count := 0
+	for {
+		name, content, err := ar.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return cleanup(err)
+		}
+
+		key := prefix + name
+		m.logf("Unpacking: %s\n", key)
+		if err := m.store.Put(key, content); err != nil {
+			return cleanup(fmt.Errorf("failed to store %s: %w", key, err))
+		}
+		count++
+	}
+	m.logf("Verified %d objects\n", count)
+
+	if opts.Password != "" {
+		arc, key, err := m.open(arcID, opts.Password)
+		if err != nil {
+			return cleanup(err)
+		}
+
+		if arc.ID != arcID || arc.Name != name {
+			arc.ID = arcID
+			arc.Name = name
+			if err := m.saveArcMetadata(arcID, arc, key); err != nil {
+				return cleanup(fmt.Errorf("failed to update arc metadata: %w", err))
+			}
+		}
+
+		if opts.NewPassword != "" {
+			if err := m.rekey(arcID, key, opts.NewPassword); err != nil {
+				return cleanup(fmt.Errorf("failed to re-key arc: %w", err))
+			}
+		}
+	}
+
+	if err := m.registry.Register(arcID, name, header.CreatedAt); err != nil {
+		return cleanup(fmt.Errorf("failed to register arc: %w", err))
+	}
+
+	entry, _ := m.registry.GetByID(arcID)
+	return entry, nil
+}
+
+// rekey re-encrypts the metadata and every document of an arc under a key
+// derived from newPassword. The security config is written last so the arc
+// only switches passwords once everything else has been rewritten.
+func (m *Manager) rekey(arcID string, oldKey []byte, newPassword string) error {
+	secConfig, err := m.loadSecurityConfig(arcID)
+	if err != nil {
+		return fmt.Errorf("failed to load security config: %w", err)
+	}
+
+	arc, err := m.loadArcMetadata(arcID, oldKey)
+	if err != nil {
+		return fmt.Errorf("failed to load arc: %w", err)
+	}
+
+	salt, err := crypto.GenerateSalt()
+	if err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	m.logf("Deriving new encryption key...\n")
+	newKey := crypto.DeriveKey(newPassword, salt)
+
+	// Packs written under the old key are dropped once everything is rewritten
+	var oldPacks []string
+	if arc.Packs != nil {
+		for packID := range arc.Packs.Sizes {
+			oldPacks = append(oldPacks, packID)
+		}
+		arc.Packs.Active = ""
+	}
+
+	for docID, doc := range arc.Documents {
+		m.logf("Re-encrypting: %s\n", doc.Filename)
+
+		encrypted, err := m.readBlob(arcID, arc, docID)
+		if err != nil {
+			return fmt.Errorf("failed to read document %s: %w", docID, err)
+		}
+		data, err := crypto.Decrypt(oldKey, encrypted)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt document %s: %w", docID, err)
+		}
+		encrypted, err = crypto.Encrypt(newKey, data)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt document %s: %w", docID, err)
+		}
+		if err := m.writeBlob(arcID, arc, docID, encrypted); err != nil {
+			return fmt.Errorf("failed to save document %s: %w", docID, err)
+		}
+	}
+	for _, packID := range oldPacks {
+		delete(arc.Packs.Sizes, packID)
+	}
+
+	if err := m.saveArcMetadata(arcID, arc, newKey); err != nil {
+		return fmt.Errorf("failed to save arc metadata: %w", err)
+	}
+	if err := m.reencryptFrames(purgeLogKey(arcID), oldKey, newKey); err != nil {
+		return fmt.Errorf("failed to re-encrypt purge log: %w", err)
+	}
+	if err := m.reencryptFrames(indexKey(arcID), oldKey, newKey); err != nil {
+		return fmt.Errorf("failed to re-encrypt search index: %w", err)
+	}
+
+	secConfig.Salt = salt
+	secConfig.PasswordHash = crypto.HashAnswer(newPassword)
+	if err := m.saveSecurityConfig(arcID, secConfig); err != nil {
+		return err
+	}
+
+	for _, packID := range oldPacks {
+		if err := m.store.Delete(packKey(arcID, packID)); err != nil {
+			return fmt.Errorf("failed to delete pack %s: %w", packID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package arc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ViniTamanhao/arcadio/internal/storage"
+)
+
+func TestUnpackRename(t *testing.T) {
+	m := reopenManager(t, storage.NewMemory())
+	if _, err := m.Create("original", "password1", "q", "a"); err != nil {
+		t.Fatal(err)
+	}
+	var packed bytes.Buffer
+	if _, err := m.PackArc("original", &packed); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     UnpackOptions
+		wantName string
+		wantErr  string
+	}{
+		{"same name", UnpackOptions{}, "original", ""},
+		{"rename without password", UnpackOptions{Name: "other"}, "", "password is required"},
+		{"rename", UnpackOptions{Name: "other", Password: "password1"}, "other", ""},
+		{"rename with new ID", UnpackOptions{Name: "other", Password: "password1", NewID: true}, "other", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := reopenManager(t, storage.NewMemory())
+			entry, err := dst.UnpackArc(bytes.NewReader(packed.Bytes()), tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("UnpackArc error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			arc, _, err := dst.Unlock(entry.ID, "password1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.Name != tt.wantName || arc.Name != tt.wantName {
+				t.Errorf("registered as %q with %q in its metadata, want %q", entry.Name, arc.Name, tt.wantName)
+			}
+			if arc.ID != entry.ID {
+				t.Errorf("metadata ID %s, registered as %s", arc.ID, entry.ID)
+			}
+		})
+	}
+}
+
+func TestUnpackOverUnregisteredObjects(t *testing.T) {
+	m := reopenManager(t, storage.NewMemory())
+	arc, err := m.Create("original", "password1", "q", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var packed bytes.Buffer
+	if _, err := m.PackArc("original", &packed); err != nil {
+		t.Fatal(err)
+	}
+
+	// An arc under the same ID whose registration was lost
+	store := storage.NewMemory()
+	stray := arc.ID + "/arc.meta"
+	if err := store.Put(stray, strings.NewReader("someone else's metadata")); err != nil {
+		t.Fatal(err)
+	}
+	dst := reopenManager(t, store)
+	if _, err := dst.UnpackArc(bytes.NewReader(packed.Bytes()), UnpackOptions{}); err == nil {
+		t.Fatal("UnpackArc wrote over unregistered objects")
+	}
+	data, err := storage.ReadAll(store, stray)
+	if err != nil || string(data) != "someone else's metadata" {
+		t.Fatalf("stray object = %q, %v after a refused import", data, err)
+	}
+
+	if _, err := dst.UnpackArc(bytes.NewReader(packed.Bytes()), UnpackOptions{NewID: true, Password: "password1"}); err != nil {
+		t.Fatalf("UnpackArc with a new ID: %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package arc
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/ViniTamanhao/arcadio/internal/crypto"
+	"github.com/ViniTamanhao/arcadio/internal/storage"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// packBlobMax is the largest encrypted document that goes into a pack;
+	// anything bigger is stored as its own object
+	packBlobMax = 1 << 20
+
+	// packTargetSize is the size after which a new pack is started
+	packTargetSize = 64 << 20
+
+	// sealOverhead is what encryption adds to a document (nonce and GCM tag)
+	sealOverhead = crypto.NonceSize + 16
+)
+
+// RepackStats summarizes a repack run
+type RepackStats struct {
+	Packed         int   // documents written into new packs
+	PacksRemoved   int   // old packs deleted
+	LooseRemoved   int   // loose document objects folded into packs
+	BytesReclaimed int64 // dead pack bytes dropped
+}
+
+// packKey is the storage key of a pack file
+func packKey(arcID, packID string) string {
+	return storage.Join(arcID, "packs", packID+".pack")
+}
+
+// writeBlob stores an encrypted document, appending it to the active pack
+// when the arc uses the packed layout and the document is small enough
+func (m *Manager) writeBlob(arcID string, arc *models.Arc, docID string, encrypted []byte) error {
+	if arc.Packs == nil || len(encrypted) > packBlobMax {
+		if err := m.store.Put(documentKey(arcID, docID), bytes.NewReader(encrypted)); err != nil {
+			return err
+		}
+		if arc.Packs != nil {
+			delete(arc.Packs.Index, docID)
+		}
+		return nil
+	}
+
+	if !storage.CanAppend(m.store) {
+		return m.bufferBlob(arcID, arc, docID, encrypted)
+	}
+
+	packs := arc.Packs
+	if packs.Active == "" || packs.Sizes[packs.Active] >= packTargetSize {
+		packs.Active = uuid.New().String()
+	}
+
+	offset, err := storage.Append(m.store, packKey(arcID, packs.Active), encrypted)
+	if err != nil {
+		return err
+	}
+
+	packs.Sizes[packs.Active] = offset + int64(len(encrypted))
+	packs.Index[docID] = &models.PackEntry{
+		Pack:   packs.Active,
+		Offset: offset,
+		Length: int64(len(encrypted)),
+	}
+	return nil
+}
+
+// pendingPack is a pack built in memory on stores without appends. Every
+// batch starts a new one, written out before the metadata that points into
+// it, so no pack is ever downloaded and uploaded again to grow it.
+type pendingPack struct {
+	id   string
+	data []byte
+}
+
+// bufferBlob adds an encrypted document to the pending pack of an arc
+func (m *Manager) bufferBlob(arcID string, arc *models.Arc, docID string, encrypted []byte) error {
+	m.mu.Lock()
+	pending := m.packPending[arcID]
+	m.mu.Unlock()
+	if pending != nil && len(pending.data) >= packTargetSize {
+		if err := m.flushPack(arcID); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	pending = m.packPending[arcID]
+	if pending == nil {
+		pending = &pendingPack{id: uuid.New().String()}
+		m.packPending[arcID] = pending
+	}
+	offset := int64(len(pending.data))
+	pending.data = append(pending.data, encrypted...)
+	m.mu.Unlock()
+
+	arc.Packs.Sizes[pending.id] = offset + int64(len(encrypted))
+	arc.Packs.Index[docID] = &models.PackEntry{
+		Pack:   pending.id,
+		Offset: offset,
+		Length: int64(len(encrypted)),
+	}
+	return nil
+}
+
+// flushPack writes out the pending pack of an arc, if there is one
+func (m *Manager) flushPack(arcID string) error {
+	m.mu.Lock()
+	pending := m.packPending[arcID]
+	delete(m.packPending, arcID)
+	m.mu.Unlock()
+
+	if pending == nil {
+		return nil
+	}
+	if err := m.store.Put(packKey(arcID, pending.id), bytes.NewReader(pending.data)); err != nil {
+		return fmt.Errorf("failed to write pack %s: %w", pending.id, err)
+	}
+	return nil
+}
+
+// readBlob loads an encrypted document from its pack or its own object
+func (m *Manager) readBlob(arcID string, arc *models.Arc, docID string) ([]byte, error) {
+	if arc.Packs != nil {
+		if entry, ok := arc.Packs.Index[docID]; ok {
+			m.mu.Lock()
+			pending := m.packPending[arcID]
+			m.mu.Unlock()
+			if pending != nil && pending.id == entry.Pack {
+				return bytes.Clone(pending.data[entry.Offset : entry.Offset+entry.Length]), nil
+			}
+			return storage.ReadRange(m.store, packKey(arcID, entry.Pack), entry.Offset, entry.Length)
+		}
+	}
+	return storage.ReadAll(m.store, documentKey(arcID, docID))
+}
+
+// deleteBlob removes an encrypted document. Packed documents are only
+// dropped from the index; their bytes are reclaimed by Repack.
+func (m *Manager) deleteBlob(arcID string, arc *models.Arc, docID string) error {
+	if arc.Packs != nil {
+		if _, ok := arc.Packs.Index[docID]; ok {
+			delete(arc.Packs.Index, docID)
+			return nil
+		}
+	}
+	return m.store.Delete(documentKey(arcID, docID))
+}
+
+// EnablePacking switches an arc to the packed layout. Existing documents
+// stay where they are until the next Repack.
+func (m *Manager) EnablePacking(arcID string, arc *models.Arc, key []byte) error {
+	if arc.Packs != nil {
+		return nil
+	}
+
+	arc.Packs = &models.PackSet{
+		Sizes: make(map[string]int64),
+		Index: make(map[string]*models.PackEntry),
+	}
+	return m.Update(arcID, arc, key)
+}
+
+// Repack rewrites the live documents of partially dead packs into fresh
+// packs, folds small loose documents into packs and deletes what is no
+// longer referenced, including packs left behind by an interrupted run.
+// New data is committed before anything old is removed.
+func (m *Manager) Repack(arcID string, arc *models.Arc, key []byte) (*RepackStats, error) {
+	if arc.Packs == nil {
+		return nil, fmt.Errorf("arc does not use the packed layout")
+	}
+
+	packs := arc.Packs
+	stats := &RepackStats{}
+
+	live := make(map[string]int64)
+	for _, entry := range packs.Index {
+		live[entry.Pack] += entry.Length
+	}
+
+	rewrite := make(map[string]bool)
+	for packID, size := range packs.Sizes {
+		if live[packID] < size {
+			rewrite[packID] = true
+			stats.BytesReclaimed += size - live[packID]
+		}
+	}
+
+	var moves []string
+	var loose []string
+	for docID := range arc.Documents {
+		if entry, ok := packs.Index[docID]; ok {
+			if rewrite[entry.Pack] {
+				moves = append(moves, docID)
+			}
+			continue
+		}
+		if doc := arc.Documents[docID]; doc.Size+sealOverhead <= packBlobMax {
+			moves = append(moves, docID)
+			loose = append(loose, docID)
+		}
+	}
+
+	// Never append to a pack that is about to be dropped
+	if rewrite[packs.Active] {
+		packs.Active = ""
+	}
+
+	movedLoose := make(map[string]bool)
+	for _, docID := range moves {
+		encrypted, err := m.readBlob(arcID, arc, docID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read document %s: %w", docID, err)
+		}
+		if len(encrypted) > packBlobMax {
+			continue
+		}
+
+		m.logf("Packing: %s\n", arc.Documents[docID].Filename)
+		if _, wasPacked := packs.Index[docID]; !wasPacked {
+			movedLoose[docID] = true
+		}
+		if err := m.writeBlob(arcID, arc, docID, encrypted); err != nil {
+			return nil, fmt.Errorf("failed to pack document %s: %w", docID, err)
+		}
+		stats.Packed++
+	}
+
+	for packID := range rewrite {
+		delete(packs.Sizes, packID)
+	}
+
+	if err := m.Update(arcID, arc, key); err != nil {
+		return nil, fmt.Errorf("failed to update arc metadata: %w", err)
+	}
+
+	// Besides the rewritten packs, this sweeps packs an interrupted repack or
+	// batch wrote but never committed
+	keys, err := m.store.List(storage.Join(arcID, "packs") + "/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packs: %w", err)
+	}
+	for _, objectKey := range keys {
+		packID := strings.TrimSuffix(path.Base(objectKey), ".pack")
+		if _, live := packs.Sizes[packID]; live {
+			continue
+		}
+		if err := m.store.Delete(objectKey); err != nil {
+			return nil, fmt.Errorf("failed to delete pack %s: %w", packID, err)
+		}
+		stats.PacksRemoved++
+	}
+	for _, docID := range loose {
+		if !movedLoose[docID] {
+			continue
+		}
+		if err := m.store.Delete(documentKey(arcID, docID)); err != nil {
+			return nil, fmt.Errorf("failed to delete document %s: %w", docID, err)
+		}
+		stats.LooseRemoved++
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,190 @@
+package arc
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ViniTamanhao/arcadio/internal/storage"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+// objectStore behaves like S3: it has no native appends, and it counts the
+// reads and writes of every key
+type objectStore struct {
+	storage.Storage
+
+	mu   sync.Mutex
+	gets map[string]int
+	puts map[string]int
+}
+
+func newObjectStore() *objectStore {
+	return &objectStore{Storage: storage.NewMemory(), gets: make(map[string]int), puts: make(map[string]int)}
+}
+
+func (s *objectStore) Get(key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	s.gets[key]++
+	s.mu.Unlock()
+	return s.Storage.Get(key)
+}
+
+func (s *objectStore) Put(key string, r io.Reader) error {
+	s.mu.Lock()
+	s.puts[key]++
+	s.mu.Unlock()
+	return s.Storage.Put(key, r)
+}
+
+func (s *objectStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gets = make(map[string]int)
+	s.puts = make(map[string]int)
+}
+
+// newPackedArc creates an unlocked arc using the packed layout
+func newPackedArc(t *testing.T, store storage.Storage) (*Manager, *models.Arc, []byte) {
+	t.Helper()
+	m := reopenManager(t, store)
+	if _, err := m.Create("packed", "password1", "q", "a"); err != nil {
+		t.Fatal(err)
+	}
+	arc, key, err := m.Unlock("packed", "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.EnablePacking(arc.ID, arc, key); err != nil {
+		t.Fatal(err)
+	}
+	return m, arc, key
+}
+
+func TestPackingWithoutAppend(t *testing.T) {
+	store := newObjectStore()
+	m, arc, key := newPackedArc(t, store)
+	store.reset()
+
+	contents := make(map[string]string)
+	err := m.Batch(arc.ID, arc, key, func() error {
+		for i := 0; i < 20; i++ {
+			content := strings.Repeat(fmt.Sprint(i), 100)
+			doc, err := m.AddDocumentFromReader(arc.ID, arc, key, fmt.Sprintf("doc%d.txt", i), strings.NewReader(content), nil)
+			if err != nil {
+				return err
+			}
+			contents[doc.ID] = content
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One batch is one new pack, uploaded once and never read back
+	packs := 0
+	for objectKey, n := range store.puts {
+		if strings.Contains(objectKey, "/packs/") {
+			packs++
+			if n != 1 {
+				t.Errorf("%s uploaded %d times", objectKey, n)
+			}
+		}
+	}
+	if packs != 1 {
+		t.Errorf("batch wrote %d packs, want 1", packs)
+	}
+	for objectKey, n := range store.gets {
+		if strings.Contains(objectKey, "/packs/") || strings.HasSuffix(objectKey, ".log") {
+			t.Errorf("%s downloaded %d times while writing", objectKey, n)
+		}
+	}
+
+	// Single documents each add a log segment instead of rewriting the log,
+	// and appending past maxLogSegments folds them into it
+	for i := 0; i < maxLogSegments+5; i++ {
+		doc, err := m.AddDocumentFromReader(arc.ID, arc, key, fmt.Sprintf("single%d.txt", i), strings.NewReader(fmt.Sprint(i)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[doc.ID] = fmt.Sprint(i)
+	}
+	if n := store.puts[logKey(arc.ID)]; n == 0 || n > 2 {
+		t.Errorf("metadata log rewritten %d times by %d appends", n, maxLogSegments+5)
+	}
+	if segments, _ := m.logSegments(logKey(arc.ID)); len(segments) > maxLogSegments {
+		t.Errorf("%d log segments left after appending", len(segments))
+	}
+
+	// Opening and reading never write
+	for round := 0; round < 2; round++ {
+		store.reset()
+		m = reopenManager(t, store)
+		arc, key, err = m.Unlock("packed", "password1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(arc.Documents) != len(contents) {
+			t.Fatalf("reopened arc has %d documents, want %d", len(arc.Documents), len(contents))
+		}
+		for docID, want := range contents {
+			data, err := m.GetDocument(arc.ID, arc, key, docID)
+			if err != nil {
+				t.Fatalf("GetDocument: %v", err)
+			}
+			if string(data) != want {
+				t.Fatalf("document %s = %q, want %q", docID, data, want)
+			}
+		}
+		for objectKey, n := range store.puts {
+			t.Errorf("%s written %d times while reading", objectKey, n)
+		}
+	}
+}
+
+func TestRepackSweepsOrphanPacks(t *testing.T) {
+	for _, native := range []bool{true, false} {
+		t.Run(fmt.Sprintf("append=%v", native), func(t *testing.T) {
+			var store storage.Storage = storage.NewMemory()
+			if !native {
+				store = newObjectStore()
+			}
+			m, arc, key := newPackedArc(t, store)
+
+			doc, err := m.AddDocumentFromReader(arc.ID, arc, key, "kept.txt", strings.NewReader("kept"), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// A pack from a run that died before committing its metadata
+			orphan := packKey(arc.ID, "orphan")
+			if err := store.Put(orphan, strings.NewReader("lost bytes")); err != nil {
+				t.Fatal(err)
+			}
+
+			stats, err := m.Repack(arc.ID, arc, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.PacksRemoved != 1 {
+				t.Errorf("removed %d packs, want 1", stats.PacksRemoved)
+			}
+			if exists, _ := storage.Exists(store, orphan); exists {
+				t.Error("orphaned pack was not removed")
+			}
+
+			m = reopenManager(t, store)
+			arc, key, err = m.Unlock("packed", "password1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := m.GetDocument(arc.ID, arc, key, doc.ID)
+			if err != nil || string(data) != "kept" {
+				t.Fatalf("GetDocument = %q, %v", data, err)
+			}
+		})
+	}
+}
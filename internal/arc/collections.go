@@ -0,0 +1,82 @@
+package arc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+// CreateCollection saves a query under a name. The query is checked now but
+// evaluated each time the collection is used, so it always reflects the
+// current documents.
+func (m *Manager) CreateCollection(arcID string, arc *models.Arc, key []byte, name, query string) (*models.Collection, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("collection name cannot be empty")
+	}
+	if _, err := FindCollection(arc, name); err == nil {
+		return nil, fmt.Errorf("collection already exists: %s", name)
+	}
+
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &models.Collection{Name: name, Query: q.String(), CreatedAt: time.Now()}
+	arc.Collections = append(arc.Collections, c)
+	sort.Slice(arc.Collections, func(i, j int) bool {
+		return strings.ToLower(arc.Collections[i].Name) < strings.ToLower(arc.Collections[j].Name)
+	})
+
+	if err := m.commit(arcID, arc, key); err != nil {
+		return nil, fmt.Errorf("failed to save collection: %w", err)
+	}
+	return c, nil
+}
+
+// DeleteCollection removes a saved collection. Its documents are untouched.
+func (m *Manager) DeleteCollection(arcID string, arc *models.Arc, key []byte, name string) error {
+	c, err := FindCollection(arc, name)
+	if err != nil {
+		return err
+	}
+
+	for i, existing := range arc.Collections {
+		if existing == c {
+			arc.Collections = append(arc.Collections[:i], arc.Collections[i+1:]...)
+			break
+		}
+	}
+	if len(arc.Collections) == 0 {
+		arc.Collections = nil
+	}
+	return m.commit(arcID, arc, key)
+}
+
+// FindCollection looks up a collection by name, ignoring case
+func FindCollection(arc *models.Arc, name string) (*models.Collection, error) {
+	name = strings.TrimSpace(name)
+	for _, c := range arc.Collections {
+		if strings.EqualFold(c.Name, name) {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("collection not found: %s", name)
+}
+
+// CollectionQuery returns the parsed query of a collection
+func CollectionQuery(arc *models.Arc, name string) (*Query, error) {
+	c, err := FindCollection(arc, name)
+	if err != nil {
+		return nil, err
+	}
+	q, err := ParseQuery(c.Query)
+	if err != nil {
+		return nil, fmt.Errorf("collection %s: %w", c.Name, err)
+	}
+	return q, nil
+}
@@ -0,0 +1,165 @@
+package arc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+// DuplicateCopy is one document in a group of identical documents
+type DuplicateCopy struct {
+	Arc *ArcHandle
+	Doc *models.Document
+}
+
+// DuplicateGroup is a set of documents with the same content
+type DuplicateGroup struct {
+	Hash   string
+	Size   int64
+	Copies []DuplicateCopy
+}
+
+// Reclaimable returns the bytes taken by every copy but one
+func (g DuplicateGroup) Reclaimable() int64 {
+	return g.Size * int64(len(g.Copies)-1)
+}
+
+// FindDuplicates groups the documents of the given arcs by content hash and
+// returns the groups with more than one copy, most reclaimable space first
+func (m *Manager) FindDuplicates(arcs []*ArcHandle) []DuplicateGroup {
+	byHash := make(map[string]*DuplicateGroup)
+	for _, h := range arcs {
+		for _, doc := range m.ListDocuments(h.Arc) {
+			if doc.ContentHash == "" {
+				continue
+			}
+			g, exists := byHash[doc.ContentHash]
+			if !exists {
+				g = &DuplicateGroup{Hash: doc.ContentHash, Size: doc.Size}
+				byHash[doc.ContentHash] = g
+			}
+			g.Copies = append(g.Copies, DuplicateCopy{Arc: h, Doc: doc})
+		}
+	}
+
+	var groups []DuplicateGroup
+	for _, g := range byHash {
+		if len(g.Copies) < 2 {
+			continue
+		}
+		sort.Slice(g.Copies, func(i, j int) bool {
+			a, b := g.Copies[i], g.Copies[j]
+			if a.Arc.Arc.Name != b.Arc.Arc.Name {
+				return a.Arc.Arc.Name < b.Arc.Arc.Name
+			}
+			return a.Doc.Path() < b.Doc.Path()
+		})
+		groups = append(groups, *g)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Reclaimable() != groups[j].Reclaimable() {
+			return groups[i].Reclaimable() > groups[j].Reclaimable()
+		}
+		return groups[i].Copies[0].Doc.Path() < groups[j].Copies[0].Doc.Path()
+	})
+	return groups
+}
+
+// File states reported by Status
+const (
+	FileArchived  = "archived"  // the same content is archived at the same path
+	FileElsewhere = "elsewhere" // the content is archived under another path
+	FileChanged   = "changed"   // the path is archived with different content
+	FileNew       = "new"       // neither the path nor the content is archived
+)
+
+// FileStatus is how one local file compares with an arc
+type FileStatus struct {
+	Path  string // relative to the compared directory, with / separators
+	State string
+	Doc   *models.Document // the archived document at the path or with the content
+}
+
+// Status hashes every file below dir and compares it with the arc. A file is
+// expected at the path arc add --recursive would give it: its path relative
+// to dir, inside folder.
+func (m *Manager) Status(arc *models.Arc, dir, folder string) ([]FileStatus, error) {
+	folder, err := CleanFolder(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	byPath := make(map[string]*models.Document, len(arc.Documents))
+	byHash := make(map[string]*models.Document, len(arc.Documents))
+	for _, doc := range m.ListDocuments(arc) {
+		byPath[doc.Path()] = doc
+		if _, exists := byHash[doc.ContentHash]; !exists {
+			byHash[doc.ContentHash] = doc
+		}
+	}
+
+	var statuses []FileStatus
+	err = filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		hash, err := hashFile(file)
+		if err != nil {
+			return err
+		}
+
+		status := FileStatus{Path: rel, State: FileNew}
+		if doc, exists := byPath[path.Join(folder, rel)]; exists {
+			status.Doc = doc
+			status.State = FileChanged
+			if doc.ContentHash == hash {
+				status.State = FileArchived
+			}
+		}
+		if status.State != FileArchived {
+			if doc, exists := byHash[hash]; exists {
+				status.Doc = doc
+				status.State = FileElsewhere
+			}
+		}
+		statuses = append(statuses, status)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return statuses, nil
+}
+
+// hashFile returns the hex SHA-256 of a file, as stored in ContentHash
+func hashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/ViniTamanhao/arcadio/internal/crypto"
@@ -14,16 +17,24 @@ import (
 	"github.com/google/uuid"
 )
 
-// AddDocument adds a file to an arc
-func (m *Manager) AddDocument (arcID string, arc *models.Arc, key []byte, filePath string, tags []string) (*models.Document, error) {
-	fmt.Printf("Reading file: %s\n", filePath)
+// AddDocument adds a file to an arc, placing it in a virtual folder
+func (m *Manager) AddDocument (arcID string, arc *models.Arc, key []byte, filePath, folder string, tags []string) (*models.Document, error) {
+	folder, err := CleanFolder(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	m.logf("Reading file: %s\n", filePath)
 
 	fileData, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
+	if err := checkQuota(arc, int64(len(fileData))); err != nil {
+		return nil, err
+	}
 
-	fmt.Println("Calculating content hash...")
+	m.logf("Calculating content hash...\n")
 	hash := sha256.Sum256(fileData)
 	contentHash := hex.EncodeToString(hash[:])
 
@@ -31,7 +42,7 @@ func (m *Manager) AddDocument (arcID string, arc *models.Arc, key []byte, filePa
 	dataToEncrypt := fileData
 	compressed := false
 
-	fmt.Println("Encrypting document...")
+	m.logf("Encrypting document...\n")
 	encryptedData, err := crypto.Encrypt(key, dataToEncrypt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt document: %w", err)
@@ -40,32 +51,34 @@ func (m *Manager) AddDocument (arcID string, arc *models.Arc, key []byte, filePa
 	doc := &models.Document{
 		ID: uuid.New().String(),
 		Filename: filepath.Base(filePath),
+		Folder: folder,
 		AddedAt: time.Now(),
 		ModifiedAt: time.Now(),
 		Size: int64(len(fileData)),
 		ContentHash: contentHash,
 		Compressed: compressed,
+		ContentType: DetectContentType(filePath, fileData),
 	}
 
-	docPath := m.GetDocumentPath(arcID, doc.ID)
-	fmt.Printf("Saving encrypted document: %s\n", docPath)
+	m.logf("Saving encrypted document...\n")
 
-	if err := os.WriteFile(docPath, encryptedData, 0600); err != nil {
+	if err := m.writeBlob(arcID, arc, doc.ID, encryptedData); err != nil {
 		return nil, fmt.Errorf("failed to save encrypted document: %w", err)
 	}
 
 	arc.Documents[doc.ID] = doc
 
-	if len(tags) > 0 {
+	if tags = NormalizeTags(tags); len(tags) > 0 {
 		arc.Tags[doc.ID] = tags
-		fmt.Printf("Added tags %v\n", tags)
+		m.logf("Added tags %v\n", tags)
 	}
 
-	if err := m.Update(arcID, arc, key); err != nil {
+	if err := m.commit(arcID, arc, key, doc.ID); err != nil {
 		return nil, fmt.Errorf("failed to update arc metadata: %w", err)
 	}
+	m.indexDocument(arcID, key, doc.ID, doc, fileData)
 
-	fmt.Println("Document added successfully")
+	m.logf("Document added successfully\n")
 	return doc, nil
 }
 
@@ -75,22 +88,25 @@ func (m *Manager) RemoveDocument(arcID string, arc *models.Arc, key []byte, docI
  	if !exists {
 		return fmt.Errorf("document not foun: %s", docID)
 	} 
+	if err := CheckChange(arc, doc); err != nil {
+		return err
+	}
 
-	fmt.Printf("Removing document: %s\n", doc.Filename)
+	m.logf("Removing document: %s\n", doc.Filename)
 
-	docPath := m.GetDocumentPath(arcID, docID)
-	if err := os.Remove(docPath); err != nil {
+	if err := m.deleteBlob(arcID, arc, docID); err != nil {
 		return fmt.Errorf("failed to delete document file: %w", err)
 	}
 
 	delete(arc.Documents, docID)
 	delete(arc.Tags, docID)
 
-	if err := m.Update(arcID, arc, key); err != nil {
+	if err := m.commit(arcID, arc, key, docID); err != nil {
 		return fmt.Errorf("failed to update arc metadata: %w", err)
 	}
+	m.unindexDocument(arcID, key, docID)
 
-	fmt.Println("Document removed successfully")
+	m.logf("Document removed successfully\n")
 	return nil
 }
 
@@ -100,15 +116,14 @@ func (m *Manager) ExportDocument(arcID string, arc *models.Arc, key []byte, docI
 		return fmt.Errorf("document not found: %s", docID)
 	}
 
-	fmt.Printf("Exporting document: %s\n", doc.Filename)
+	m.logf("Exporting document: %s\n", doc.Filename)
 
-	docPath := m.GetDocumentPath(arcID, docID)
-	encryptedData, err := os.ReadFile(docPath)
+	encryptedData, err := m.readBlob(arcID, arc, docID)
 	if err != nil {
 		return fmt.Errorf("failed to read encrypted document: %w", err)
 	}
 
-	fmt.Println("Decrypting document...")
+	m.logf("Decrypting document...\n")
 	decryptedData, err := crypto.Decrypt(key, encryptedData)
 	if err != nil {
 		return fmt.Errorf("failed to decrypt document: %w", err)
@@ -121,12 +136,12 @@ func (m *Manager) ExportDocument(arcID string, arc *models.Arc, key []byte, docI
 		return fmt.Errorf("document integrity check failed - file may be corrupted")
 	}
 
-	fmt.Printf("Writing to: %s\n", outputPath)
+	m.logf("Writing to: %s\n", outputPath)
 	if err := os.WriteFile(outputPath, decryptedData, 0644); err != nil {
 		return fmt.Errorf("failed to write output file: %w", err)
 	}
 
-	fmt.Println("Document exported successfully")
+	m.logf("Document exported successfully\n")
 	return nil
 }
 
@@ -137,8 +152,7 @@ func (m *Manager) GetDocument(arcID string, arc *models.Arc, key []byte, docID s
 		return nil, fmt.Errorf("document not found: %s", docID)
 	}
 
-	docPath := m.GetDocumentPath(arcID, docID)
-	encryptedData, err := os.ReadFile(docPath)
+	encryptedData, err := m.readBlob(arcID, arc, docID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read encrypted document: %w", err)
 	}
@@ -151,120 +165,164 @@ func (m *Manager) GetDocument(arcID string, arc *models.Arc, key []byte, docID s
 	return decryptedData, nil
 }
 
-// AddTags adds tags to a document
-func (m *Manager) AddTags(arcID string, arc *models.Arc, key []byte, docID string, tags []string) error {
-	if _, exists := arc.Documents[docID]; !exists {
+// UpdateDocumentContent replaces the content of a document, keeping its ID,
+// tags and properties
+func (m *Manager) UpdateDocumentContent(arcID string, arc *models.Arc, key []byte, docID string, data []byte) error {
+	doc, exists := arc.Documents[docID]
+	if !exists {
 		return fmt.Errorf("document not found: %s", docID)
 	}
+	if err := CheckChange(arc, doc); err != nil {
+		return err
+	}
+	if err := checkQuota(arc, int64(len(data))-doc.Size); err != nil {
+		return err
+	}
 
-	existingTags := arc.Tags[docID]
-	if existingTags == nil {
-		existingTags = []string{}
+	encryptedData, err := crypto.Encrypt(key, data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt document: %w", err)
 	}
 
-	tagSet := make(map[string]bool)
-	for _, tag := range existingTags {
-		tagSet[tag] = true
+	wasLoose := true
+	if arc.Packs != nil {
+		_, packed := arc.Packs.Index[docID]
+		wasLoose = !packed
 	}
-	for _, tag := range tags {
-		tagSet[tag] = true
+
+	if err := m.writeBlob(arcID, arc, docID, encryptedData); err != nil {
+		return fmt.Errorf("failed to save encrypted document: %w", err)
 	}
 
-	newTags := make([]string, 0,len(tagSet))
-	for tag := range tagSet {
-		newTags = append(newTags, tag)
+	hash := sha256.Sum256(data)
+	doc.ContentHash = hex.EncodeToString(hash[:])
+	doc.Size = int64(len(data))
+	doc.ContentType = DetectContentType(doc.Filename, data)
+	doc.ModifiedAt = time.Now()
+
+	if err := m.commit(arcID, arc, key, docID); err != nil {
+		return fmt.Errorf("failed to update arc metadata: %w", err)
 	}
+	m.indexDocument(arcID, key, docID, doc, data)
 
-	arc.Tags[docID] = newTags
-	return m.Update(arcID, arc, key)
+	// A loose document that now lives in a pack leaves its old object behind
+	if wasLoose && arc.Packs != nil {
+		if _, packed := arc.Packs.Index[docID]; !packed {
+			return nil
+		}
+		if err := m.store.Delete(documentKey(arcID, docID)); err != nil {
+			return fmt.Errorf("failed to delete old document file: %w", err)
+		}
+	}
+	return nil
+}
+
+// AddTags adds tags to a document
+func (m *Manager) AddTags(arcID string, arc *models.Arc, key []byte, docID string, tags []string) error {
+	doc, exists := arc.Documents[docID]
+	if !exists {
+		return fmt.Errorf("document not found: %s", docID)
+	}
+	if err := CheckChange(arc, doc); err != nil {
+		return err
+	}
+
+	arc.Tags[docID] = NormalizeTags(append(append([]string(nil), arc.Tags[docID]...), tags...))
+	return m.commit(arcID, arc, key, docID)
 }
 
 // RemoveTags removes tags from a document
 func (m *Manager) RemoveTags(arcID string, arc *models.Arc, key []byte, docID string, tags []string) error {
-	if _, exists := arc.Documents[docID]; !exists {
+	doc, exists := arc.Documents[docID]
+	if !exists {
 		return fmt.Errorf("document not found: %s", docID)
 	}
+	if err := CheckChange(arc, doc); err != nil {
+		return err
+	}
 
-	existingTags := arc.Tags[docID]
-	if existingTags == nil {
+	if len(arc.Tags[docID]) == 0 {
 		return nil
 	}
 
-	tagSet := make(map[string]bool)
-	for _, tag := range existingTags {
-		tagSet[tag] = true
-	}
+	removed := make(map[string]bool, len(tags))
 	for _, tag := range tags {
-		delete(tagSet, tag)
+		removed[NormalizeTag(tag)] = true
 	}
 
-	newTags := make([]string, 0, len(tagSet))
-	for tag := range tagSet {
-		newTags = append(newTags, tag)
+	var kept []string
+	for _, tag := range arc.Tags[docID] {
+		if !removed[NormalizeTag(tag)] {
+			kept = append(kept, tag)
+		}
 	}
 
-	arc.Tags[docID] = newTags
-	return m.Update(arcID, arc, key)
+	arc.Tags[docID] = NormalizeTags(kept)
+	return m.commit(arcID, arc, key, docID)
 }
 
-// ListDocuments returns all documents in the arc
-func (m *Manager) ListDocuments(arc *models.Arc) []*models.Document {
-	docs := make([]*models.Document, 0, len(arc.Documents))
-	for _, doc := range arc.Documents {
-		docs = append(docs, doc)
+// FindDocument resolves a document by ID, unique ID prefix, virtual path
+// or unique filename
+func (m *Manager) FindDocument(arc *models.Arc, ref string) (*models.Document, error) {
+	if doc, exists := arc.Documents[ref]; exists {
+		return doc, nil
 	}
-	return docs
-}
 
-// SearchDocuments performs fuzzy search on document filenames
-func (m *Manager) SearchDocuments(arc *models.Arc, query string) []*models.Document {
-	// Simple substring search for now
-	// We can improve this with fuzzy matching library later
-	var matches []*models.Document
-	
-	for _, doc := range arc.Documents {
-		if contains(doc.Filename, query) {
-			matches = append(matches, doc)
+	var byPrefix, byPath, byName []*models.Document
+	cleanRef := strings.Trim(strings.ReplaceAll(ref, "\\", "/"), "/")
+	for id, doc := range arc.Documents {
+		if len(ref) >= 4 && strings.HasPrefix(id, ref) {
+			byPrefix = append(byPrefix, doc)
+		}
+		if doc.Path() == cleanRef {
+			byPath = append(byPath, doc)
+		}
+		if doc.Filename == ref {
+			byName = append(byName, doc)
 		}
 	}
-	
-	return matches
-}
 
-// Helper function for case-insensitive substring matching
-func contains(s, substr string) bool {
-	s = toLower(s)
-	substr = toLower(substr)
-	return len(s) >= len(substr) && (s == substr || indexof(s, substr) >= 0)
-}
-
-func toLower(s string) string {
-	result := make([]byte, len(s))
-	for i := 0; i < len(s); i++ {
-		c := s[i]
-		if c >= 'A' && c <= 'Z' {
-			c = c + 32
+	for _, matches := range [][]*models.Document{byPrefix, byPath, byName} {
+		if len(matches) == 1 {
+			return matches[0], nil
+		}
+		if len(matches) > 1 {
+			return nil, fmt.Errorf("%q matches %d documents, use the document ID", ref, len(matches))
 		}
-		result[i] = c
 	}
-	return string(result)
+
+	return nil, fmt.Errorf("document not found: %s", ref)
 }
 
-func indexof(s, substr string) int {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return i
-		}
+// ListDocuments returns all documents in the arc
+func (m *Manager) ListDocuments(arc *models.Arc) []*models.Document {
+	docs := make([]*models.Document, 0, len(arc.Documents))
+	for _, doc := range arc.Documents {
+		docs = append(docs, doc)
 	}
-	return -1
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Path() < docs[j].Path() })
+	return docs
 }
 
-// AddDocumentFromReader adds a document from an io.Reader
-func (m *Manager) AddDocumentFromReader(arcID string, arc *models.Arc, key []byte, filename string, reader io.Reader, tags []string) (*models.Document, error) {
+// AddDocumentFromReader adds a document from an io.Reader. The name may
+// include a virtual folder, e.g. "2024/report.csv".
+func (m *Manager) AddDocumentFromReader(arcID string, arc *models.Arc, key []byte, name string, reader io.Reader, tags []string) (*models.Document, error) {
+	folder, err := CleanFolder(path.Dir(strings.ReplaceAll(name, "\\", "/")))
+	if err != nil {
+		return nil, err
+	}
+	filename := path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if filename == "." || filename == "/" {
+		return nil, fmt.Errorf("invalid document name: %q", name)
+	}
+
 	fileData, err := io.ReadAll(reader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read data: %w", err)
 	}
+	if err := checkQuota(arc, int64(len(fileData))); err != nil {
+		return nil, err
+	}
 
 	hash := sha256.Sum256(fileData)
 	contentHash := hex.EncodeToString(hash[:])
@@ -277,27 +335,28 @@ func (m *Manager) AddDocumentFromReader(arcID string, arc *models.Arc, key []byt
 	doc := &models.Document{
 		ID:          uuid.New().String(),
 		Filename:    filename,
+		Folder:      folder,
 		AddedAt:     time.Now(),
 		ModifiedAt:  time.Now(),
 		Size:        int64(len(fileData)),
 		ContentHash: contentHash,
 		Compressed:  false,
+		ContentType: DetectContentType(filename, fileData),
 	}
 
-	docPath := m.GetDocumentPath(arcID, doc.ID)
-	if err := os.WriteFile(docPath, encryptedData, 0600); err != nil {
+	if err := m.writeBlob(arcID, arc, doc.ID, encryptedData); err != nil {
 		return nil, fmt.Errorf("failed to save encrypted document: %w", err)
 	}
 
 	arc.Documents[doc.ID] = doc
-	if len(tags) > 0 {
+	if tags = NormalizeTags(tags); len(tags) > 0 {
 		arc.Tags[doc.ID] = tags
 	}
 
-	arc.ModifiedAt = time.Now()
-	if err := m.Update(arcID, arc, key); err != nil {
+	if err := m.commit(arcID, arc, key, doc.ID); err != nil {
 		return nil, fmt.Errorf("failed to update arc metadata: %w", err)
 	}
+	m.indexDocument(arcID, key, doc.ID, doc, fileData)
 
 	return doc, nil
 }
@@ -0,0 +1,172 @@
+package arc
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+// ConflictPolicy decides what happens when an export target already exists
+type ConflictPolicy string
+
+const (
+	ConflictFail      ConflictPolicy = "fail"
+	ConflictSkip      ConflictPolicy = "skip"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictRename    ConflictPolicy = "rename"
+)
+
+// ExportOptions controls how a folder is exported
+type ExportOptions struct {
+	Policy        ConflictPolicy // what to do when a target file exists
+	FixExtensions bool           // add the extension of the detected type to files without one
+}
+
+// ExportStats summarizes a folder export
+type ExportStats struct {
+	Exported int
+	Skipped  int
+	Renamed  int
+}
+
+// CleanFolder normalizes a virtual folder path. The root is "".
+func CleanFolder(folder string) (string, error) {
+	folder = strings.ReplaceAll(folder, "\\", "/")
+	folder = path.Clean("/" + folder)
+	folder = strings.Trim(folder, "/")
+
+	for _, part := range strings.Split(folder, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("invalid folder: %s", folder)
+		}
+	}
+	return folder, nil
+}
+
+// inFolder reports whether folder is dir itself or one of its descendants
+func inFolder(folder, dir string) bool {
+	return dir == "" || folder == dir || strings.HasPrefix(folder, dir+"/")
+}
+
+// ListFolder returns the direct subfolders and documents of a folder
+func (m *Manager) ListFolder(arc *models.Arc, folder string) ([]string, []*models.Document) {
+	subfolders := make(map[string]bool)
+	var docs []*models.Document
+
+	for _, doc := range arc.Documents {
+		if doc.Folder == folder {
+			docs = append(docs, doc)
+			continue
+		}
+		if !inFolder(doc.Folder, folder) {
+			continue
+		}
+
+		rest := strings.TrimPrefix(doc.Folder, folder)
+		rest = strings.TrimPrefix(rest, "/")
+		subfolders[strings.SplitN(rest, "/", 2)[0]] = true
+	}
+
+	folders := make([]string, 0, len(subfolders))
+	for name := range subfolders {
+		folders = append(folders, name)
+	}
+	sort.Strings(folders)
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Filename < docs[j].Filename })
+
+	return folders, docs
+}
+
+// DocumentsUnder returns every document in folder and its subfolders,
+// ordered by path
+func (m *Manager) DocumentsUnder(arc *models.Arc, folder string) []*models.Document {
+	var docs []*models.Document
+	for _, doc := range arc.Documents {
+		if inFolder(doc.Folder, folder) {
+			docs = append(docs, doc)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Path() < docs[j].Path() })
+	return docs
+}
+
+// MoveDocument moves a document to another virtual folder
+func (m *Manager) MoveDocument(arcID string, arc *models.Arc, key []byte, docID, folder string) error {
+	doc, exists := arc.Documents[docID]
+	if !exists {
+		return fmt.Errorf("document not found: %s", docID)
+	}
+	return m.RenameDocument(arcID, arc, key, docID, folder, doc.Filename)
+}
+
+// ExportFolder decrypts every document under folder into destDir,
+// recreating the folder hierarchy relative to folder
+func (m *Manager) ExportFolder(arcID string, arc *models.Arc, key []byte, folder, destDir string, opts ExportOptions) (*ExportStats, error) {
+	folder, err := CleanFolder(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := m.DocumentsUnder(arc, folder)
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("no documents under: /%s", folder)
+	}
+	return m.ExportDocuments(arcID, arc, key, docs, folder, destDir, opts)
+}
+
+// ExportDocuments decrypts docs into destDir, recreating their folders
+// relative to the base folder
+func (m *Manager) ExportDocuments(arcID string, arc *models.Arc, key []byte, docs []*models.Document, base, destDir string, opts ExportOptions) (*ExportStats, error) {
+	stats := &ExportStats{}
+	for _, doc := range docs {
+		rel := strings.TrimPrefix(doc.Path(), base)
+		rel = strings.TrimPrefix(rel, "/")
+		target := filepath.Join(destDir, filepath.FromSlash(rel))
+		if opts.FixExtensions {
+			target = WithExtension(target, doc)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return stats, fmt.Errorf("failed to create directory: %w", err)
+		}
+
+		if _, err := os.Lstat(target); err == nil {
+			switch opts.Policy {
+			case ConflictSkip:
+				m.logf("Skipping existing file: %s\n", target)
+				stats.Skipped++
+				continue
+			case ConflictOverwrite:
+			case ConflictRename:
+				target = uniquePath(target)
+				stats.Renamed++
+			default:
+				return stats, fmt.Errorf("file already exists: %s", target)
+			}
+		}
+
+		if err := m.ExportDocument(arcID, arc, key, doc.ID, target); err != nil {
+			return stats, err
+		}
+		stats.Exported++
+	}
+
+	return stats, nil
+}
+
+// uniquePath appends " (n)" before the extension until the path is free
+func uniquePath(p string) string {
+	ext := filepath.Ext(p)
+	base := strings.TrimSuffix(p, ext)
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,457 @@
+package arc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ViniTamanhao/arcadio/internal/crypto"
+	"github.com/ViniTamanhao/arcadio/internal/extract"
+	"github.com/ViniTamanhao/arcadio/internal/storage"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+// The full-text index is an encrypted append-only log (index.log) next to
+// the metadata. Each entry holds the text extracted from one document and
+// its postings, term -> word positions; loading the log inverts them into
+// term -> document -> positions. Entries remember the content hash they
+// were built from, so entries left stale by an interrupted write are
+// ignored rather than trusted.
+
+const (
+	// maxIndexText bounds the text indexed per document
+	maxIndexText = extract.MaxText
+
+	// maxTermLen skips tokens too long to be words, e.g. base64 blobs
+	maxTermLen = 64
+
+	// indexFrameRecords bounds how many entries a rewritten index puts in
+	// one frame
+	indexFrameRecords = 100
+
+	// snippetBefore and snippetAfter are the words of context shown around
+	// a match
+	snippetBefore = 4
+	snippetAfter  = 12
+)
+
+// indexRecord is one change to the full-text index
+type indexRecord struct {
+	Op    string           `json:"op"` // "doc" or "del"
+	DocID string           `json:"doc_id"`
+	Hash  string           `json:"hash,omitempty"` // content hash the entry was built from
+	Text  string           `json:"text,omitempty"`
+	Terms map[string][]int `json:"terms,omitempty"` // term -> word positions
+}
+
+// contentIndex is the loaded full-text index of an arc
+type contentIndex struct {
+	docs     map[string]*indexRecord
+	postings map[string]map[string][]int // term -> doc ID -> word positions
+	records  int                         // entries read, live or not
+}
+
+// ContentResult is one document matching a full-text search
+type ContentResult struct {
+	Doc     *models.Document
+	Score   float64
+	Snippet string   // the text around the best match
+	Spans   [][2]int // byte ranges of Snippet that matched
+}
+
+// indexKey is the storage key of an arc's full-text index
+func indexKey(arcID string) string {
+	return storage.Join(arcID, "index.log")
+}
+
+// buildIndexRecord extracts the text of a document's content and indexes it
+// under docID
+func (m *Manager) buildIndexRecord(docID string, doc *models.Document, data []byte) *indexRecord {
+	rec := &indexRecord{Op: "doc", DocID: docID, Hash: doc.ContentHash}
+
+	text, err := extract.Text(ContentTypeOf(doc), data)
+	if err != nil {
+		m.logf("Could not extract text from %s: %v\n", doc.Filename, err)
+		return rec
+	}
+	if len(text) > maxIndexText {
+		cut := maxIndexText
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut]
+	}
+	if text == "" {
+		return rec
+	}
+
+	rec.Text = text
+	rec.Terms = make(map[string][]int)
+	for i, term := range terms(fold(text)) {
+		if term != "" {
+			rec.Terms[term] = append(rec.Terms[term], i)
+		}
+	}
+	return rec
+}
+
+// terms returns the indexable words of a folded text, with "" standing in
+// for words too long to index so positions stay aligned with words()
+func terms(ft foldedText) []string {
+	ws := words(ft)
+	out := make([]string, len(ws))
+	for i, w := range ws {
+		if w.end-w.start <= maxTermLen {
+			out[i] = string(ft.runes[w.start:w.end])
+		}
+	}
+	return out
+}
+
+// indexDocument adds the content of a document to the index under docID,
+// which differs from doc.ID when the document is being copied between arcs
+func (m *Manager) indexDocument(arcID string, key []byte, docID string, doc *models.Document, data []byte) {
+	m.writeIndex(arcID, key, m.buildIndexRecord(docID, doc, data))
+}
+
+// unindexDocument drops a document from the index
+func (m *Manager) unindexDocument(arcID string, key []byte, docID string) {
+	m.writeIndex(arcID, key, &indexRecord{Op: "del", DocID: docID})
+}
+
+// writeIndex appends index entries, deferring them to the end of an open
+// Batch. The index is secondary to the documents themselves, so a failure
+// is reported rather than undoing the change that was indexed.
+func (m *Manager) writeIndex(arcID string, key []byte, recs ...*indexRecord) {
+	m.mu.Lock()
+	if _, batching := m.batches[arcID]; batching {
+		m.indexPending[arcID] = append(m.indexPending[arcID], recs...)
+		if len(m.indexPending[arcID]) < indexFrameRecords {
+			m.mu.Unlock()
+			return
+		}
+		recs = m.indexPending[arcID]
+		delete(m.indexPending, arcID)
+	}
+	m.mu.Unlock()
+
+	m.appendIndex(arcID, key, recs)
+}
+
+// appendIndex writes index entries as one frame
+func (m *Manager) appendIndex(arcID string, key []byte, recs []*indexRecord) {
+	if len(recs) == 0 {
+		return
+	}
+	data, err := json.Marshal(recs)
+	if err == nil {
+		_, err = m.appendFrame(indexKey(arcID), key, data)
+	}
+	if err != nil {
+		m.logf("Warning: failed to update the search index: %v (run 'arc reindex' to rebuild it)\n", err)
+	}
+}
+
+// loadIndex reads and inverts the full-text index of an arc
+func (m *Manager) loadIndex(arcID string, key []byte) (*contentIndex, int64, error) {
+	idx := &contentIndex{docs: make(map[string]*indexRecord)}
+	size, err := m.readFrames(indexKey(arcID), key, func(plain []byte) error {
+		var recs []*indexRecord
+		if err := json.Unmarshal(plain, &recs); err != nil {
+			return fmt.Errorf("invalid search index record: %w", err)
+		}
+		for _, rec := range recs {
+			idx.records++
+			if rec.Op == "del" {
+				delete(idx.docs, rec.DocID)
+			} else {
+				idx.docs[rec.DocID] = rec
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read search index: %w", err)
+	}
+
+	idx.postings = make(map[string]map[string][]int)
+	for docID, rec := range idx.docs {
+		for term, positions := range rec.Terms {
+			if idx.postings[term] == nil {
+				idx.postings[term] = make(map[string][]int)
+			}
+			idx.postings[term][docID] = positions
+		}
+	}
+	return idx, size, nil
+}
+
+// current reports whether the index entry for a document matches its content
+func (idx *contentIndex) current(doc *models.Document) bool {
+	rec, ok := idx.docs[doc.ID]
+	return ok && rec.Hash == doc.ContentHash
+}
+
+// Reindex rebuilds the full-text index from the content of every document
+// and returns how many documents had text to index
+func (m *Manager) Reindex(arcID string, arc *models.Arc, key []byte) (int, error) {
+	var recs []*indexRecord
+	withText := 0
+	for _, doc := range m.ListDocuments(arc) {
+		m.logf("Indexing: %s\n", doc.Path())
+		data, err := m.GetDocument(arcID, arc, key, doc.ID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read /%s: %w", doc.Path(), err)
+		}
+		rec := m.buildIndexRecord(doc.ID, doc, data)
+		if rec.Text != "" {
+			withText++
+		}
+		recs = append(recs, rec)
+	}
+
+	if err := m.rewriteIndex(arcID, key, recs); err != nil {
+		return 0, err
+	}
+	return withText, nil
+}
+
+// rewriteIndex replaces the index log with the given entries
+func (m *Manager) rewriteIndex(arcID string, key []byte, recs []*indexRecord) error {
+	var buf bytes.Buffer
+	for start := 0; start < len(recs); start += indexFrameRecords {
+		end := min(start+indexFrameRecords, len(recs))
+		data, err := json.Marshal(recs[start:end])
+		if err != nil {
+			return err
+		}
+		encrypted, err := crypto.Encrypt(key, data)
+		if err != nil {
+			return err
+		}
+		binary.Write(&buf, binary.BigEndian, uint32(len(encrypted)))
+		buf.Write(encrypted)
+	}
+
+	if err := m.rewriteLog(indexKey(arcID), buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write search index: %w", err)
+	}
+	return nil
+}
+
+// SearchContent finds documents whose text contains every word of query,
+// best match first. Documents holding the words as a phrase rank above
+// those holding them apart. Query words also match longer words they
+// start, so "terminat" finds "termination". It also returns how many
+// documents are missing from the index and need 'arc reindex'.
+func (m *Manager) SearchContent(arcID string, arc *models.Arc, key []byte, query string, limit int) ([]ContentResult, int, error) {
+	idx, size, err := m.loadIndex(arcID, key)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	unindexed := 0
+	for _, doc := range arc.Documents {
+		if !idx.current(doc) {
+			unindexed++
+		}
+	}
+
+	// Entries for removed or rewritten documents pile up; drop them once
+	// they outnumber the live ones
+	if size > compactMinLog && idx.records > 2*len(idx.docs) {
+		var live []*indexRecord
+		for _, doc := range m.ListDocuments(arc) {
+			if idx.current(doc) {
+				live = append(live, idx.docs[doc.ID])
+			}
+		}
+		if err := m.rewriteIndex(arcID, key, live); err != nil {
+			m.logf("Warning: %v\n", err)
+		}
+	}
+
+	var queryTerms []string
+	for _, term := range terms(fold(query)) {
+		if term != "" {
+			queryTerms = append(queryTerms, term)
+		}
+	}
+	if len(queryTerms) == 0 {
+		return nil, unindexed, nil
+	}
+
+	// Positions of every index term each query word matches, per document
+	matches := make([]map[string][]int, len(queryTerms))
+	for i, qt := range queryTerms {
+		matches[i] = make(map[string][]int)
+		for term, docs := range idx.postings {
+			if !strings.HasPrefix(term, qt) {
+				continue
+			}
+			for docID, positions := range docs {
+				matches[i][docID] = append(matches[i][docID], positions...)
+			}
+		}
+	}
+
+	var results []ContentResult
+	for docID := range matches[0] {
+		doc, exists := arc.Documents[docID]
+		if !exists || !idx.current(doc) {
+			continue
+		}
+
+		score := 0.0
+		positions := make([][]int, len(queryTerms))
+		for i := range queryTerms {
+			positions[i] = matches[i][docID]
+			if len(positions[i]) == 0 {
+				score = -1
+				break
+			}
+			sort.Ints(positions[i])
+			idf := math.Log(1 + float64(len(idx.docs))/float64(len(matches[i])))
+			score += (1 + math.Log(float64(len(positions[i])))) * idf
+		}
+		if score < 0 {
+			continue
+		}
+
+		anchor, phrase := findPhrase(positions)
+		if phrase {
+			score *= 2
+		}
+
+		result := ContentResult{Doc: doc, Score: score}
+		result.Snippet, result.Spans = snippet(idx.docs[docID].Text, anchor, queryTerms)
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Doc.Path() < results[j].Doc.Path()
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, unindexed, nil
+}
+
+// findPhrase returns the position of the first occurrence of the query
+// words in order. Failing that it returns the start of the passage holding
+// the most distinct query words.
+func findPhrase(positions [][]int) (int, bool) {
+	for _, start := range positions[0] {
+		found := true
+		for i := 1; i < len(positions) && found; i++ {
+			j := sort.SearchInts(positions[i], start+i)
+			found = j < len(positions[i]) && positions[i][j] == start+i
+		}
+		if found {
+			return start, len(positions) > 1
+		}
+	}
+
+	best, bestCount := positions[0][0], 0
+	for _, starts := range positions {
+		for _, start := range starts {
+			count := 0
+			for _, p := range positions {
+				j := sort.SearchInts(p, start)
+				if j < len(p) && p[j] < start+len(positions)+snippetAfter {
+					count++
+				}
+			}
+			if count > bestCount || count == bestCount && start < best {
+				best, bestCount = start, count
+			}
+		}
+	}
+	return best, false
+}
+
+// wordRange finds the byte range of text covering words from to to-1, as
+// numbered by words(fold(text)), without folding all of text. found is the
+// number of words seen, which stops short at to+1 when more words follow.
+func wordRange(text string, from, to int) (start, end, found int) {
+	var ascii [1]rune
+	inWord := false
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		folded := ascii[:]
+		if r < utf8.RuneSelf {
+			ascii[0] = unicode.ToLower(r)
+		} else {
+			folded = foldRune(r)
+		}
+		for _, f := range folded {
+			isWord := unicode.IsLetter(f) || unicode.IsDigit(f)
+			if isWord && !inWord {
+				if found == to {
+					return start, end, found + 1
+				}
+				if found == from {
+					start = i
+				}
+				found++
+			}
+			if isWord && found > from {
+				end = i + size
+			}
+			inWord = isWord
+		}
+		i += size
+	}
+	return start, end, found
+}
+
+// snippet cuts the text around word position anchor and marks the words
+// matching the query. Only the words around the anchor are folded, since
+// stored text can run to a megabyte.
+func snippet(text string, anchor int, queryTerms []string) (string, [][2]int) {
+	from := max(anchor-snippetBefore, 0)
+	to := anchor + len(queryTerms) + snippetAfter
+	start, end, found := wordRange(text, from, to)
+	if anchor >= found {
+		return "", nil
+	}
+	ft := fold(text[start:end])
+	ws := words(ft)
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	offset := b.Len()
+	b.WriteString(strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, text[start:end]))
+	if found > to {
+		b.WriteString("…")
+	}
+
+	var spans [][2]int
+	for _, w := range ws {
+		word := string(ft.runes[w.start:w.end])
+		for _, qt := range queryTerms {
+			if strings.HasPrefix(word, qt) {
+				span := ft.span(w.start, w.end)
+				spans = append(spans, [2]int{span[0] + offset, span[1] + offset})
+				break
+			}
+		}
+	}
+	return b.String(), spans
+}
@@ -0,0 +1,37 @@
+package arc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSnippet(t *testing.T) {
+	text := "Le café était fermé.\nOn a bu un thé au bord du lac, puis nous sommes rentrés à la maison avant la pluie."
+	tests := []struct {
+		name      string
+		anchor    int
+		terms     []string
+		want      string
+		wantMarks []string
+	}{
+		{"start", 1, []string{"cafe"}, "Le café était fermé. On a bu un thé au bord du lac, puis…", []string{"café"}},
+		{"middle", 8, []string{"the", "au"}, "…On a bu un thé au bord du lac, puis nous sommes rentrés à la maison avant la…", []string{"thé", "au"}},
+		{"end", 22, []string{"pluie"}, "…la maison avant la pluie", []string{"pluie"}},
+		{"past the end", 23, []string{"x"}, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, spans := snippet(text, tt.anchor, tt.terms)
+			if got != tt.want {
+				t.Errorf("snippet = %q, want %q", got, tt.want)
+			}
+			var marks []string
+			for _, s := range spans {
+				marks = append(marks, got[s[0]:s[1]])
+			}
+			if strings.Join(marks, "|") != strings.Join(tt.wantMarks, "|") {
+				t.Errorf("marked %q, want %q", marks, tt.wantMarks)
+			}
+		})
+	}
+}
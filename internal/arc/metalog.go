@@ -0,0 +1,495 @@
+package arc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ViniTamanhao/arcadio/internal/crypto"
+	"github.com/ViniTamanhao/arcadio/internal/storage"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+// Arc metadata is kept as an encrypted snapshot (arc.meta) plus an
+// append-only log of encrypted change records (arc.log). Every commit
+// appends one frame holding the new state of the documents it touched, so
+// a single-document change writes a few hundred bytes instead of the whole
+// arc. Once the log outgrows the snapshot it is folded into a new snapshot.
+
+const (
+	// metadataVersion is the snapshot format written by this version
+	metadataVersion = 2
+
+	// compactMinLog is the log size below which compaction never happens
+	compactMinLog = 256 << 10
+
+	// batchFlushSize bounds how many changed documents a batch holds in
+	// memory before they are written out
+	batchFlushSize = 1000
+)
+
+// metaSnapshot is the decrypted content of arc.meta
+type metaSnapshot struct {
+	Version    int         `json:"metadata_version"`
+	Generation int64       `json:"generation"`
+	Arc        *models.Arc `json:"arc"`
+}
+
+// metaRecord is one change in the log. Records carry the full new state of
+// what they describe, so replaying them is idempotent.
+type metaRecord struct {
+	Generation int64             `json:"gen"`
+	Op         string            `json:"op"` // "arc", "doc" or "del"
+	Arc        *models.Arc       `json:"arc,omitempty"`
+	Doc        *models.Document  `json:"doc,omitempty"`
+	Tags       []string          `json:"tags,omitempty"`
+	Pack       *models.PackEntry `json:"pack,omitempty"`
+	DocID      string            `json:"doc_id,omitempty"`
+}
+
+// metaState tracks the on-storage metadata of an unlocked arc
+type metaState struct {
+	generation   int64
+	snapshotSize int64
+	logSize      int64
+}
+
+// logKey is the storage key of an arc's metadata log
+func logKey(arcID string) string {
+	return storage.Join(arcID, "arc.log")
+}
+
+// Batch runs fn and writes every metadata change it makes in a single log
+// append. Changes are written even if fn fails part way, so the stored
+// metadata matches the documents that were actually stored.
+func (m *Manager) Batch(arcID string, arc *models.Arc, key []byte, fn func() error) error {
+	m.mu.Lock()
+	if _, active := m.batches[arcID]; active {
+		m.mu.Unlock()
+		return fn()
+	}
+	m.batches[arcID] = make(map[string]bool)
+	m.mu.Unlock()
+
+	fnErr := fn()
+
+	m.mu.Lock()
+	pending := m.batches[arcID]
+	delete(m.batches, arcID)
+	indexed := m.indexPending[arcID]
+	delete(m.indexPending, arcID)
+	m.mu.Unlock()
+
+	if err := m.writeChanges(arcID, arc, key, pending); err != nil {
+		if fnErr != nil {
+			return fmt.Errorf("%w (also failed to save metadata: %v)", fnErr, err)
+		}
+		return err
+	}
+	m.appendIndex(arcID, key, indexed)
+	return fnErr
+}
+
+// commit records that the given documents (and the arc header) changed.
+// Inside a Batch the write is deferred until the batch ends.
+func (m *Manager) commit(arcID string, arc *models.Arc, key []byte, docIDs ...string) error {
+	arc.ModifiedAt = time.Now()
+
+	m.mu.Lock()
+	pending, batching := m.batches[arcID]
+	if batching {
+		for _, id := range docIDs {
+			pending[id] = true
+		}
+		if len(pending) < batchFlushSize {
+			m.mu.Unlock()
+			return nil
+		}
+		m.batches[arcID] = make(map[string]bool)
+	}
+	m.mu.Unlock()
+
+	if !batching {
+		pending = make(map[string]bool, len(docIDs))
+		for _, id := range docIDs {
+			pending[id] = true
+		}
+	}
+	return m.writeChanges(arcID, arc, key, pending)
+}
+
+// writeChanges appends one log frame describing the changed documents and
+// compacts the log when it has grown past the snapshot
+func (m *Manager) writeChanges(arcID string, arc *models.Arc, key []byte, docIDs map[string]bool) error {
+	if err := m.flushPack(arcID); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	state, known := m.meta[arcID]
+	m.mu.Unlock()
+	if !known {
+		return m.saveArcMetadata(arcID, arc, key)
+	}
+
+	records := []metaRecord{{Generation: state.generation, Op: "arc", Arc: arcHeader(arc)}}
+	for docID := range docIDs {
+		doc, exists := arc.Documents[docID]
+		if !exists {
+			records = append(records, metaRecord{Generation: state.generation, Op: "del", DocID: docID})
+			continue
+		}
+
+		rec := metaRecord{Generation: state.generation, Op: "doc", Doc: doc, Tags: arc.Tags[docID]}
+		if arc.Packs != nil {
+			rec.Pack = arc.Packs.Index[docID]
+		}
+		records = append(records, rec)
+	}
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	n, err := m.appendFrame(logKey(arcID), key, data)
+	if err != nil {
+		return fmt.Errorf("failed to append metadata log: %w", err)
+	}
+
+	m.mu.Lock()
+	state.logSize += n
+	compact := state.logSize > compactMinLog && state.logSize > state.snapshotSize
+	m.mu.Unlock()
+
+	if compact {
+		return m.saveArcMetadata(arcID, arc, key)
+	}
+	return nil
+}
+
+// replayLog applies the records of the current generation on top of a
+// snapshot. A torn final frame from an interrupted write is ignored.
+func (m *Manager) replayLog(arcID string, arc *models.Arc, key []byte, generation int64) (int64, error) {
+	return m.readFrames(logKey(arcID), key, func(plain []byte) error {
+		var records []metaRecord
+		if err := json.Unmarshal(plain, &records); err != nil {
+			return fmt.Errorf("invalid metadata log record: %w", err)
+		}
+		for _, rec := range records {
+			if rec.Generation == generation {
+				applyRecord(arc, rec)
+			}
+		}
+		return nil
+	})
+}
+
+// Logs are grown with native appends where the backend has them. Elsewhere
+// (S3) appending would download and re-upload the whole log, so each frame
+// is written as a segment object under "<log>.d/" instead, read back in
+// order after the log itself. Appending to a log with many segments folds
+// them into it.
+
+// maxLogSegments is the segment count at which appending folds a log
+const maxLogSegments = 64
+
+// segmentPrefix is where the segments of a log are kept
+func segmentPrefix(objectKey string) string {
+	return objectKey + ".d/"
+}
+
+// logSegments lists the segment keys of a log in order
+func (m *Manager) logSegments(objectKey string) ([]string, error) {
+	keys, err := m.store.List(segmentPrefix(objectKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log segments: %w", err)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// appendFrame encrypts data and appends it to an append-only log as one
+// length-prefixed frame, returning the number of bytes added
+func (m *Manager) appendFrame(objectKey string, key, data []byte) (int64, error) {
+	encrypted, err := crypto.Encrypt(key, data)
+	if err != nil {
+		return 0, err
+	}
+
+	frame := make([]byte, 4+len(encrypted))
+	binary.BigEndian.PutUint32(frame, uint32(len(encrypted)))
+	copy(frame[4:], encrypted)
+
+	m.mu.Lock()
+	torn := m.tornLogs[objectKey]
+	delete(m.tornLogs, objectKey)
+	m.mu.Unlock()
+	if torn {
+		if err := m.cutTornTail(objectKey); err != nil {
+			return 0, err
+		}
+	}
+
+	if storage.CanAppend(m.store) {
+		if _, err := storage.Append(m.store, objectKey, frame); err != nil {
+			return 0, err
+		}
+		return int64(len(frame)), nil
+	}
+
+	segments, err := m.logSegments(objectKey)
+	if err != nil {
+		return 0, err
+	}
+	if len(segments) >= maxLogSegments {
+		data, _, err := m.loadLog(objectKey)
+		if err != nil {
+			return 0, err
+		}
+		data = append(data[:completeFrames(data)], frame...)
+		if err := m.rewriteLog(objectKey, data); err != nil {
+			return 0, err
+		}
+		return int64(len(frame)), nil
+	}
+
+	seq := 1
+	if len(segments) > 0 {
+		last := path.Base(segments[len(segments)-1])
+		n, err := strconv.Atoi(last)
+		if err != nil {
+			return 0, fmt.Errorf("invalid log segment: %s", segments[len(segments)-1])
+		}
+		seq = n + 1
+	}
+	segment := segmentPrefix(objectKey) + fmt.Sprintf("%08d", seq)
+	if err := m.store.Put(segment, bytes.NewReader(frame)); err != nil {
+		return 0, err
+	}
+	return int64(len(frame)), nil
+}
+
+// reencryptFrames rewrites an append-only log under a new key
+func (m *Manager) reencryptFrames(objectKey string, oldKey, newKey []byte) error {
+	var buf bytes.Buffer
+	_, err := m.readFrames(objectKey, oldKey, func(plain []byte) error {
+		encrypted, err := crypto.Encrypt(newKey, plain)
+		if err != nil {
+			return err
+		}
+		binary.Write(&buf, binary.BigEndian, uint32(len(encrypted)))
+		buf.Write(encrypted)
+		return nil
+	})
+	if err != nil || buf.Len() == 0 {
+		return err
+	}
+	return m.rewriteLog(objectKey, buf.Bytes())
+}
+
+// loadLog reads an append-only log followed by its segments. A missing log
+// is empty.
+func (m *Manager) loadLog(objectKey string) ([]byte, []string, error) {
+	data, err := storage.ReadAll(m.store, objectKey)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return nil, nil, err
+	}
+
+	segments, err := m.logSegments(objectKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, segment := range segments {
+		frame, err := storage.ReadAll(m.store, segment)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return nil, nil, err
+		}
+		data = append(data, frame...)
+	}
+	return data, segments, nil
+}
+
+// completeFrames returns the size of the leading run of whole frames in data
+func completeFrames(data []byte) int {
+	pos := 0
+	for pos+4 <= len(data) {
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		if pos+4+size > len(data) {
+			break
+		}
+		pos += 4 + size
+	}
+	return pos
+}
+
+// readFrames decrypts the frames of an append-only log in order and returns
+// the size of the intact part. An incomplete final frame from an
+// interrupted write is skipped and cut off by the next append, so later
+// frames are not lost behind it. A whole frame that fails to decrypt is an
+// error. Reading never modifies the log.
+func (m *Manager) readFrames(objectKey string, key []byte, fn func(plain []byte) error) (int64, error) {
+	data, _, err := m.loadLog(objectKey)
+	if err != nil {
+		return 0, err
+	}
+
+	end := completeFrames(data)
+	for pos := 0; pos < end; {
+		size := int(binary.BigEndian.Uint32(data[pos:]))
+		plain, err := crypto.Decrypt(key, data[pos+4:pos+4+size])
+		if err != nil {
+			return 0, fmt.Errorf("failed to decrypt %s at offset %d: %w", objectKey, pos, err)
+		}
+		if err := fn(plain); err != nil {
+			return 0, err
+		}
+		pos += 4 + size
+	}
+
+	if end < len(data) {
+		m.mu.Lock()
+		m.tornLogs[objectKey] = true
+		m.mu.Unlock()
+	}
+	return int64(end), nil
+}
+
+// cutTornTail drops an incomplete final frame from a log
+func (m *Manager) cutTornTail(objectKey string) error {
+	data, _, err := m.loadLog(objectKey)
+	if err != nil {
+		return err
+	}
+	if end := completeFrames(data); end < len(data) {
+		return m.rewriteLog(objectKey, data[:end])
+	}
+	return nil
+}
+
+// rewriteLog replaces an append-only log, and any segments it has, with data.
+// A crash before the segments are gone replays their frames twice. Metadata
+// and index records hold full states, so only the purge log can then list
+// an entry twice.
+func (m *Manager) rewriteLog(objectKey string, data []byte) error {
+	segments, err := m.logSegments(objectKey)
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		err = m.store.Delete(objectKey)
+	} else {
+		err = m.store.Put(objectKey, bytes.NewReader(data))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to rewrite log %s: %w", objectKey, err)
+	}
+
+	for _, segment := range segments {
+		if err := m.store.Delete(segment); err != nil {
+			return fmt.Errorf("failed to delete log segment %s: %w", segment, err)
+		}
+	}
+	return nil
+}
+
+// applyRecord replays one log record onto arc
+func applyRecord(arc *models.Arc, rec metaRecord) {
+	switch rec.Op {
+	case "arc":
+		if rec.Arc == nil {
+			return
+		}
+		header := *rec.Arc
+		header.Documents = arc.Documents
+		header.Tags = arc.Tags
+		if header.Packs != nil {
+			header.Packs.Index = make(map[string]*models.PackEntry)
+			if arc.Packs != nil {
+				header.Packs.Index = arc.Packs.Index
+			}
+		}
+		*arc = header
+
+	case "doc":
+		if rec.Doc == nil {
+			return
+		}
+		arc.Documents[rec.Doc.ID] = rec.Doc
+		if len(rec.Tags) > 0 {
+			arc.Tags[rec.Doc.ID] = rec.Tags
+		} else {
+			delete(arc.Tags, rec.Doc.ID)
+		}
+		if arc.Packs != nil {
+			if rec.Pack != nil {
+				arc.Packs.Index[rec.Doc.ID] = rec.Pack
+			} else {
+				delete(arc.Packs.Index, rec.Doc.ID)
+			}
+		}
+
+	case "del":
+		delete(arc.Documents, rec.DocID)
+		delete(arc.Tags, rec.DocID)
+		if arc.Packs != nil {
+			delete(arc.Packs.Index, rec.DocID)
+		}
+	}
+}
+
+// arcHeader copies the arc-level fields without the per-document maps
+func arcHeader(arc *models.Arc) *models.Arc {
+	header := *arc
+	header.Documents = nil
+	header.Tags = nil
+	if arc.Packs != nil {
+		packs := *arc.Packs
+		packs.Index = nil
+		header.Packs = &packs
+	}
+	return &header
+}
+
+// encodeSnapshot serializes and encrypts a metadata snapshot
+func encodeSnapshot(arc *models.Arc, generation int64, key []byte) ([]byte, error) {
+	data, err := json.Marshal(metaSnapshot{
+		Version:    metadataVersion,
+		Generation: generation,
+		Arc:        arc,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Encrypt(key, data)
+}
+
+// decodeSnapshot decrypts arc.meta. Snapshots written before the metadata
+// log existed hold a bare models.Arc and are reported as legacy.
+func decodeSnapshot(encrypted, key []byte) (*metaSnapshot, bool, error) {
+	data, err := crypto.Decrypt(key, encrypted)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var snap metaSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, false, err
+	}
+	if snap.Version >= metadataVersion && snap.Arc != nil {
+		return &snap, false, nil
+	}
+
+	var arc models.Arc
+	if err := json.Unmarshal(data, &arc); err != nil {
+		return nil, false, err
+	}
+	return &metaSnapshot{Arc: &arc}, true, nil
+}
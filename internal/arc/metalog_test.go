@@ -0,0 +1,135 @@
+package arc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ViniTamanhao/arcadio/internal/storage"
+)
+
+func newTestManager(t *testing.T) (*Manager, storage.Storage) {
+	t.Helper()
+	store := storage.NewMemory()
+	return reopenManager(t, store), store
+}
+
+// reopenManager returns a fresh manager over store, as a new process would
+func reopenManager(t *testing.T, store storage.Storage) *Manager {
+	t.Helper()
+	registry, err := NewRegistry(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManagerWithStorage(store, registry)
+	m.SetOutput(io.Discard)
+	return m
+}
+
+func TestTornLogTail(t *testing.T) {
+	tails := []struct {
+		name string
+		tail []byte
+	}{
+		{"torn frame", []byte{0, 0, 0, 64, 1, 2, 3}},
+		{"torn length", []byte{0, 0}},
+	}
+	for _, tt := range tails {
+		for _, native := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s/append=%v", tt.name, native), func(t *testing.T) {
+				testTornLogTail(t, tt.tail, native)
+			})
+		}
+	}
+}
+
+// newTornArc creates an arc with one document and writes tail after its
+// metadata log, as an interrupted or corrupted write would
+func newTornArc(t *testing.T, tail []byte, native bool) storage.Storage {
+	t.Helper()
+	var store storage.Storage = storage.NewMemory()
+	if !native {
+		store = newObjectStore()
+	}
+	m := reopenManager(t, store)
+	if _, err := m.Create("torn", "password1", "q", "a"); err != nil {
+		t.Fatal(err)
+	}
+	arc, key, err := m.Unlock("torn", "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AddDocumentFromReader(arc.ID, arc, key, "first.txt", strings.NewReader("one"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if native {
+		_, err = storage.Append(store, logKey(arc.ID), tail)
+	} else {
+		err = store.Put(segmentPrefix(logKey(arc.ID))+"00000099", bytes.NewReader(tail))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// storedLog returns the raw bytes of the torn arc's metadata log
+func storedLog(t *testing.T, store storage.Storage) []byte {
+	t.Helper()
+	m := reopenManager(t, store)
+	entry, err := m.registry.FindArc("torn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _, err := m.loadLog(logKey(entry.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func testTornLogTail(t *testing.T, tail []byte, native bool) {
+	store := newTornArc(t, tail, native)
+	before := storedLog(t, store)
+
+	m := reopenManager(t, store)
+	arc, key, err := m.Unlock("torn", "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(storedLog(t, store), before) {
+		t.Error("unlocking rewrote the metadata log")
+	}
+	if _, err := m.AddDocumentFromReader(arc.ID, arc, key, "second.txt", strings.NewReader("two"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	m = reopenManager(t, store)
+	arc, _, err = m.Unlock("torn", "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(arc.Documents); n != 2 {
+		t.Fatalf("arc has %d documents after reopening, want 2", n)
+	}
+}
+
+func TestUndecryptableLogFrame(t *testing.T) {
+	for _, native := range []bool{true, false} {
+		t.Run(fmt.Sprintf("append=%v", native), func(t *testing.T) {
+			store := newTornArc(t, []byte{0, 0, 0, 4, 1, 2, 3, 4}, native)
+			before := storedLog(t, store)
+
+			m := reopenManager(t, store)
+			if _, _, err := m.Unlock("torn", "password1"); err == nil {
+				t.Fatal("Unlock succeeded with a corrupt log frame")
+			}
+			if !bytes.Equal(storedLog(t, store), before) {
+				t.Error("reading a corrupt log frame changed the log")
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package arc
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+// unknownType is reported for content that could not be identified
+const unknownType = "application/octet-stream"
+
+// extensionTypes covers common extensions the system MIME tables often lack
+var extensionTypes = map[string]string{
+	".md":       "text/markdown",
+	".markdown": "text/markdown",
+	".csv":      "text/csv",
+	".json":     "application/json",
+	".yaml":     "application/yaml",
+	".yml":      "application/yaml",
+	".toml":     "application/toml",
+	".log":      "text/plain",
+	".txt":      "text/plain",
+	".go":       "text/x-go",
+	".py":       "text/x-python",
+	".sh":       "application/x-sh",
+	".docx":     "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx":     "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx":     "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".heic":     "image/heic",
+}
+
+// typeExtensions is the preferred extension for a content type, used when
+// restoring a missing extension on export
+var typeExtensions = map[string]string{
+	"application/pdf":  ".pdf",
+	"application/zip":  ".zip",
+	"application/json": ".json",
+	"image/jpeg":       ".jpg",
+	"image/png":        ".png",
+	"image/gif":        ".gif",
+	"image/webp":       ".webp",
+	"image/bmp":        ".bmp",
+	"text/plain":       ".txt",
+	"text/html":        ".html",
+	"text/markdown":    ".md",
+	"text/csv":         ".csv",
+	"audio/mpeg":       ".mp3",
+	"audio/wave":       ".wav",
+	"video/mp4":        ".mp4",
+	"video/webm":       ".webm",
+}
+
+// DetectContentType identifies a document from its leading bytes, falling
+// back to the file extension when the content alone is not conclusive
+func DetectContentType(filename string, data []byte) string {
+	sniffed := baseType(http.DetectContentType(data))
+	byExt := typeByExtension(filename)
+
+	switch {
+	case sniffed == unknownType && byExt != "":
+		return byExt
+	case sniffed == "text/plain" && strings.HasPrefix(byExt, "text/"),
+		sniffed == "text/plain" && isTextApplication(byExt):
+		// Plain text sniffing cannot tell markdown, CSV or JSON apart
+		return byExt
+	case sniffed == "application/zip" && strings.HasPrefix(byExt, "application/vnd.openxmlformats"):
+		return byExt
+	}
+	return sniffed
+}
+
+// ContentTypeOf returns the stored content type of a document, guessing
+// from the extension for documents added before types were detected
+func ContentTypeOf(doc *models.Document) string {
+	if doc.ContentType != "" {
+		return doc.ContentType
+	}
+	if byExt := typeByExtension(doc.Filename); byExt != "" {
+		return byExt
+	}
+	return unknownType
+}
+
+// MatchesType reports whether a document matches a type filter. The filter
+// can be a full type (application/pdf), a family (image or image/*) or a
+// short name or extension (pdf, jpg, markdown).
+func MatchesType(doc *models.Document, filter string) bool {
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	if filter == "" {
+		return true
+	}
+
+	contentType := ContentTypeOf(doc)
+	family, subtype, _ := strings.Cut(contentType, "/")
+
+	if strings.Contains(filter, "/") {
+		if prefix, ok := strings.CutSuffix(filter, "/*"); ok {
+			return family == prefix
+		}
+		return contentType == filter
+	}
+
+	if family == filter || subtype == filter || strings.HasSuffix(subtype, "+"+filter) {
+		return true
+	}
+	return typeByExtension("x."+filter) == contentType
+}
+
+// ExtensionFor returns the usual file extension for a content type, or ""
+func ExtensionFor(contentType string) string {
+	if ext, ok := typeExtensions[contentType]; ok {
+		return ext
+	}
+	for ext, t := range extensionTypes {
+		if t == contentType {
+			return ext
+		}
+	}
+	if exts, err := mime.ExtensionsByType(contentType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// WithExtension appends the extension of the document's content type to p
+// when p has none
+func WithExtension(p string, doc *models.Document) string {
+	if filepath.Ext(p) != "" {
+		return p
+	}
+	return p + ExtensionFor(ContentTypeOf(doc))
+}
+
+// typeByExtension looks up a content type from a filename's extension
+func typeByExtension(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		return ""
+	}
+	if t, ok := extensionTypes[ext]; ok {
+		return t
+	}
+	return baseType(mime.TypeByExtension(ext))
+}
+
+// isTextApplication reports whether an application/ type is plain text
+func isTextApplication(contentType string) bool {
+	switch contentType {
+	case "application/json", "application/yaml", "application/toml", "application/x-sh", "application/xml":
+		return true
+	}
+	return false
+}
+
+// baseType strips parameters such as charset from a media type
+func baseType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	return strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+}
@@ -0,0 +1,197 @@
+package arc
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ViniTamanhao/arcadio/internal/crypto"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+// The policy lives in the encrypted arc metadata, so it is covered by the
+// same authentication as the documents list and cannot be edited without
+// the password. Its rules are enforced by the manager on every path that
+// removes or changes documents.
+
+var (
+	ErrImmutable     = errors.New("arc is immutable")
+	ErrLegalHold     = errors.New("document is under legal hold")
+	ErrQuota         = errors.New("arc size limit reached")
+	ErrAdminRequired = errors.New("the admin passphrase is required to loosen the policy")
+)
+
+// PolicyUpdate describes a change to an arc's policy. Nil fields are left
+// as they are.
+type PolicyUpdate struct {
+	ImmutableUntil  *time.Time // new retention date; the zero time clears it
+	MaxSize         *int64     // new size limit in bytes; 0 clears it
+	AdminPassphrase string     // sets or replaces the admin passphrase
+}
+
+// ArcSize returns the total size of the documents in an arc
+func ArcSize(arc *models.Arc) int64 {
+	var total int64
+	for _, doc := range arc.Documents {
+		total += doc.Size
+	}
+	return total
+}
+
+// Immutable reports whether documents of the arc are locked at time now
+func Immutable(arc *models.Arc, now time.Time) bool {
+	return arc.Policy != nil && arc.Policy.ImmutableUntil != nil && now.Before(*arc.Policy.ImmutableUntil)
+}
+
+// CheckChange returns an error if the policy forbids removing or changing doc
+func CheckChange(arc *models.Arc, doc *models.Document) error {
+	if Immutable(arc, time.Now()) {
+		return fmt.Errorf("cannot change /%s: %w until %s", doc.Path(), ErrImmutable, arc.Policy.ImmutableUntil.Format("2006-01-02"))
+	}
+	if doc.LegalHold {
+		return fmt.Errorf("cannot change /%s: %w", doc.Path(), ErrLegalHold)
+	}
+	return nil
+}
+
+// checkRemovable checks every document before a bulk removal starts
+func checkRemovable(arc *models.Arc, docIDs []string) error {
+	for _, docID := range docIDs {
+		if doc, exists := arc.Documents[docID]; exists {
+			if err := CheckChange(arc, doc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkQuota returns an error if adding extra bytes would exceed the size limit
+func checkQuota(arc *models.Arc, extra int64) error {
+	if arc.Policy == nil || arc.Policy.MaxSize <= 0 || extra <= 0 {
+		return nil
+	}
+	if total := ArcSize(arc) + extra; total > arc.Policy.MaxSize {
+		return fmt.Errorf("%w: %d of %d bytes used, %d more needed", ErrQuota, ArcSize(arc), arc.Policy.MaxSize, extra)
+	}
+	return nil
+}
+
+// checkDeletable returns an error if the policy forbids deleting the arc
+func checkDeletable(arc *models.Arc) error {
+	if Immutable(arc, time.Now()) {
+		return fmt.Errorf("cannot delete arc %s: %w until %s", arc.Name, ErrImmutable, arc.Policy.ImmutableUntil.Format("2006-01-02"))
+	}
+	for _, doc := range arc.Documents {
+		if doc.LegalHold {
+			return fmt.Errorf("cannot delete arc %s: /%s is under legal hold", arc.Name, doc.Path())
+		}
+	}
+	return nil
+}
+
+// SetPolicy applies a policy change. Changes that only tighten the policy
+// are always allowed; anything that loosens it (an earlier or cleared
+// retention date, a higher or cleared size limit, a new admin passphrase
+// replacing an old one) must be authorized with the current admin passphrase.
+func (m *Manager) SetPolicy(arcID string, arc *models.Arc, key []byte, update PolicyUpdate, adminPassphrase string) error {
+	now := time.Now()
+	current := models.Policy{}
+	if arc.Policy != nil {
+		current = *arc.Policy
+	}
+	next := current
+	loosens := false
+
+	if update.ImmutableUntil != nil {
+		if update.ImmutableUntil.IsZero() {
+			next.ImmutableUntil = nil
+		} else {
+			until := *update.ImmutableUntil
+			next.ImmutableUntil = &until
+		}
+		if current.ImmutableUntil != nil && now.Before(*current.ImmutableUntil) &&
+			(next.ImmutableUntil == nil || next.ImmutableUntil.Before(*current.ImmutableUntil)) {
+			loosens = true
+		}
+	}
+
+	if update.MaxSize != nil {
+		next.MaxSize = *update.MaxSize
+		if next.MaxSize < 0 {
+			return fmt.Errorf("invalid size limit: %d", next.MaxSize)
+		}
+		if next.MaxSize > 0 && next.MaxSize < ArcSize(arc) {
+			return fmt.Errorf("arc already holds %d bytes, more than the new limit of %d", ArcSize(arc), next.MaxSize)
+		}
+		if current.MaxSize > 0 && (next.MaxSize == 0 || next.MaxSize > current.MaxSize) {
+			loosens = true
+		}
+	}
+
+	if update.AdminPassphrase != "" {
+		if current.AdminHash != nil {
+			loosens = true
+		}
+		salt, err := crypto.GenerateSalt()
+		if err != nil {
+			return fmt.Errorf("failed to generate salt: %w", err)
+		}
+		next.AdminSalt = salt
+		next.AdminHash = crypto.DeriveKey(update.AdminPassphrase, salt)
+	}
+
+	if loosens {
+		if err := verifyAdmin(&current, adminPassphrase); err != nil {
+			return err
+		}
+	}
+
+	arc.Policy = &next
+	if next.ImmutableUntil == nil && next.MaxSize == 0 && next.AdminHash == nil {
+		arc.Policy = nil
+	}
+	return m.commit(arcID, arc, key)
+}
+
+// SetLegalHold places or releases a legal hold on documents. Releasing a
+// hold loosens the policy and needs the admin passphrase.
+func (m *Manager) SetLegalHold(arcID string, arc *models.Arc, key []byte, docIDs []string, hold bool, adminPassphrase string) error {
+	for _, docID := range docIDs {
+		if _, exists := arc.Documents[docID]; !exists {
+			return fmt.Errorf("document not found: %s", docID)
+		}
+	}
+
+	if !hold {
+		if err := verifyAdmin(arc.Policy, adminPassphrase); err != nil {
+			return err
+		}
+	}
+
+	for _, docID := range docIDs {
+		arc.Documents[docID].LegalHold = hold
+	}
+	return m.commit(arcID, arc, key, docIDs...)
+}
+
+// NeedsAdmin reports whether the arc has an admin passphrase
+func NeedsAdmin(arc *models.Arc) bool {
+	return arc.Policy != nil && arc.Policy.AdminHash != nil
+}
+
+// verifyAdmin checks the admin passphrase of a policy
+func verifyAdmin(policy *models.Policy, passphrase string) error {
+	if policy == nil || policy.AdminHash == nil {
+		return fmt.Errorf("%w, and this arc has none: the policy can only be tightened", ErrAdminRequired)
+	}
+	if passphrase == "" {
+		return ErrAdminRequired
+	}
+	hash := crypto.DeriveKey(passphrase, policy.AdminSalt)
+	if subtle.ConstantTimeCompare(hash, policy.AdminHash) != 1 {
+		return fmt.Errorf("invalid admin passphrase")
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package arc
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+// dateLayouts are the accepted formats for date properties
+var dateLayouts = []string{"2006-01-02", time.RFC3339, "2006-01-02 15:04", "2006-01-02T15:04"}
+
+// propertyName restricts property names to something usable on the command line
+var propertyName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// whereOperators are checked longest first so ">=" is not read as ">"
+var whereOperators = []string{">=", "<=", "!=", ">", "<", "=", "~"}
+
+// PropertyFilter is a single comparison against a document property,
+// e.g. amount>1000 or vendor=ACME
+type PropertyFilter struct {
+	Name  string
+	Op    string
+	Value string
+}
+
+// ParseProperty turns a raw value into a typed property. With an empty
+// type, number, date or bool is only picked when storing the value as that
+// type keeps it exactly as written, so 02134, 1.50, T or NaN stay strings.
+func ParseProperty(raw, typ string) (*models.Property, error) {
+	switch typ {
+	case "":
+		for _, t := range []string{models.PropertyNumber, models.PropertyDate, models.PropertyBool} {
+			if prop, err := ParseProperty(raw, t); err == nil && prop.Value == raw {
+				return prop, nil
+			}
+		}
+		return &models.Property{Type: models.PropertyString, Value: raw}, nil
+
+	case models.PropertyString:
+		return &models.Property{Type: typ, Value: raw}, nil
+
+	case models.PropertyNumber:
+		n, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, fmt.Errorf("not a number: %s", raw)
+		}
+		return &models.Property{Type: typ, Value: strconv.FormatFloat(n, 'f', -1, 64)}, nil
+
+	case models.PropertyDate:
+		t, err := parseDate(raw)
+		if err != nil {
+			return nil, err
+		}
+		value := t.Format("2006-01-02")
+		if t.Hour() != 0 || t.Minute() != 0 || t.Second() != 0 {
+			value = t.Format(time.RFC3339)
+		}
+		return &models.Property{Type: typ, Value: value}, nil
+
+	case models.PropertyBool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("not a boolean: %s", raw)
+		}
+		return &models.Property{Type: typ, Value: strconv.FormatBool(b)}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown property type: %s (use string, number, date or bool)", typ)
+	}
+}
+
+// SetProperty sets a custom property on a document
+func (m *Manager) SetProperty(arcID string, arc *models.Arc, key []byte, docID, name string, prop *models.Property) error {
+	doc, exists := arc.Documents[docID]
+	if !exists {
+		return fmt.Errorf("document not found: %s", docID)
+	}
+	if !propertyName.MatchString(name) {
+		return fmt.Errorf("invalid property name: %q", name)
+	}
+	if _, replaces := doc.Properties[name]; replaces {
+		if err := CheckChange(arc, doc); err != nil {
+			return err
+		}
+	}
+
+	if doc.Properties == nil {
+		doc.Properties = make(map[string]*models.Property)
+	}
+	doc.Properties[name] = prop
+	doc.ModifiedAt = time.Now()
+
+	return m.commit(arcID, arc, key, docID)
+}
+
+// UnsetProperty removes custom properties from a document
+func (m *Manager) UnsetProperty(arcID string, arc *models.Arc, key []byte, docID string, names []string) error {
+	doc, exists := arc.Documents[docID]
+	if !exists {
+		return fmt.Errorf("document not found: %s", docID)
+	}
+	if err := CheckChange(arc, doc); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		delete(doc.Properties, name)
+	}
+	if len(doc.Properties) == 0 {
+		doc.Properties = nil
+	}
+	doc.ModifiedAt = time.Now()
+
+	return m.commit(arcID, arc, key, docID)
+}
+
+// ParseWhere parses a property comparison such as amount>1000
+func ParseWhere(expr string) (*PropertyFilter, error) {
+	for i := 0; i < len(expr); i++ {
+		for _, op := range whereOperators {
+			if strings.HasPrefix(expr[i:], op) {
+				name := strings.TrimSpace(expr[:i])
+				if !propertyName.MatchString(name) {
+					return nil, fmt.Errorf("invalid filter %q: bad property name", expr)
+				}
+				return &PropertyFilter{
+					Name:  name,
+					Op:    op,
+					Value: strings.TrimSpace(expr[i+len(op):]),
+				}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid filter %q: expected name, operator (= != > >= < <= ~) and value", expr)
+}
+
+// Match reports whether a document satisfies the filter. Documents without
+// the property only match "!=".
+func (f *PropertyFilter) Match(doc *models.Document) bool {
+	prop, exists := doc.Properties[f.Name]
+	if !exists {
+		return f.Op == "!="
+	}
+	return CompareProperty(prop, f.Op, f.Value)
+}
+
+// CompareProperty compares a property against a raw value using its type
+func CompareProperty(prop *models.Property, op, raw string) bool {
+	if op == "~" {
+		return strings.Contains(strings.ToLower(prop.Value), strings.ToLower(raw))
+	}
+
+	cmp, ok := comparePropertyValue(prop, raw)
+	if !ok {
+		return op == "!="
+	}
+
+	switch op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// comparePropertyValue returns -1, 0 or 1, and false when raw cannot be
+// read as the property's type
+func comparePropertyValue(prop *models.Property, raw string) (int, bool) {
+	switch prop.Type {
+	case models.PropertyNumber:
+		a, err1 := strconv.ParseFloat(prop.Value, 64)
+		b, err2 := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err1 != nil || err2 != nil {
+			return 0, false
+		}
+		return compareOrdered(a, b), true
+
+	case models.PropertyDate:
+		a, err1 := parseDate(prop.Value)
+		b, err2 := parseDate(raw)
+		if err1 != nil || err2 != nil {
+			return 0, false
+		}
+		return a.Compare(b), true
+
+	case models.PropertyBool:
+		a, err1 := strconv.ParseBool(prop.Value)
+		b, err2 := strconv.ParseBool(strings.TrimSpace(raw))
+		if err1 != nil || err2 != nil {
+			return 0, false
+		}
+		if a == b {
+			return 0, true
+		}
+		if !a {
+			return -1, true
+		}
+		return 1, true
+
+	default:
+		return strings.Compare(strings.ToLower(prop.Value), strings.ToLower(raw)), true
+	}
+}
+
+func compareOrdered(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// parseDate accepts the date formats allowed for date properties
+func parseDate(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, raw, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("not a date: %s (use YYYY-MM-DD)", raw)
+}
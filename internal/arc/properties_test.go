@@ -0,0 +1,65 @@
+package arc
+
+import (
+	"testing"
+
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+func TestParseProperty(t *testing.T) {
+	tests := []struct {
+		raw       string
+		typ       string
+		wantType  string
+		wantValue string
+		wantErr   bool
+	}{
+		// Inferred only when the value survives unchanged
+		{"42", "", models.PropertyNumber, "42", false},
+		{"-3.25", "", models.PropertyNumber, "-3.25", false},
+		{"1299.5", "", models.PropertyNumber, "1299.5", false},
+		{"02134", "", models.PropertyString, "02134", false},
+		{"1.50", "", models.PropertyString, "1.50", false},
+		{"1e3", "", models.PropertyString, "1e3", false},
+		{" 7", "", models.PropertyString, " 7", false},
+		{"NaN", "", models.PropertyString, "NaN", false},
+		{"inf", "", models.PropertyString, "inf", false},
+		{"+Inf", "", models.PropertyString, "+Inf", false},
+		{"2026-11-01", "", models.PropertyDate, "2026-11-01", false},
+		{"2026-11-01 10:30", "", models.PropertyString, "2026-11-01 10:30", false},
+		{"true", "", models.PropertyBool, "true", false},
+		{"false", "", models.PropertyBool, "false", false},
+		{"T", "", models.PropertyString, "T", false},
+		{"F", "", models.PropertyString, "F", false},
+		{"TRUE", "", models.PropertyString, "TRUE", false},
+		{"ACME", "", models.PropertyString, "ACME", false},
+
+		// An explicit type converts
+		{"02134", models.PropertyNumber, models.PropertyNumber, "2134", false},
+		{"1.50", models.PropertyNumber, models.PropertyNumber, "1.5", false},
+		{"T", models.PropertyBool, models.PropertyBool, "true", false},
+		{"42", models.PropertyString, models.PropertyString, "42", false},
+		{"NaN", models.PropertyNumber, "", "", true},
+		{"-Inf", models.PropertyNumber, "", "", true},
+		{"abc", models.PropertyNumber, "", "", true},
+		{"maybe", models.PropertyBool, "", "", true},
+		{"x", "color", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ+"/"+tt.raw, func(t *testing.T) {
+			prop, err := ParseProperty(tt.raw, tt.typ)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseProperty = %+v, want an error", prop)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseProperty: %v", err)
+			}
+			if prop.Type != tt.wantType || prop.Value != tt.wantValue {
+				t.Errorf("ParseProperty = %s %q, want %s %q", prop.Type, prop.Value, tt.wantType, tt.wantValue)
+			}
+		})
+	}
+}
@@ -0,0 +1,610 @@
+package arc
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+// Query is a parsed document query, e.g.
+//
+//	tag:legal AND (name:*.pdf OR type:image) size>1MB NOT tag:draft
+//
+// Terms next to each other must all match; AND, OR, NOT (or a leading -)
+// and parentheses combine them. A term is either a field comparison or a
+// plain word matched against paths and tags like a search, tolerating
+// typos. Quoted text is matched as written.
+type Query struct {
+	text string
+	root queryNode
+	rank []rankTerm // plain words and phrases outside NOT, used for ranking
+}
+
+// rankTerm is a plain search word, or a quoted phrase
+type rankTerm struct {
+	text   []rune
+	phrase bool
+}
+
+// QueryError is a query that could not be parsed, with the position of
+// the problem
+type QueryError struct {
+	Query string
+	Pos   int // byte offset into Query
+	Msg   string
+}
+
+func (e *QueryError) Error() string {
+	col := utf8.RuneCountInString(e.Query[:min(e.Pos, len(e.Query))])
+	return fmt.Sprintf("invalid query: %s\n  %s\n  %s^", e.Msg, e.Query, strings.Repeat(" ", col))
+}
+
+// queryFields lists the fields a term can compare, for error messages
+const queryFields = "tag, name, path, folder, type, size, added, modified, expires or prop.<name>"
+
+// queryTerm splits a field comparison such as size>=1MB
+var queryTerm = regexp.MustCompile(`^([A-Za-z]+(?:\.[A-Za-z0-9_.-]+)?)(>=|<=|!=|:|=|>|<|~)(.*)$`)
+
+// String returns the query as it was written
+func (q *Query) String() string {
+	return q.text
+}
+
+// Match reports whether a document satisfies the query
+func (q *Query) Match(arc *models.Arc, doc *models.Document) bool {
+	return q.root.match(arc, doc, newDocText(arc, doc))
+}
+
+// Filter returns the documents satisfying the query, keeping their order
+func (q *Query) Filter(arc *models.Arc, docs []*models.Document) []*models.Document {
+	var matched []*models.Document
+	for _, doc := range docs {
+		if q.Match(arc, doc) {
+			matched = append(matched, doc)
+		}
+	}
+	return matched
+}
+
+// ParseQuery parses a document query
+func ParseQuery(text string) (*Query, error) {
+	tokens, err := lexQuery(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{text: text, tokens: tokens}
+	if p.peek().kind == tokEnd {
+		return nil, &QueryError{Query: text, Pos: 0, Msg: "empty query"}
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEnd {
+		return nil, p.errorAt(tok, fmt.Sprintf("unexpected %s", tok))
+	}
+
+	q := &Query{text: text, root: root}
+	collectRankTerms(root, false, &q.rank)
+	return q, nil
+}
+
+// collectRankTerms gathers the plain words that are not negated
+func collectRankTerms(node queryNode, negated bool, out *[]rankTerm) {
+	switch n := node.(type) {
+	case *andNode:
+		collectRankTerms(n.left, negated, out)
+		collectRankTerms(n.right, negated, out)
+	case *orNode:
+		collectRankTerms(n.left, negated, out)
+		collectRankTerms(n.right, negated, out)
+	case *notNode:
+		collectRankTerms(n.node, !negated, out)
+	case *wordNode:
+		if !negated {
+			*out = append(*out, rankTerm{text: n.text})
+		}
+	case *phraseNode:
+		if !negated {
+			*out = append(*out, rankTerm{text: n.text, phrase: true})
+		}
+	}
+}
+
+// Query tokens
+const (
+	tokEnd = iota
+	tokWord
+	tokAnd
+	tokOr
+	tokNot
+	tokOpen
+	tokClose
+)
+
+type queryToken struct {
+	kind   int
+	text   string // the word with quotes removed
+	quoted bool   // the whole word was quoted
+	pos    int
+}
+
+func (t queryToken) String() string {
+	switch t.kind {
+	case tokEnd:
+		return "end of query"
+	case tokAnd, tokOr, tokNot:
+		return t.text
+	case tokOpen:
+		return `"("`
+	case tokClose:
+		return `")"`
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lexQuery splits a query into words, operators and parentheses. Quotes
+// group text containing spaces or parentheses, inside or around a word.
+func lexQuery(text string) ([]queryToken, error) {
+	var tokens []queryToken
+	i := 0
+	for i < len(text) {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, queryToken{kind: tokOpen, pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, queryToken{kind: tokClose, pos: i})
+			i++
+		case c == '-' && i+1 < len(text) && !strings.ContainsRune(" \t\n)", rune(text[i+1])):
+			tokens = append(tokens, queryToken{kind: tokNot, text: "-", pos: i})
+			i++
+		default:
+			start := i
+			var b strings.Builder
+			quotedParts, parts := 0, 0
+			for i < len(text) && !strings.ContainsRune(" \t\n()", rune(text[i])) {
+				if text[i] != '"' {
+					b.WriteByte(text[i])
+					i++
+					parts++
+					continue
+				}
+				end := strings.IndexByte(text[i+1:], '"')
+				if end < 0 {
+					return nil, &QueryError{Query: text, Pos: i, Msg: "unterminated quote"}
+				}
+				b.WriteString(text[i+1 : i+1+end])
+				i += end + 2
+				quotedParts++
+			}
+
+			tok := queryToken{kind: tokWord, text: b.String(), pos: start, quoted: quotedParts > 0 && parts == 0}
+			if !tok.quoted {
+				switch tok.text {
+				case "AND":
+					tok.kind = tokAnd
+				case "OR":
+					tok.kind = tokOr
+				case "NOT":
+					tok.kind = tokNot
+				}
+			}
+			tokens = append(tokens, tok)
+		}
+	}
+	return append(tokens, queryToken{kind: tokEnd, pos: len(text)}), nil
+}
+
+// queryParser is a recursive descent parser over:
+//
+//	or    = and { OR and }
+//	and   = unary { [AND] unary }
+//	unary = (NOT | -) unary | "(" or ")" | term
+type queryParser struct {
+	text   string
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEnd {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *queryParser) errorAt(tok queryToken, msg string) error {
+	return &QueryError{Query: p.text, Pos: tok.pos, Msg: msg}
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case tokAnd:
+			p.next()
+		case tokWord, tokNot, tokOpen:
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left, right}
+	}
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNot:
+		if p.peek().kind == tokEnd {
+			return nil, p.errorAt(p.peek(), fmt.Sprintf("expected a term after %s", tok.text))
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{node}, nil
+
+	case tokOpen:
+		if p.peek().kind == tokClose {
+			return nil, p.errorAt(p.peek(), "empty parentheses")
+		}
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokClose {
+			return nil, p.errorAt(p.peek(), fmt.Sprintf(`expected ")" to close the "(" at position %d, found %s`, tok.pos+1, p.peek()))
+		}
+		p.next()
+		return node, nil
+
+	case tokWord:
+		return p.parseTerm(tok)
+
+	case tokEnd:
+		return nil, p.errorAt(tok, "expected a term, found end of query")
+	case tokClose:
+		return nil, p.errorAt(tok, `unexpected ")" without a matching "("`)
+	default:
+		return nil, p.errorAt(tok, fmt.Sprintf("expected a term before %s", tok))
+	}
+}
+
+// parseTerm reads a field comparison, a quoted phrase or a plain word
+func (p *queryParser) parseTerm(tok queryToken) (queryNode, error) {
+	if tok.quoted {
+		text := fold(tok.text).runes
+		if len(text) == 0 {
+			return nil, p.errorAt(tok, "empty quoted phrase")
+		}
+		return &phraseNode{text}, nil
+	}
+
+	m := queryTerm.FindStringSubmatch(tok.text)
+	if m == nil {
+		return &wordNode{fold(tok.text).runes}, nil
+	}
+
+	field, op, value := strings.ToLower(m[1]), m[2], m[3]
+	if value == "" {
+		return nil, p.errorAt(tok, fmt.Sprintf("missing value after %s%s", m[1], op))
+	}
+	node, err := newFieldNode(field, op, value)
+	if err != nil {
+		return nil, p.errorAt(tok, err.Error())
+	}
+	return node, nil
+}
+
+// newFieldNode validates a field comparison and parses its value
+func newFieldNode(field, op, value string) (queryNode, error) {
+	if name, ok := strings.CutPrefix(field, "prop."); ok {
+		if op == ":" {
+			op = "="
+		}
+		return &propNode{&PropertyFilter{Name: name, Op: op, Value: value}}, nil
+	}
+
+	switch field {
+	case "tag", "name", "path", "type", "folder":
+		if op != ":" && op != "=" && op != "!=" {
+			return nil, fmt.Errorf("%s does not support %q (use %s:<value> or %s!=<value>)", field, op, field, field)
+		}
+		node := &matchNode{field: field, exact: op == "=", value: strings.ToLower(value)}
+		if field == "tag" {
+			node.value = NormalizeTag(value)
+		}
+		if field == "folder" {
+			folder, err := CleanFolder(value)
+			if err != nil {
+				return nil, err
+			}
+			node.value = folder
+		}
+		if strings.ContainsAny(node.value, "*?[") {
+			if _, err := path.Match(node.value, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q", value)
+			}
+		}
+		if op == "!=" {
+			return &notNode{node}, nil
+		}
+		return node, nil
+
+	case "size":
+		if op == "~" {
+			return nil, fmt.Errorf(`size does not support "~"`)
+		}
+		size, err := ParseSize(value)
+		if err != nil {
+			return nil, err
+		}
+		return &sizeNode{op: op, size: size}, nil
+
+	case "added", "modified", "expires":
+		if op == "~" {
+			return nil, fmt.Errorf(`%s does not support "~"`, field)
+		}
+		node := &dateNode{field: field, op: op}
+		if field == "expires" && strings.EqualFold(value, "never") {
+			if op != ":" && op != "=" && op != "!=" {
+				return nil, fmt.Errorf("expires:never cannot be compared with %q", op)
+			}
+			node.never = true
+			return node, nil
+		}
+		t, dateOnly, err := parseQueryDate(field, value, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		node.value, node.dateOnly = t, dateOnly
+		return node, nil
+	}
+
+	return nil, fmt.Errorf("unknown field %q (use %s; quote the term to search for it as text)", field, queryFields)
+}
+
+// parseQueryDate reads a date, "today", or a period such as 30d meaning
+// that long ago, so added>=30d is the last 30 days. For expires a period
+// is that long from now.
+func parseQueryDate(field, value string, now time.Time) (time.Time, bool, error) {
+	if strings.EqualFold(value, "today") {
+		y, m, d := now.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.Local), true, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, true, nil
+	}
+	if t, err := parseDate(value); err == nil {
+		return t, false, nil
+	}
+
+	t, err := AddPeriod(now, value)
+	if err == nil {
+		if field != "expires" {
+			t = now.Add(now.Sub(t))
+		}
+		return t, false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date %q (use e.g. 2025-01-31, today or 30d)", value)
+}
+
+// ParseSize reads a size such as 1.5MB, 500KB or 1024
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	units := []struct {
+		suffix string
+		factor int64
+	}{{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+	for _, unit := range units {
+		if num, ok := strings.CutSuffix(s, unit.suffix); ok {
+			n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid size: %s", s)
+			}
+			return int64(n * float64(unit.factor)), nil
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s (use e.g. 500KB or 2GB)", s)
+	}
+	return n, nil
+}
+
+// queryNode is one part of a parsed query
+type queryNode interface {
+	match(arc *models.Arc, doc *models.Document, text *docText) bool
+}
+
+type andNode struct{ left, right queryNode }
+
+func (n *andNode) match(arc *models.Arc, doc *models.Document, text *docText) bool {
+	return n.left.match(arc, doc, text) && n.right.match(arc, doc, text)
+}
+
+type orNode struct{ left, right queryNode }
+
+func (n *orNode) match(arc *models.Arc, doc *models.Document, text *docText) bool {
+	return n.left.match(arc, doc, text) || n.right.match(arc, doc, text)
+}
+
+type notNode struct{ node queryNode }
+
+func (n *notNode) match(arc *models.Arc, doc *models.Document, text *docText) bool {
+	return !n.node.match(arc, doc, text)
+}
+
+// wordNode is a plain word, matched like a search term
+type wordNode struct{ text []rune }
+
+func (n *wordNode) match(arc *models.Arc, doc *models.Document, text *docText) bool {
+	score, _, _ := text.matchTerm(n.text)
+	return score > 0
+}
+
+// phraseNode is quoted text that must appear in the path or a tag
+type phraseNode struct{ text []rune }
+
+func (n *phraseNode) match(arc *models.Arc, doc *models.Document, text *docText) bool {
+	score, _, _ := text.matchPhrase(n.text)
+	return score > 0
+}
+
+// matchNode compares a text field. Values with * ? or [ are glob
+// patterns; otherwise ":" matches part of the value and "=" all of it.
+// Types are always matched whole, and tag:finance also matches descendants
+// such as finance/taxes.
+type matchNode struct {
+	field string
+	exact bool
+	value string
+}
+
+func (n *matchNode) match(arc *models.Arc, doc *models.Document, text *docText) bool {
+	switch n.field {
+	case "tag":
+		for _, tag := range arc.Tags[doc.ID] {
+			tag = NormalizeTag(tag)
+			if n.matches(tag, true) || !n.exact && !strings.ContainsAny(n.value, "*?[") && TagWithin(tag, n.value) {
+				return true
+			}
+		}
+		return false
+	case "name":
+		return n.matches(strings.ToLower(doc.Filename), n.exact)
+	case "path":
+		return n.matches(strings.ToLower(doc.Path()), n.exact)
+	case "folder":
+		if strings.ContainsAny(n.value, "*?[") {
+			return n.matches(strings.ToLower(doc.Folder), true)
+		}
+		return inFolder(strings.ToLower(doc.Folder), n.value)
+	case "type":
+		return MatchesType(doc, n.value)
+	}
+	return false
+}
+
+func (n *matchNode) matches(s string, exact bool) bool {
+	if strings.ContainsAny(n.value, "*?[") {
+		ok, _ := path.Match(n.value, s)
+		return ok
+	}
+	if exact {
+		return s == n.value
+	}
+	return strings.Contains(s, n.value)
+}
+
+// sizeNode compares the document size
+type sizeNode struct {
+	op   string
+	size int64
+}
+
+func (n *sizeNode) match(arc *models.Arc, doc *models.Document, text *docText) bool {
+	return compareWith(n.op, compareOrdered(float64(doc.Size), float64(n.size)))
+}
+
+// dateNode compares a document timestamp. Plain dates compare whole days,
+// so added=2025-01-31 matches anything added that day.
+type dateNode struct {
+	field    string
+	op       string
+	value    time.Time
+	dateOnly bool
+	never    bool // expires:never, for documents that do not expire
+}
+
+func (n *dateNode) match(arc *models.Arc, doc *models.Document, text *docText) bool {
+	var t time.Time
+	switch n.field {
+	case "added":
+		t = doc.AddedAt
+	case "modified":
+		t = doc.ModifiedAt
+	case "expires":
+		expiry, _, expires := ExpiryOf(arc, doc)
+		if n.never {
+			return !expires == (n.op != "!=")
+		}
+		if !expires {
+			return n.op == "!="
+		}
+		t = expiry
+	}
+
+	if n.dateOnly {
+		y, m, d := t.In(time.Local).Date()
+		t = time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	}
+	return compareWith(n.op, t.Compare(n.value))
+}
+
+// propNode compares a custom property
+type propNode struct{ filter *PropertyFilter }
+
+func (n *propNode) match(arc *models.Arc, doc *models.Document, text *docText) bool {
+	return n.filter.Match(doc)
+}
+
+// compareWith applies a comparison operator to the result of a comparison
+func compareWith(op string, cmp int) bool {
+	switch op {
+	case ":", "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
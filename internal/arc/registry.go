@@ -1,12 +1,16 @@
 package arc
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 	"time"
+
+	"github.com/ViniTamanhao/arcadio/internal/storage"
 )
+
+const registryKey = "registry.json"
  
 type ArcEntry struct {
 	ID        string    `json:"id"`
@@ -15,21 +19,19 @@ type ArcEntry struct {
 }
 
 type Registry struct {
-	configPath string
-	Arcs       map[string]*ArcEntry `json:"arcs"` // ID -> ArcEntry
+	store storage.Storage
+	Arcs  map[string]*ArcEntry `json:"arcs"` // ID -> ArcEntry
 }
 
-// NewRegistry creates or loads the arc registry
-func NewRegistry(baseDir string) (*Registry, error) {
-	configPath := filepath.Join(baseDir, "..", "registry.json")
-	
+// NewRegistry creates or loads the arc registry kept in store
+func NewRegistry(store storage.Storage) (*Registry, error) {
 	registry := &Registry{
-		configPath: configPath,
-		Arcs:       make(map[string]*ArcEntry),
+		store: store,
+		Arcs:  make(map[string]*ArcEntry),
 	}
 
 	if err := registry.load(); err != nil {
-		if !os.IsNotExist(err) {
+		if !errors.Is(err, storage.ErrNotFound) {
 			return nil, fmt.Errorf("failed to load registry: %w", err)
 		}
 	}
@@ -47,9 +49,9 @@ func (r *Registry) Register(id, name string, createdAt time.Time) error {
 	return r.save()
 }
 
-// load reads the registry from disk
+// load reads the registry from storage
 func (r *Registry) load() error {
-	data, err := os.ReadFile(r.configPath)
+	data, err := storage.ReadAll(r.store, registryKey)
 	if err != nil {
 		return err
 	}
@@ -107,17 +109,12 @@ func (r *Registry) FindArc(idOrName string) (*ArcEntry, error) {
 	return nil, fmt.Errorf("arc not found: %s", idOrName)
 }
 
-// save writes the registry to disk
+// save writes the registry to storage
 func (r *Registry) save() error {
-	dir := filepath.Dir(r.configPath)
-	if err := os.MkdirAll(dir, 0700); err != nil {
-		return err
-	}
-
 	data, err := json.MarshalIndent(r.Arcs, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(r.configPath, data, 0600)
+	return r.store.Put(registryKey, bytes.NewReader(data))
 }
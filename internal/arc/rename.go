@@ -0,0 +1,220 @@
+package arc
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+// backref matches sed-style back-references (\1) in a replacement
+var backref = regexp.MustCompile(`\\([0-9])`)
+
+// Substitution is a parsed sed-style s/pattern/replacement/flags expression
+type Substitution struct {
+	re          *regexp.Regexp
+	replacement string
+	global      bool
+}
+
+// ParseSubstitution parses a sed-style substitution such as s/IMG_/vacation_/.
+// Any character may follow the s as delimiter. Flags are g (replace every
+// match) and i (ignore case); \1 in the replacement refers to a group.
+func ParseSubstitution(expr string) (*Substitution, error) {
+	if len(expr) < 4 || expr[0] != 's' {
+		return nil, fmt.Errorf("invalid pattern %q: expected s/pattern/replacement/", expr)
+	}
+	delim := expr[1:2]
+
+	parts := splitUnescaped(expr[2:], delim[0])
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid pattern %q: expected s%spattern%sreplacement%s", expr, delim, delim, delim)
+	}
+	pattern, replacement, flags := parts[0], parts[1], parts[2]
+
+	sub := &Substitution{}
+	for _, flag := range flags {
+		switch flag {
+		case 'g':
+			sub.global = true
+		case 'i':
+			pattern = "(?i)" + pattern
+		default:
+			return nil, fmt.Errorf("invalid pattern %q: unknown flag %q", expr, flag)
+		}
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", expr, err)
+	}
+	sub.re = re
+
+	replacement = strings.ReplaceAll(replacement, "$", "$$")
+	sub.replacement = backref.ReplaceAllString(replacement, "$${$1}")
+	return sub, nil
+}
+
+// Apply runs the substitution on s
+func (s *Substitution) Apply(in string) string {
+	if s.global {
+		return s.re.ReplaceAllString(in, s.replacement)
+	}
+
+	loc := s.re.FindStringSubmatchIndex(in)
+	if loc == nil {
+		return in
+	}
+	var out []byte
+	out = s.re.ExpandString(out, s.replacement, in, loc)
+	return in[:loc[0]] + string(out) + in[loc[1]:]
+}
+
+// splitUnescaped splits s on delim, treating \delim as a literal delimiter
+func splitUnescaped(s string, delim byte) []string {
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && s[i+1] == delim {
+			cur.WriteByte(delim)
+			i++
+			continue
+		}
+		if s[i] == delim {
+			parts = append(parts, cur.String())
+			cur.Reset()
+			continue
+		}
+		cur.WriteByte(s[i])
+	}
+	return append(parts, cur.String())
+}
+
+// ResolveTarget works out the new folder and filename for a document moved
+// to target. A target ending in "/", "/" itself or an existing folder keeps
+// the filename; anything else is a new name or path.
+func ResolveTarget(arc *models.Arc, doc *models.Document, target string) (string, string, error) {
+	target = strings.ReplaceAll(target, "\\", "/")
+
+	if strings.HasSuffix(target, "/") || folderExists(arc, target) {
+		folder, err := CleanFolder(target)
+		return folder, doc.Filename, err
+	}
+
+	dir, name := path.Split(target)
+	if name == "" || name == "." || name == ".." {
+		return "", "", fmt.Errorf("invalid document name: %q", target)
+	}
+	if dir == "" {
+		return doc.Folder, name, nil
+	}
+
+	folder, err := CleanFolder(dir)
+	return folder, name, err
+}
+
+// folderExists reports whether any document lives in or below folder
+func folderExists(arc *models.Arc, folder string) bool {
+	folder, err := CleanFolder(folder)
+	if err != nil {
+		return false
+	}
+	if folder == "" {
+		return true
+	}
+	for _, doc := range arc.Documents {
+		if inFolder(doc.Folder, folder) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rename is one planned document rename
+type Rename struct {
+	DocID    string
+	Folder   string
+	Filename string
+}
+
+// RenameDocument changes the folder and filename of a document. Only
+// metadata changes; the document keeps its ID, content and tags.
+func (m *Manager) RenameDocument(arcID string, arc *models.Arc, key []byte, docID, folder, filename string) error {
+	return m.RenameDocuments(arcID, arc, key, []Rename{{DocID: docID, Folder: folder, Filename: filename}})
+}
+
+// RenameDocuments applies a plan of renames as a whole. The plan is checked
+// against the paths documents have after every rename, so chains such as
+// a -> old_a, old_a -> old_old_a and swaps work, and nothing changes
+// unless every rename is valid.
+func (m *Manager) RenameDocuments(arcID string, arc *models.Arc, key []byte, plan []Rename) error {
+	plan, err := checkRenames(arc, plan)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	docIDs := make([]string, len(plan))
+	for i, r := range plan {
+		doc := arc.Documents[r.DocID]
+		doc.Folder = r.Folder
+		doc.Filename = r.Filename
+		doc.ModifiedAt = now
+		docIDs[i] = r.DocID
+	}
+	return m.commit(arcID, arc, key, docIDs...)
+}
+
+// CheckRenames reports whether RenameDocuments would accept a plan
+func CheckRenames(arc *models.Arc, plan []Rename) error {
+	_, err := checkRenames(arc, plan)
+	return err
+}
+
+// checkRenames validates a plan and returns it with cleaned folders. Two
+// documents may not end up at the same path, including documents the plan
+// does not touch.
+func checkRenames(arc *models.Arc, plan []Rename) ([]Rename, error) {
+	cleaned := make([]Rename, len(plan))
+	moving := make(map[string]bool, len(plan))
+	for i, r := range plan {
+		doc, exists := arc.Documents[r.DocID]
+		if !exists {
+			return nil, fmt.Errorf("document not found: %s", r.DocID)
+		}
+		if moving[r.DocID] {
+			return nil, fmt.Errorf("/%s is renamed twice", doc.Path())
+		}
+		moving[r.DocID] = true
+		if err := CheckChange(arc, doc); err != nil {
+			return nil, err
+		}
+
+		folder, err := CleanFolder(r.Folder)
+		if err != nil {
+			return nil, err
+		}
+		if r.Filename == "" || r.Filename == "." || r.Filename == ".." || strings.ContainsAny(r.Filename, "/\\") {
+			return nil, fmt.Errorf("invalid document name: %q", r.Filename)
+		}
+		cleaned[i] = Rename{DocID: r.DocID, Folder: folder, Filename: r.Filename}
+	}
+
+	taken := make(map[string]bool)
+	for _, doc := range arc.Documents {
+		if !moving[doc.ID] {
+			taken[doc.Path()] = true
+		}
+	}
+	for _, r := range cleaned {
+		newPath := path.Join(r.Folder, r.Filename)
+		if taken[newPath] {
+			return nil, fmt.Errorf("cannot rename /%s: /%s already exists", arc.Documents[r.DocID].Path(), newPath)
+		}
+		taken[newPath] = true
+	}
+	return cleaned, nil
+}
@@ -0,0 +1,121 @@
+package arc
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+func TestRenameDocuments(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   []string
+		renames map[string]string // old path -> new filename
+		want    []string
+		wantErr string
+	}{
+		{
+			name:    "chain",
+			files:   []string{"a", "old_a"},
+			renames: map[string]string{"a": "old_a", "old_a": "old_old_a"},
+			want:    []string{"old_a", "old_old_a"},
+		},
+		{
+			name:    "swap",
+			files:   []string{"a", "b"},
+			renames: map[string]string{"a": "b", "b": "a"},
+			want:    []string{"a", "b"},
+		},
+		{
+			name:    "cycle",
+			files:   []string{"a", "b", "c"},
+			renames: map[string]string{"a": "b", "b": "c", "c": "a"},
+			want:    []string{"a", "b", "c"},
+		},
+		{
+			name:    "collision with untouched document",
+			files:   []string{"a", "b", "c"},
+			renames: map[string]string{"a": "x", "b": "c"},
+			want:    []string{"a", "b", "c"},
+			wantErr: "/c already exists",
+		},
+		{
+			name:    "two renames to one path",
+			files:   []string{"a", "b"},
+			renames: map[string]string{"a": "x", "b": "x"},
+			want:    []string{"a", "b"},
+			wantErr: "/x already exists",
+		},
+		{
+			name:    "dot names",
+			files:   []string{"a", "b"},
+			renames: map[string]string{"a": ".", "b": ".."},
+			want:    []string{"a", "b"},
+			wantErr: "invalid document name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, store := newTestManager(t)
+			arc, err := m.Create("renames", "password1", "q", "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			arc, key, err := m.Unlock("renames", "password1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			byPath := make(map[string]*models.Document)
+			for _, name := range tt.files {
+				doc, err := m.AddDocumentFromReader(arc.ID, arc, key, name, strings.NewReader(name), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				byPath[name] = doc
+			}
+
+			var plan []Rename
+			for from, to := range tt.renames {
+				plan = append(plan, Rename{DocID: byPath[from].ID, Filename: to})
+			}
+			err = m.RenameDocuments(arc.ID, arc, key, plan)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("RenameDocuments: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("RenameDocuments error = %v, want %q", err, tt.wantErr)
+			}
+
+			// Check what was persisted, not just the arc in memory
+			m = reopenManager(t, store)
+			arc, key, err = m.Unlock("renames", "password1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var paths []string
+			for _, doc := range arc.Documents {
+				paths = append(paths, doc.Path())
+			}
+			sort.Strings(paths)
+			if strings.Join(paths, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("paths = %v, want %v", paths, tt.want)
+			}
+
+			if tt.wantErr != "" {
+				return
+			}
+			for from, to := range tt.renames {
+				data, err := m.GetDocument(arc.ID, arc, key, byPath[from].ID)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := arc.Documents[byPath[from].ID].Filename; got != to || string(data) != from {
+					t.Errorf("document %s is %s holding %q, want %s holding %q", byPath[from].ID, got, data, to, from)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package arc
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Merges and splits copy documents into a destination arc and record their
+// progress there: the arc carries a Journal naming the operation, and every
+// copied document an Origin naming the document it came from. An interrupted
+// run is resumed by running the same command again, which skips documents
+// whose origin is already present.
+
+// ReorgStats summarizes a merge or split
+type ReorgStats struct {
+	Copied  int // documents copied into the destination
+	Skipped int // documents already copied by an interrupted run
+	Renamed int // documents renamed to avoid a path collision
+	Removed int // documents removed from the source
+}
+
+// reorgSource is an arc to copy from and the documents to take
+type reorgSource struct {
+	arc    *ArcHandle
+	docIDs []string
+}
+
+// Merge copies every document of the source arcs into dst. The sources are
+// left untouched.
+func (m *Manager) Merge(dst *ArcHandle, sources []*ArcHandle) (*ReorgStats, error) {
+	plan := make([]reorgSource, 0, len(sources))
+	for _, src := range sources {
+		var docIDs []string
+		for _, doc := range m.ListDocuments(src.Arc) {
+			docIDs = append(docIDs, doc.ID)
+		}
+		plan = append(plan, reorgSource{arc: src, docIDs: docIDs})
+	}
+	return m.reorganize("merge", dst, plan, false)
+}
+
+// Split moves the given documents of src into dst
+func (m *Manager) Split(src, dst *ArcHandle, docIDs []string) (*ReorgStats, error) {
+	if err := checkRemovable(src.Arc, docIDs); err != nil {
+		return nil, err
+	}
+	return m.reorganize("split", dst, []reorgSource{{arc: src, docIDs: docIDs}}, true)
+}
+
+// reorganize copies the planned documents into dst, then removes them from
+// their sources when cleanup is set
+func (m *Manager) reorganize(op string, dst *ArcHandle, plan []reorgSource, cleanup bool) (*ReorgStats, error) {
+	sourceIDs := make([]string, 0, len(plan))
+	for _, src := range plan {
+		if src.arc.ID == dst.ID {
+			return nil, fmt.Errorf("arc %s cannot be both source and destination", dst.Arc.Name)
+		}
+		sourceIDs = append(sourceIDs, src.arc.ID)
+	}
+	sort.Strings(sourceIDs)
+
+	if journal := dst.Arc.Journal; journal != nil {
+		if journal.Op != op || strings.Join(journal.Sources, ",") != strings.Join(sourceIDs, ",") {
+			return nil, fmt.Errorf("arc %s has an unfinished %s from other arcs, finish that first", dst.Arc.Name, journal.Op)
+		}
+		m.logf("Resuming %s started %s\n", op, journal.StartedAt.Format("2006-01-02 15:04:05"))
+	} else {
+		dst.Arc.Journal = &models.Journal{Op: op, Sources: sourceIDs, Phase: "copy", StartedAt: time.Now()}
+		if err := m.Update(dst.ID, dst.Arc, dst.Key); err != nil {
+			return nil, fmt.Errorf("failed to start %s: %w", op, err)
+		}
+	}
+
+	stats := &ReorgStats{}
+	copied := make(map[string]bool)
+	taken := make(map[string]bool, len(dst.Arc.Documents))
+	for _, doc := range dst.Arc.Documents {
+		if doc.Origin != "" {
+			copied[doc.Origin] = true
+		}
+		taken[doc.Path()] = true
+	}
+
+	err := m.Batch(dst.ID, dst.Arc, dst.Key, func() error {
+		for _, src := range plan {
+			for _, docID := range src.docIDs {
+				origin := src.arc.ID + "/" + docID
+				if copied[origin] {
+					stats.Skipped++
+					continue
+				}
+				if err := m.copyInto(src.arc, dst, docID, origin, taken, stats); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("%s interrupted, run it again to resume: %w", op, err)
+	}
+
+	if cleanup {
+		dst.Arc.Journal.Phase = "cleanup"
+		if err := m.Update(dst.ID, dst.Arc, dst.Key); err != nil {
+			return stats, err
+		}
+
+		for _, src := range plan {
+			err := m.Batch(src.arc.ID, src.arc.Arc, src.arc.Key, func() error {
+				for _, docID := range src.docIDs {
+					if _, exists := src.arc.Arc.Documents[docID]; !exists {
+						continue
+					}
+					if err := m.RemoveDocument(src.arc.ID, src.arc.Arc, src.arc.Key, docID); err != nil {
+						return err
+					}
+					stats.Removed++
+				}
+				return nil
+			})
+			if err != nil {
+				return stats, fmt.Errorf("%s interrupted, run it again to resume: %w", op, err)
+			}
+		}
+	}
+
+	dst.Arc.Journal = nil
+	if err := m.Update(dst.ID, dst.Arc, dst.Key); err != nil {
+		return stats, fmt.Errorf("failed to finish %s: %w", op, err)
+	}
+	return stats, nil
+}
+
+// copyInto copies one document into dst, keeping its timestamps, tags and
+// properties and renaming it if its path is already taken
+func (m *Manager) copyInto(src, dst *ArcHandle, docID, origin string, taken map[string]bool, stats *ReorgStats) error {
+	doc := src.Arc.Documents[docID]
+	if err := checkQuota(dst.Arc, doc.Size); err != nil {
+		return err
+	}
+
+	copied := copyDocument(doc)
+	copied.Origin = origin
+
+	if _, clash := dst.Arc.Documents[copied.ID]; clash {
+		copied.ID = uuid.New().String()
+	}
+	if taken[copied.Path()] {
+		copied.Filename = uniqueFilename(taken, copied.Folder, copied.Filename)
+		stats.Renamed++
+		m.logf("Renaming /%s to /%s\n", doc.Path(), copied.Path())
+	}
+
+	m.logf("Copying: %s\n", copied.Path())
+	if err := m.copyBlob(src, dst, docID, copied.ID); err != nil {
+		return err
+	}
+
+	dst.Arc.Documents[copied.ID] = copied
+	if tags := src.Arc.Tags[docID]; len(tags) > 0 {
+		dst.Arc.Tags[copied.ID] = append([]string(nil), tags...)
+	}
+	taken[copied.Path()] = true
+	stats.Copied++
+
+	return m.commit(dst.ID, dst.Arc, dst.Key, copied.ID)
+}
+
+// uniqueFilename appends " (n)" before the extension until the path is free
+func uniqueFilename(taken map[string]bool, folder, filename string) string {
+	ext := path.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if !taken[path.Join(folder, candidate)] {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package arc
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ViniTamanhao/arcadio/internal/storage"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+// period matches retention periods such as 36h, 30d, 6w, 18mo or 7y
+var period = regexp.MustCompile(`^(\d+)(h|d|w|mo|y)$`)
+
+// PurgeRecord is kept for every document removed because it expired
+type PurgeRecord struct {
+	DocID       string    `json:"doc_id"`
+	Path        string    `json:"path"`
+	ContentHash string    `json:"content_hash"`
+	Size        int64     `json:"size"`
+	Tags        []string  `json:"tags,omitempty"`
+	ExpiredAt   time.Time `json:"expired_at"`
+	Reason      string    `json:"reason"`
+	PurgedAt    time.Time `json:"purged_at"`
+}
+
+// purgeLogKey is the storage key of an arc's encrypted purge log
+func purgeLogKey(arcID string) string {
+	return storage.Join(arcID, "purge.log")
+}
+
+// AddPeriod adds a retention period such as 30d to t
+func AddPeriod(t time.Time, p string) (time.Time, error) {
+	match := period.FindStringSubmatch(strings.ToLower(strings.TrimSpace(p)))
+	if match == nil {
+		return time.Time{}, fmt.Errorf("invalid period %q (use e.g. 36h, 30d, 6w, 18mo or 7y)", p)
+	}
+	n, err := strconv.Atoi(match[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid period %q", p)
+	}
+
+	switch match[2] {
+	case "h":
+		return t.Add(time.Duration(n) * time.Hour), nil
+	case "d":
+		return t.AddDate(0, 0, n), nil
+	case "w":
+		return t.AddDate(0, 0, 7*n), nil
+	case "mo":
+		return t.AddDate(0, n, 0), nil
+	default:
+		return t.AddDate(n, 0, 0), nil
+	}
+}
+
+// ParseExpiry reads an expiry given as a date (2026-12-31) or a period from
+// now (30d). "never" clears the expiry and returns nil.
+func ParseExpiry(s string, now time.Time) (*time.Time, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "never", "none", "":
+		return nil, nil
+	}
+
+	if t, err := parseDate(s); err == nil {
+		return &t, nil
+	}
+	t, err := AddPeriod(now, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expiry %q (use a date like 2026-12-31, a period like 30d, or never)", s)
+	}
+	return &t, nil
+}
+
+// ExpiryOf returns when a document expires and why: its own expiry or the
+// earliest retention rule matching one of its tags
+func ExpiryOf(arc *models.Arc, doc *models.Document) (time.Time, string, bool) {
+	var expires time.Time
+	var reason string
+	if doc.ExpiresAt != nil {
+		expires, reason = *doc.ExpiresAt, "expires_at"
+	}
+
+	for _, rule := range arc.RetentionRules {
+		if !hasTag(arc.Tags[doc.ID], rule.Tag) {
+			continue
+		}
+		t, err := AddPeriod(doc.AddedAt, rule.After)
+		if err != nil {
+			continue
+		}
+		if reason == "" || t.Before(expires) {
+			expires, reason = t, fmt.Sprintf("tag %s after %s", rule.Tag, rule.After)
+		}
+	}
+
+	return expires, reason, reason != ""
+}
+
+// ExpiredDocuments returns the documents whose expiry has passed, ordered
+// by path. Documents the policy keeps (legal hold, immutable arc) are left
+// out until they can be removed.
+func (m *Manager) ExpiredDocuments(arc *models.Arc, now time.Time) []*models.Document {
+	var expired []*models.Document
+	for _, doc := range m.ListDocuments(arc) {
+		if CheckChange(arc, doc) != nil {
+			continue
+		}
+		if t, _, ok := ExpiryOf(arc, doc); ok && !t.After(now) {
+			expired = append(expired, doc)
+		}
+	}
+	return expired
+}
+
+// SetExpiry sets or, with nil, clears the expiry of a document
+func (m *Manager) SetExpiry(arcID string, arc *models.Arc, key []byte, docID string, expires *time.Time) error {
+	doc, exists := arc.Documents[docID]
+	if !exists {
+		return fmt.Errorf("document not found: %s", docID)
+	}
+	if err := CheckChange(arc, doc); err != nil {
+		return err
+	}
+
+	doc.ExpiresAt = expires
+	return m.commit(arcID, arc, key, docID)
+}
+
+// checkRules returns an error if replacing the arc's retention rules with
+// rules would move the expiry of a document the policy keeps
+func checkRules(arc *models.Arc, rules []*models.RetentionRule) error {
+	next := *arc
+	next.RetentionRules = rules
+	for _, doc := range arc.Documents {
+		before, _, expired := ExpiryOf(arc, doc)
+		after, _, expires := ExpiryOf(&next, doc)
+		if expired == expires && before.Equal(after) {
+			continue
+		}
+		if err := CheckChange(arc, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetRetentionRule expires documents tagged tag the given period after they
+// were added, replacing any rule for the same tag
+func (m *Manager) SetRetentionRule(arcID string, arc *models.Arc, key []byte, tag, after string) error {
+	tag = NormalizeTag(tag)
+	if tag == "" {
+		return fmt.Errorf("retention rule needs a tag")
+	}
+	if _, err := AddPeriod(time.Now(), after); err != nil {
+		return err
+	}
+
+	rules := []*models.RetentionRule{{Tag: tag, After: after}}
+	for _, rule := range arc.RetentionRules {
+		if rule.Tag != tag {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Tag < rules[j].Tag })
+	if err := checkRules(arc, rules); err != nil {
+		return err
+	}
+
+	arc.RetentionRules = rules
+	return m.commit(arcID, arc, key)
+}
+
+// RemoveRetentionRule drops the retention rule for a tag
+func (m *Manager) RemoveRetentionRule(arcID string, arc *models.Arc, key []byte, tag string) error {
+	tag = NormalizeTag(tag)
+	var rules []*models.RetentionRule
+	for _, rule := range arc.RetentionRules {
+		if rule.Tag != tag {
+			rules = append(rules, rule)
+		}
+	}
+	if len(rules) == len(arc.RetentionRules) {
+		return fmt.Errorf("no retention rule for tag: %s", tag)
+	}
+	if err := checkRules(arc, rules); err != nil {
+		return err
+	}
+
+	arc.RetentionRules = rules
+	return m.commit(arcID, arc, key)
+}
+
+// PurgeExpired removes every expired document through RemoveDocument. Each
+// purge is recorded in the arc's encrypted purge log before the document is
+// removed, so an interrupted purge never loses its record.
+func (m *Manager) PurgeExpired(arcID string, arc *models.Arc, key []byte, now time.Time) ([]PurgeRecord, error) {
+	expired := m.ExpiredDocuments(arc, now)
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	records := make([]PurgeRecord, 0, len(expired))
+	for _, doc := range expired {
+		expiresAt, reason, _ := ExpiryOf(arc, doc)
+		records = append(records, PurgeRecord{
+			DocID:       doc.ID,
+			Path:        doc.Path(),
+			ContentHash: doc.ContentHash,
+			Size:        doc.Size,
+			Tags:        arc.Tags[doc.ID],
+			ExpiredAt:   expiresAt,
+			Reason:      reason,
+			PurgedAt:    now,
+		})
+	}
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.appendFrame(purgeLogKey(arcID), key, data); err != nil {
+		return nil, fmt.Errorf("failed to record purge: %w", err)
+	}
+
+	err = m.Batch(arcID, arc, key, func() error {
+		for _, doc := range expired {
+			if err := m.RemoveDocument(arcID, arc, key, doc.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// PurgeLog returns the recorded purges of an arc, oldest first
+func (m *Manager) PurgeLog(arcID string, key []byte) ([]PurgeRecord, error) {
+	var records []PurgeRecord
+	_, err := m.readFrames(purgeLogKey(arcID), key, func(plain []byte) error {
+		var batch []PurgeRecord
+		if err := json.Unmarshal(plain, &batch); err != nil {
+			return fmt.Errorf("invalid purge log record: %w", err)
+		}
+		records = append(records, batch...)
+		return nil
+	})
+	return records, err
+}
@@ -0,0 +1,59 @@
+package arc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeldDocumentExpiry(t *testing.T) {
+	m, _ := newTestManager(t)
+	if _, err := m.Create("held", "password1", "q", "a"); err != nil {
+		t.Fatal(err)
+	}
+	arc, key, err := m.Unlock("held", "password1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetRetentionRule(arc.ID, arc, key, "hr", "7y"); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := m.AddDocumentFromReader(arc.ID, arc, key, "contract.txt", strings.NewReader("terms"), []string{"hr"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AddDocumentFromReader(arc.ID, arc, key, "other.txt", strings.NewReader("other"), []string{"misc"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetLegalHold(arc.ID, arc, key, []string{doc.ID}, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	tomorrow := time.Now().AddDate(0, 0, 1)
+
+	tests := []struct {
+		name   string
+		change func() error
+	}{
+		{"set expiry", func() error { return m.SetExpiry(arc.ID, arc, key, doc.ID, &tomorrow) }},
+		{"add tag", func() error { return m.AddTags(arc.ID, arc, key, doc.ID, []string{"tmp"}) }},
+		{"shorten rule", func() error { return m.SetRetentionRule(arc.ID, arc, key, "hr", "1d") }},
+		{"remove rule", func() error { return m.RemoveRetentionRule(arc.ID, arc, key, "hr") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(); !errors.Is(err, ErrLegalHold) {
+				t.Fatalf("error = %v, want ErrLegalHold", err)
+			}
+			expires, _, _ := ExpiryOf(arc, doc)
+			if want := doc.AddedAt.AddDate(7, 0, 0); !expires.Equal(want) {
+				t.Errorf("held document expires %s, want %s", expires, want)
+			}
+		})
+	}
+
+	// Rules that miss the held document still apply
+	if err := m.SetRetentionRule(arc.ID, arc, key, "misc", "1d"); err != nil {
+		t.Fatalf("rule for another tag: %v", err)
+	}
+}
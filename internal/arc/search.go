@@ -0,0 +1,384 @@
+package arc
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Scores for how a query term matched. A document's score is the sum of the
+// best match of every term, so documents matching more precisely rank first.
+const (
+	scoreExactWord  = 1.0  // term equals a whole word
+	scorePrefix     = 0.9  // term starts a word
+	scoreSubstring  = 0.75 // term appears inside a word
+	scoreTypo       = 0.6  // within the allowed edit distance, minus a step per edit
+	scoreTypoStep   = 0.1
+	scoreTrigram    = 0.5 // scaled by trigram similarity
+	trigramMinimum  = 0.4
+	tagWeight       = 1.1 // tags are curated, so a tag hit beats a filename hit
+	folderWeight    = 0.8 // folder names are less specific than filenames
+	minFuzzyTermLen = 4   // shorter terms must match exactly
+)
+
+// SearchResult is one ranked search hit
+type SearchResult struct {
+	Doc   *models.Document
+	Score float64
+	Spans [][2]int // byte ranges of doc.Path() that matched
+	Tags  []string // tags that matched
+}
+
+// folder removes diacritics and folds case, so "Résumé" matches "resume".
+// Transformers keep state between calls, so foldMu guards both.
+var (
+	foldMu     sync.Mutex
+	stripMarks = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	folder     = cases.Fold()
+	foldCache  sync.Map // rune -> []rune
+)
+
+// foldRune returns the search form of a single rune, which may be empty
+// (a combining mark) or longer than one rune (ß folds to ss)
+func foldRune(r rune) []rune {
+	if cached, ok := foldCache.Load(r); ok {
+		return cached.([]rune)
+	}
+	foldMu.Lock()
+	s, _, err := transform.String(stripMarks, string(r))
+	if err != nil {
+		s = string(r)
+	}
+	folded := []rune(folder.String(s))
+	foldMu.Unlock()
+	foldCache.Store(r, folded)
+	return folded
+}
+
+// foldedText is a folded string that remembers where each rune came from
+type foldedText struct {
+	runes  []rune
+	starts []int // byte offset in the original of each folded rune
+	ends   []int // byte offset just past the original rune
+}
+
+func fold(s string) foldedText {
+	ft := foldedText{
+		runes:  make([]rune, 0, len(s)),
+		starts: make([]int, 0, len(s)),
+		ends:   make([]int, 0, len(s)),
+	}
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			ft.runes = append(ft.runes, unicode.ToLower(rune(c)))
+			ft.starts = append(ft.starts, i)
+			ft.ends = append(ft.ends, i+1)
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		for _, f := range foldRune(r) {
+			ft.runes = append(ft.runes, f)
+			ft.starts = append(ft.starts, i)
+			ft.ends = append(ft.ends, i+size)
+		}
+		i += size
+	}
+	return ft
+}
+
+// span maps a folded rune range back to original byte offsets
+func (ft foldedText) span(from, to int) [2]int {
+	return [2]int{ft.starts[from], ft.ends[to-1]}
+}
+
+// word is a run of letters and digits in a folded text
+type word struct {
+	start, end int // rune range in the folded text
+}
+
+func words(ft foldedText) []word {
+	var out []word
+	start := -1
+	for i, r := range ft.runes {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		}
+		if !isWord && start >= 0 {
+			out = append(out, word{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		out = append(out, word{start, len(ft.runes)})
+	}
+	return out
+}
+
+// Search returns the documents matching q, ranked by how well their path
+// and tags match its plain search terms. Matching ignores case and
+// diacritics and tolerates typos. limit caps the number of results; 0
+// returns all.
+func (m *Manager) Search(arc *models.Arc, q *Query, limit int) []SearchResult {
+	var results []SearchResult
+	for _, doc := range arc.Documents {
+		text := newDocText(arc, doc)
+		if !q.root.match(arc, doc, text) {
+			continue
+		}
+		results = append(results, rankDocument(doc, text, q.rank))
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Doc.Path() < results[j].Doc.Path()
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// SearchDocuments returns the documents matching q, best match first
+func (m *Manager) SearchDocuments(arc *models.Arc, q *Query) []*models.Document {
+	results := m.Search(arc, q, 0)
+	docs := make([]*models.Document, len(results))
+	for i, result := range results {
+		docs[i] = result.Doc
+	}
+	return docs
+}
+
+// docText is the folded path and tags of a document, prepared once so
+// every term of a query can be matched against it
+type docText struct {
+	path          foldedText
+	words         []word
+	filenameStart int // first rune of the filename in path
+	tags          []string
+	foldedTags    []foldedText
+}
+
+func newDocText(arc *models.Arc, doc *models.Document) *docText {
+	path := fold(doc.Path())
+	t := &docText{
+		path:          path,
+		words:         words(path),
+		filenameStart: len(path.runes) - len(fold(doc.Filename).runes),
+		tags:          arc.Tags[doc.ID],
+	}
+	t.foldedTags = make([]foldedText, len(t.tags))
+	for i, tag := range t.tags {
+		t.foldedTags[i] = fold(tag)
+	}
+	return t
+}
+
+// matchTerm returns the best score of a term across the path words and
+// tags, with the matched byte range of the path or the index of the
+// matched tag (-1 for a path match)
+func (t *docText) matchTerm(term []rune) (float64, [2]int, int) {
+	best, bestSpan, bestTag := 0.0, [2]int{-1, -1}, -1
+
+	for _, w := range t.words {
+		score, from, to := matchWord(term, t.path.runes[w.start:w.end])
+		if w.start < t.filenameStart {
+			score *= folderWeight
+		}
+		if score > best {
+			best, bestSpan, bestTag = score, t.path.span(w.start+from, w.start+to), -1
+		}
+	}
+
+	for i, tag := range t.foldedTags {
+		score, _, _ := matchWord(term, tag.runes)
+		score *= tagWeight
+		if score > best {
+			best, bestTag = score, i
+		}
+	}
+	return best, bestSpan, bestTag
+}
+
+// matchPhrase looks for a quoted phrase as it is written, ignoring case
+// and diacritics
+func (t *docText) matchPhrase(phrase []rune) (float64, [2]int, int) {
+	if i := indexRunes(t.path.runes, phrase); i >= 0 {
+		return scoreSubstring * float64(len(strings.Fields(string(phrase)))), t.path.span(i, i+len(phrase)), -1
+	}
+	for i, tag := range t.foldedTags {
+		if indexRunes(tag.runes, phrase) >= 0 {
+			return scoreSubstring * tagWeight, [2]int{-1, -1}, i
+		}
+	}
+	return 0, [2]int{-1, -1}, -1
+}
+
+// indexRunes returns the index of the first instance of needle in s, or -1
+func indexRunes(s, needle []rune) int {
+	if len(needle) == 0 {
+		return -1
+	}
+	for i := 0; i+len(needle) <= len(s); i++ {
+		if string(s[i:i+len(needle)]) == string(needle) {
+			return i
+		}
+	}
+	return -1
+}
+
+// rankDocument scores a matching document by its search terms and records
+// what matched for highlighting
+func rankDocument(doc *models.Document, text *docText, terms []rankTerm) SearchResult {
+	result := SearchResult{Doc: doc}
+	matchedTags := make(map[string]bool)
+
+	for _, term := range terms {
+		var score float64
+		var span [2]int
+		var tag int
+		if term.phrase {
+			score, span, tag = text.matchPhrase(term.text)
+		} else {
+			score, span, tag = text.matchTerm(term.text)
+		}
+
+		if score == 0 {
+			continue
+		}
+		result.Score += score
+		if tag >= 0 {
+			matchedTags[text.tags[tag]] = true
+		} else {
+			result.Spans = append(result.Spans, span)
+		}
+	}
+
+	for _, tag := range text.tags {
+		if matchedTags[tag] {
+			result.Tags = append(result.Tags, tag)
+		}
+	}
+	result.Spans = mergeSpans(result.Spans)
+	return result
+}
+
+// matchWord scores term against one word and returns the matched rune range
+// within the word
+func matchWord(term, w []rune) (float64, int, int) {
+	ts, ws := string(term), string(w)
+
+	if ts == ws {
+		return scoreExactWord, 0, len(w)
+	}
+	if strings.HasPrefix(ws, ts) {
+		return scorePrefix, 0, len(term)
+	}
+	if i := strings.Index(ws, ts); i >= 0 {
+		from := len([]rune(ws[:i]))
+		return scoreSubstring, from, from + len(term)
+	}
+	if len(term) < minFuzzyTermLen {
+		return 0, 0, 0
+	}
+
+	// Compare against the whole word and against a prefix of similar length,
+	// so "invoce" matches both "invoice" and "invoices2024"
+	allowed := 1
+	if len(term) >= 8 {
+		allowed = 2
+	}
+	best := allowed + 1
+	bestLen := 0
+	for _, n := range []int{len(w), len(term) - 1, len(term), len(term) + 1} {
+		if n <= 0 || n > len(w) {
+			continue
+		}
+		if d := levenshtein(term, w[:n]); d < best {
+			best, bestLen = d, n
+		}
+	}
+	if best <= allowed {
+		return scoreTypo - scoreTypoStep*float64(best-1), 0, bestLen
+	}
+
+	if sim := trigramSimilarity(term, w); sim >= trigramMinimum {
+		return scoreTrigram * sim, 0, len(w)
+	}
+	return 0, 0, 0
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// trigramSimilarity is the Jaccard similarity of the padded trigrams of a and b
+func trigramSimilarity(a, b []rune) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func trigrams(r []rune) map[string]bool {
+	padded := append(append([]rune{' ', ' '}, r...), ' ')
+	set := make(map[string]bool)
+	for i := 0; i+3 <= len(padded); i++ {
+		set[string(padded[i:i+3])] = true
+	}
+	return set
+}
+
+// mergeSpans sorts spans and joins overlapping ones
+func mergeSpans(spans [][2]int) [][2]int {
+	if len(spans) < 2 {
+		return spans
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	merged := spans[:1]
+	for _, s := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if s[0] <= last[1] {
+			last[1] = max(last[1], s[1])
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
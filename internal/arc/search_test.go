@@ -0,0 +1,26 @@
+package arc
+
+import "testing"
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Report.PDF", "report.pdf"},
+		{"Résumé", "resume"},
+		{"Straße", "strasse"},
+		{"NAÏVE coöp", "naive coop"},
+	}
+	for _, tt := range tests {
+		ft := fold(tt.in)
+		if got := string(ft.runes); got != tt.want {
+			t.Errorf("fold(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		// Every folded rune maps back into the original
+		for i := range ft.runes {
+			if ft.starts[i] < 0 || ft.ends[i] > len(tt.in) || ft.starts[i] >= ft.ends[i] {
+				t.Errorf("fold(%q): rune %d maps to %d-%d", tt.in, i, ft.starts[i], ft.ends[i])
+			}
+		}
+	}
+}
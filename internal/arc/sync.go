@@ -0,0 +1,333 @@
+package arc
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+// How SyncDirectory treats archived documents whose local file is gone
+const (
+	SyncDeletedIgnore = "ignore" // keep them as they are
+	SyncDeletedMark   = "mark"   // tag them with SyncDeletedTag
+	SyncDeletedRemove = "remove" // remove them from the arc
+)
+
+// SyncDeletedTag marks documents whose local file was deleted. It is removed
+// again if the file comes back.
+const SyncDeletedTag = "sync/deleted"
+
+// Sync actions
+const (
+	SyncAdd    = "add"
+	SyncUpdate = "update"
+	SyncMark   = "mark"
+	SyncRemove = "remove"
+)
+
+// SyncOptions controls SyncDirectory
+type SyncOptions struct {
+	Folder  string   // folder inside the arc mirroring the directory
+	Include []string // only sync files matching one of these globs
+	Exclude []string // skip files and directories matching these globs
+	Deleted string   // SyncDeletedIgnore, SyncDeletedMark or SyncDeletedRemove
+	DryRun  bool     // report what would change without changing anything
+}
+
+// SyncChange is one change made (or, in a dry run, planned) by a sync
+type SyncChange struct {
+	Action string
+	Path   string // path inside the arc
+	Size   int64
+	Err    error // set when the change failed
+}
+
+// SyncStats summarizes a sync
+type SyncStats struct {
+	Changes   []SyncChange
+	Unchanged int
+	Failed    int
+}
+
+// Count returns the number of successful changes with an action
+func (s *SyncStats) Count(action string) int {
+	n := 0
+	for _, c := range s.Changes {
+		if c.Action == action && c.Err == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// SyncDirectory mirrors a local directory into a folder of the arc: files
+// that are not archived yet are added, changed files are updated in place
+// (keeping their ID, tags and properties) and documents whose file is gone
+// are handled as opts.Deleted says. A file whose size and modification time
+// match the last sync is not read again.
+func (m *Manager) SyncDirectory(arcID string, arc *models.Arc, key []byte, dir string, opts SyncOptions) (*SyncStats, error) {
+	folder, err := CleanFolder(opts.Folder)
+	if err != nil {
+		return nil, err
+	}
+	switch opts.Deleted {
+	case "":
+		opts.Deleted = SyncDeletedIgnore
+	case SyncDeletedIgnore, SyncDeletedMark, SyncDeletedRemove:
+	default:
+		return nil, fmt.Errorf("invalid deleted mode %q (use ignore, mark or remove)", opts.Deleted)
+	}
+
+	include, err := compileGlobs(opts.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileGlobs(opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	// Documents below the folder, by their path relative to it
+	archived := make(map[string]*models.Document)
+	for _, doc := range m.ListDocuments(arc) {
+		if !inFolder(doc.Folder, folder) {
+			continue
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(doc.Path(), folder), "/")
+		archived[rel] = doc
+	}
+
+	stats := &SyncStats{}
+	seen := make(map[string]bool)
+
+	run := func() error {
+		err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, file)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+
+			if info.IsDir() {
+				if rel != "." && matchesAny(exclude, rel) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !info.Mode().IsRegular() || !syncSelected(include, exclude, rel) {
+				return nil
+			}
+
+			seen[rel] = true
+			change, err := m.syncFile(arcID, arc, key, file, folder, rel, info, archived[rel], opts.DryRun)
+			if err != nil {
+				return err
+			}
+			if change == nil {
+				stats.Unchanged++
+				return nil
+			}
+			if change.Err != nil {
+				stats.Failed++
+			}
+			stats.Changes = append(stats.Changes, *change)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", dir, err)
+		}
+
+		if opts.Deleted == SyncDeletedIgnore {
+			return nil
+		}
+
+		var gone []string
+		for rel := range archived {
+			if !seen[rel] && syncSelected(include, exclude, rel) {
+				gone = append(gone, rel)
+			}
+		}
+		sort.Strings(gone)
+
+		for _, rel := range gone {
+			doc := archived[rel]
+			change := SyncChange{Action: SyncRemove, Path: doc.Path(), Size: doc.Size}
+			if opts.Deleted == SyncDeletedMark {
+				if hasTag(arc.Tags[doc.ID], SyncDeletedTag) {
+					continue
+				}
+				change.Action = SyncMark
+				if !opts.DryRun {
+					change.Err = m.AddTags(arcID, arc, key, doc.ID, []string{SyncDeletedTag})
+				}
+			} else if !opts.DryRun {
+				change.Err = m.RemoveDocument(arcID, arc, key, doc.ID)
+			}
+			if change.Err != nil {
+				stats.Failed++
+			}
+			stats.Changes = append(stats.Changes, change)
+		}
+		return nil
+	}
+
+	if opts.DryRun {
+		err = run()
+	} else {
+		err = m.Batch(arcID, arc, key, run)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// syncFile brings one file up to date, returning nil when it was unchanged
+func (m *Manager) syncFile(arcID string, arc *models.Arc, key []byte, file, folder, rel string, info os.FileInfo, doc *models.Document, dryRun bool) (*SyncChange, error) {
+	modTime := info.ModTime().UTC()
+
+	if doc == nil {
+		change := &SyncChange{Action: SyncAdd, Path: path.Join(folder, rel), Size: info.Size()}
+		if dryRun {
+			return change, nil
+		}
+		added, err := m.AddDocument(arcID, arc, key, file, path.Join(folder, path.Dir(rel)), nil)
+		if err != nil {
+			change.Err = err
+			return change, nil
+		}
+		added.SourceModTime = &modTime
+		change.Err = m.commit(arcID, arc, key, added.ID)
+		return change, nil
+	}
+
+	// A file that came back is no longer deleted
+	if hasTag(arc.Tags[doc.ID], SyncDeletedTag) && !dryRun {
+		if err := m.RemoveTags(arcID, arc, key, doc.ID, []string{SyncDeletedTag}); err != nil {
+			return &SyncChange{Action: SyncUpdate, Path: doc.Path(), Size: info.Size(), Err: err}, nil
+		}
+	}
+
+	if doc.SourceModTime != nil && doc.SourceModTime.Equal(modTime) && doc.Size == info.Size() {
+		return nil, nil
+	}
+
+	hash, err := hashFile(file)
+	if err != nil {
+		return &SyncChange{Action: SyncUpdate, Path: doc.Path(), Size: info.Size(), Err: err}, nil
+	}
+	if hash == doc.ContentHash {
+		// Same content with a new timestamp: remember it to skip hashing next time
+		if !dryRun {
+			doc.SourceModTime = &modTime
+			if err := m.commit(arcID, arc, key, doc.ID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+
+	change := &SyncChange{Action: SyncUpdate, Path: doc.Path(), Size: info.Size()}
+	if dryRun {
+		return change, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		change.Err = fmt.Errorf("failed to read file: %w", err)
+		return change, nil
+	}
+	if change.Err = m.UpdateDocumentContent(arcID, arc, key, doc.ID, data); change.Err == nil {
+		doc.SourceModTime = &modTime
+		change.Err = m.commit(arcID, arc, key, doc.ID)
+	}
+	return change, nil
+}
+
+// syncSelected reports whether a relative path passes the include and
+// exclude globs
+func syncSelected(include, exclude []*regexp.Regexp, rel string) bool {
+	if len(include) > 0 && !matchesAny(include, rel) {
+		return false
+	}
+	return !matchesAny(exclude, rel)
+}
+
+// matchesAny reports whether a relative path or any of its elements matches
+// one of the globs
+func matchesAny(globs []*regexp.Regexp, rel string) bool {
+	elements := strings.Split(rel, "/")
+	for _, glob := range globs {
+		if glob.MatchString(rel) {
+			return true
+		}
+		for _, element := range elements {
+			if glob.MatchString(element) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compileGlobs turns shell globs into regular expressions. * and ? stay
+// within one path element and ** crosses elements, so *.tmp matches a file
+// name anywhere, build/** everything below build and docs/**/*.md Markdown
+// at any depth below docs.
+func compileGlobs(patterns []string) ([]*regexp.Regexp, error) {
+	var globs []*regexp.Regexp
+	for _, pattern := range patterns {
+		pattern = strings.Trim(filepath.ToSlash(pattern), "/")
+		if pattern == "" {
+			continue
+		}
+
+		var b strings.Builder
+		b.WriteString("^")
+		for i := 0; i < len(pattern); i++ {
+			switch c := pattern[i]; c {
+			case '*':
+				if strings.HasPrefix(pattern[i:], "**/") {
+					b.WriteString("(?:.*/)?")
+					i += 2
+				} else if strings.HasPrefix(pattern[i:], "**") {
+					b.WriteString(".*")
+					i++
+				} else {
+					b.WriteString("[^/]*")
+				}
+			case '?':
+				b.WriteString("[^/]")
+			case '[':
+				end := strings.IndexByte(pattern[i:], ']')
+				if end < 0 {
+					return nil, fmt.Errorf("invalid pattern %q", pattern)
+				}
+				class := pattern[i+1 : i+end]
+				if strings.HasPrefix(class, "!") {
+					class = "^" + class[1:]
+				}
+				b.WriteString("[" + class + "]")
+				i += end
+			default:
+				b.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		}
+		b.WriteString("$")
+
+		glob, err := regexp.Compile(b.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q", pattern)
+		}
+		globs = append(globs, glob)
+	}
+	return globs, nil
+}
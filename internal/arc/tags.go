@@ -0,0 +1,287 @@
+package arc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+)
+
+// NormalizeTag returns the stored form of a tag: lowercase, with each level
+// of a hierarchical tag such as finance/taxes/2024 trimmed
+func NormalizeTag(tag string) string {
+	levels := strings.Split(strings.ToLower(tag), "/")
+	kept := levels[:0]
+	for _, level := range levels {
+		if level = strings.TrimSpace(level); level != "" {
+			kept = append(kept, level)
+		}
+	}
+	return strings.Join(kept, "/")
+}
+
+// TagWithin reports whether tag is parent or one of its descendants, so
+// finance/taxes is within finance but finances is not
+func TagWithin(tag, parent string) bool {
+	return tag == parent || strings.HasPrefix(tag, parent+"/")
+}
+
+// NormalizeTags normalizes tags, dropping empty ones and duplicates, and
+// sorts them
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// hasTag reports whether tags contains tag or one of its descendants,
+// ignoring case
+func hasTag(tags []string, tag string) bool {
+	tag = NormalizeTag(tag)
+	for _, t := range tags {
+		if TagWithin(NormalizeTag(t), tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAllTags reports whether tags contains every wanted tag
+func HasAllTags(tags, wanted []string) bool {
+	for _, want := range wanted {
+		if !hasTag(tags, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasAnyTag reports whether tags contains at least one wanted tag
+func HasAnyTag(tags, wanted []string) bool {
+	for _, want := range wanted {
+		if hasTag(tags, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// TagNode is one level of the tag hierarchy
+type TagNode struct {
+	Name     string // last level, e.g. 2024
+	Tag      string // full tag, e.g. finance/taxes/2024
+	Count    int    // documents tagged exactly Tag
+	Total    int    // documents tagged Tag or one of its descendants
+	Children []*TagNode
+}
+
+// TagTree returns the tags in use as a hierarchy. Parents that are only
+// used through their descendants are included with a Count of 0.
+func (m *Manager) TagTree(arc *models.Arc) []*TagNode {
+	nodes := make(map[string]*TagNode)
+	var roots []*TagNode
+
+	var node func(tag string) *TagNode
+	node = func(tag string) *TagNode {
+		if n, exists := nodes[tag]; exists {
+			return n
+		}
+		n := &TagNode{Name: tag, Tag: tag}
+		nodes[tag] = n
+		if i := strings.LastIndex(tag, "/"); i >= 0 {
+			n.Name = tag[i+1:]
+			parent := node(tag[:i])
+			parent.Children = append(parent.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+		return n
+	}
+
+	for docID, tags := range arc.Tags {
+		if _, exists := arc.Documents[docID]; !exists {
+			continue
+		}
+		counted := make(map[string]bool)
+		for _, tag := range NormalizeTags(tags) {
+			node(tag).Count++
+			for t := tag; ; {
+				if !counted[t] {
+					counted[t] = true
+					nodes[t].Total++
+				}
+				i := strings.LastIndex(t, "/")
+				if i < 0 {
+					break
+				}
+				t = t[:i]
+			}
+		}
+	}
+
+	sortTagNodes(roots)
+	return roots
+}
+
+func sortTagNodes(nodes []*TagNode) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	for _, n := range nodes {
+		sortTagNodes(n.Children)
+	}
+}
+
+// TagCount is a tag and the number of documents carrying it
+type TagCount struct {
+	Tag   string
+	Count int
+}
+
+// ListTags returns every tag in use with its document count, sorted by tag
+func (m *Manager) ListTags(arc *models.Arc) []TagCount {
+	counts := make(map[string]int)
+	for docID, tags := range arc.Tags {
+		if _, exists := arc.Documents[docID]; !exists {
+			continue
+		}
+		for _, tag := range NormalizeTags(tags) {
+			counts[tag]++
+		}
+	}
+
+	list := make([]TagCount, 0, len(counts))
+	for tag, count := range counts {
+		list = append(list, TagCount{Tag: tag, Count: count})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Tag < list[j].Tag })
+	return list
+}
+
+// RenameTag renames a tag and its descendants on every document, so
+// renaming finance to money turns finance/taxes into money/taxes. The new
+// name must not be in use yet; use MergeTags to fold one tag into another.
+func (m *Manager) RenameTag(arcID string, arc *models.Arc, key []byte, oldTag, newTag string) (int, error) {
+	oldTag, newTag = NormalizeTag(oldTag), NormalizeTag(newTag)
+	if newTag == "" {
+		return 0, fmt.Errorf("tag name cannot be empty")
+	}
+	if newTag != oldTag && TagWithin(newTag, oldTag) {
+		return 0, fmt.Errorf("cannot move tag %s under itself", oldTag)
+	}
+	if newTag != oldTag && len(m.taggedWith(arc, newTag)) > 0 {
+		return 0, fmt.Errorf("tag %s is already in use (use merge to combine tags)", newTag)
+	}
+	return m.replaceTag(arcID, arc, key, oldTag, newTag)
+}
+
+// MergeTags replaces tag from with tag into on every document, so documents
+// carrying both keep a single into tag. Descendants of from move under into.
+func (m *Manager) MergeTags(arcID string, arc *models.Arc, key []byte, from, into string) (int, error) {
+	from, into = NormalizeTag(from), NormalizeTag(into)
+	if into == "" {
+		return 0, fmt.Errorf("tag name cannot be empty")
+	}
+	if TagWithin(into, from) {
+		return 0, fmt.Errorf("cannot merge tag %s into itself", from)
+	}
+	return m.replaceTag(arcID, arc, key, from, into)
+}
+
+// replaceTag swaps oldTag for newTag on every document carrying it or one of
+// its descendants and moves their retention rules along. It returns the
+// number of documents changed.
+func (m *Manager) replaceTag(arcID string, arc *models.Arc, key []byte, oldTag, newTag string) (int, error) {
+	docIDs := m.taggedWith(arc, oldTag)
+	if len(docIDs) == 0 {
+		return 0, fmt.Errorf("no documents are tagged %s", oldTag)
+	}
+	// Replacing drops the old tag, which write-once documents don't allow
+	if err := checkRemovable(arc, docIDs); err != nil {
+		return 0, err
+	}
+
+	err := m.Batch(arcID, arc, key, func() error {
+		for _, docID := range docIDs {
+			tags := make([]string, 0, len(arc.Tags[docID]))
+			for _, tag := range arc.Tags[docID] {
+				tag, _ = retag(NormalizeTag(tag), oldTag, newTag)
+				tags = append(tags, tag)
+			}
+			arc.Tags[docID] = NormalizeTags(tags)
+			if err := m.commit(arcID, arc, key, docID); err != nil {
+				return err
+			}
+		}
+
+		moveRetentionRules(arc, oldTag, newTag)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(docIDs), nil
+}
+
+// retag renames tag when it is oldTag or one of its descendants
+func retag(tag, oldTag, newTag string) (string, bool) {
+	if tag == oldTag {
+		return newTag, true
+	}
+	if rest, ok := strings.CutPrefix(tag, oldTag+"/"); ok {
+		return newTag + "/" + rest, true
+	}
+	return tag, false
+}
+
+// moveRetentionRules hands the retention rules of oldTag and its
+// descendants to their new names. Where the new name already has a rule of
+// its own, that rule wins.
+func moveRetentionRules(arc *models.Arc, oldTag, newTag string) {
+	existing := make(map[string]bool)
+	for _, rule := range arc.RetentionRules {
+		if _, moved := retag(rule.Tag, oldTag, newTag); !moved {
+			existing[rule.Tag] = true
+		}
+	}
+
+	rules := arc.RetentionRules[:0]
+	for _, rule := range arc.RetentionRules {
+		if tag, moved := retag(rule.Tag, oldTag, newTag); moved {
+			if existing[tag] {
+				continue
+			}
+			rule.Tag = tag
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) == 0 {
+		rules = nil
+	}
+	arc.RetentionRules = rules
+	sort.Slice(arc.RetentionRules, func(i, j int) bool { return arc.RetentionRules[i].Tag < arc.RetentionRules[j].Tag })
+}
+
+// taggedWith returns the IDs of the documents carrying tag or one of its
+// descendants, sorted
+func (m *Manager) taggedWith(arc *models.Arc, tag string) []string {
+	var docIDs []string
+	for docID, tags := range arc.Tags {
+		if _, exists := arc.Documents[docID]; !exists {
+			continue
+		}
+		if hasTag(tags, tag) {
+			docIDs = append(docIDs, docID)
+		}
+	}
+	sort.Strings(docIDs)
+	return docIDs
+}
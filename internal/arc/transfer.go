@@ -0,0 +1,170 @@
+package arc
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ViniTamanhao/arcadio/internal/crypto"
+	"github.com/ViniTamanhao/arcadio/pkg/models"
+	"github.com/google/uuid"
+)
+
+// ArcHandle bundles an unlocked arc with its ID and key
+type ArcHandle struct {
+	ID  string
+	Arc *models.Arc
+	Key []byte
+}
+
+// TransferOptions controls how documents are transferred between arcs
+type TransferOptions struct {
+	Move   bool    // remove the documents from the source once the destination has them
+	Folder *string // place documents in this folder instead of keeping theirs
+}
+
+// TransferDocuments copies documents from one arc to another, re-encrypting
+// them in memory under the destination key. Tags, properties and folders
+// come along. The destination commits all documents at once, and with Move
+// the source documents are only removed after that commit succeeded.
+func (m *Manager) TransferDocuments(src, dst *ArcHandle, docIDs []string, opts TransferOptions) ([]*models.Document, error) {
+	if src.ID == dst.ID {
+		return nil, fmt.Errorf("source and destination are the same arc")
+	}
+
+	if opts.Move {
+		if err := checkRemovable(src.Arc, docIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	// Check every target path before writing anything
+	taken := make(map[string]bool, len(dst.Arc.Documents))
+	for _, doc := range dst.Arc.Documents {
+		taken[doc.Path()] = true
+	}
+
+	copies := make([]*models.Document, 0, len(docIDs))
+	for _, docID := range docIDs {
+		doc, exists := src.Arc.Documents[docID]
+		if !exists {
+			return nil, fmt.Errorf("document not found: %s", docID)
+		}
+
+		copied := copyDocument(doc)
+		if opts.Folder != nil {
+			folder, err := CleanFolder(*opts.Folder)
+			if err != nil {
+				return nil, err
+			}
+			copied.Folder = folder
+		}
+		if taken[copied.Path()] {
+			return nil, fmt.Errorf("destination already has a document at /%s", copied.Path())
+		}
+		taken[copied.Path()] = true
+
+		if _, clash := dst.Arc.Documents[copied.ID]; clash {
+			copied.ID = uuid.New().String()
+		}
+		copies = append(copies, copied)
+	}
+
+	var total int64
+	for _, copied := range copies {
+		total += copied.Size
+	}
+	if err := checkQuota(dst.Arc, total); err != nil {
+		return nil, err
+	}
+
+	written := make([]string, 0, len(copies))
+	rollback := func(cause error) ([]*models.Document, error) {
+		for _, id := range written {
+			m.deleteBlob(dst.ID, dst.Arc, id)
+			m.unindexDocument(dst.ID, dst.Key, id)
+		}
+		return nil, cause
+	}
+
+	for i, docID := range docIDs {
+		copied := copies[i]
+		m.logf("Transferring: %s\n", copied.Path())
+
+		if err := m.copyBlob(src, dst, docID, copied.ID); err != nil {
+			return rollback(err)
+		}
+		written = append(written, copied.ID)
+	}
+
+	newIDs := make([]string, 0, len(copies))
+	for i, copied := range copies {
+		dst.Arc.Documents[copied.ID] = copied
+		if tags := src.Arc.Tags[docIDs[i]]; len(tags) > 0 {
+			dst.Arc.Tags[copied.ID] = append([]string(nil), tags...)
+		}
+		newIDs = append(newIDs, copied.ID)
+	}
+
+	if err := m.commit(dst.ID, dst.Arc, dst.Key, newIDs...); err != nil {
+		for _, copied := range copies {
+			delete(dst.Arc.Documents, copied.ID)
+			delete(dst.Arc.Tags, copied.ID)
+		}
+		return rollback(fmt.Errorf("failed to update destination metadata: %w", err))
+	}
+
+	if opts.Move {
+		err := m.Batch(src.ID, src.Arc, src.Key, func() error {
+			for _, docID := range docIDs {
+				if err := m.RemoveDocument(src.ID, src.Arc, src.Key, docID); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return copies, fmt.Errorf("documents were copied but not all removed from the source: %w", err)
+		}
+	}
+
+	return copies, nil
+}
+
+// copyBlob decrypts a document of src and stores it in dst under dstDocID,
+// encrypted with the destination key
+func (m *Manager) copyBlob(src, dst *ArcHandle, srcDocID, dstDocID string) error {
+	doc := src.Arc.Documents[srcDocID]
+
+	data, err := m.GetDocument(src.ID, src.Arc, src.Key, srcDocID)
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(data)
+	if hex.EncodeToString(hash[:]) != doc.ContentHash {
+		return fmt.Errorf("document integrity check failed for /%s", doc.Path())
+	}
+
+	encrypted, err := crypto.Encrypt(dst.Key, data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt document: %w", err)
+	}
+	if err := m.writeBlob(dst.ID, dst.Arc, dstDocID, encrypted); err != nil {
+		return fmt.Errorf("failed to save encrypted document: %w", err)
+	}
+	m.indexDocument(dst.ID, dst.Key, dstDocID, doc, data)
+	return nil
+}
+
+// copyDocument duplicates a document's metadata
+func copyDocument(doc *models.Document) *models.Document {
+	copied := *doc
+	if doc.Properties != nil {
+		copied.Properties = make(map[string]*models.Property, len(doc.Properties))
+		for name, prop := range doc.Properties {
+			p := *prop
+			copied.Properties[name] = &p
+		}
+	}
+	return &copied
+}
@@ -0,0 +1,47 @@
+package arc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransferRollbackDropsIndexEntries(t *testing.T) {
+	m, store := newTestManager(t)
+	var handles []*ArcHandle
+	for _, name := range []string{"source", "target"} {
+		if _, err := m.Create(name, "password1", "q", "a"); err != nil {
+			t.Fatal(err)
+		}
+		arc, key, err := m.Unlock(name, "password1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		handles = append(handles, &ArcHandle{ID: arc.ID, Arc: arc, Key: key})
+	}
+	src, dst := handles[0], handles[1]
+
+	var docIDs []string
+	for _, name := range []string{"first.txt", "second.txt"} {
+		doc, err := m.AddDocumentFromReader(src.ID, src.Arc, src.Key, name, strings.NewReader("quarterly figures"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		docIDs = append(docIDs, doc.ID)
+	}
+
+	// The second copy fails after the first was written and indexed
+	if err := store.Delete(documentKey(src.ID, docIDs[1])); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.TransferDocuments(src, dst, docIDs, TransferOptions{}); err == nil {
+		t.Fatal("TransferDocuments succeeded without a source document")
+	}
+
+	idx, _, err := m.loadIndex(dst.ID, dst.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.docs) != 0 {
+		t.Errorf("destination index has %d entries after the rollback", len(idx.docs))
+	}
+}
@@ -0,0 +1,366 @@
+// Package arcx reads and writes .arcx files, single-file containers holding
+// every object of an arc.
+//
+// Layout:
+//
+//	"ARCX" | version (uint16) | header length (uint32) | header JSON
+//	entries, each: 'E' | name length (uint16) | name | chunks...
+//	  chunk: length (uint32) | data, terminated by a zero-length chunk
+//	'I' | index length (uint32) | index JSON
+//	SHA-256 of everything above (32 bytes) | "ARCXEND\n"
+//
+// Every integer is big-endian. Entries are streamed, so neither side needs
+// to hold an object in memory; the trailing index lets readers verify that
+// nothing was dropped, reordered or altered.
+package arcx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+)
+
+const (
+	// Version is the container format version written by this package
+	Version = 1
+
+	chunkSize = 64 << 10
+
+	// maxHeaderSize and maxIndexSize bound what a reader allocates for
+	// lengths taken from the container. The index lists every object, so it
+	// gets room for a few hundred thousand entries.
+	maxHeaderSize = 1 << 20
+	maxIndexSize  = 64 << 20
+
+	entryMarker = 'E'
+	indexMarker = 'I'
+)
+
+var (
+	magic   = []byte("ARCX")
+	trailer = []byte("ARCXEND\n")
+
+	// ErrCorrupt is returned when a container fails validation
+	ErrCorrupt = errors.New("arcx: container is corrupt")
+)
+
+// Header describes the arc stored in a container
+type Header struct {
+	FormatVersion int       `json:"format_version"`
+	ArcID         string    `json:"arc_id"`
+	Name          string    `json:"name"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExportedAt    time.Time `json:"exported_at"`
+}
+
+// IndexEntry records one stored object
+type IndexEntry struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Writer streams objects into a container
+type Writer struct {
+	out   io.Writer // underlying writer
+	w     io.Writer // out plus the running stream hash
+	sum   hash.Hash
+	index []IndexEntry
+	buf   []byte
+}
+
+// NewWriter writes the container preamble and header to w
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	header.FormatVersion = Version
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.New()
+	aw := &Writer{
+		out: w,
+		w:   io.MultiWriter(w, sum),
+		sum: sum,
+		buf: make([]byte, chunkSize),
+	}
+
+	var pre bytes.Buffer
+	pre.Write(magic)
+	binary.Write(&pre, binary.BigEndian, uint16(Version))
+	binary.Write(&pre, binary.BigEndian, uint32(len(headerJSON)))
+	pre.Write(headerJSON)
+	if _, err := aw.w.Write(pre.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return aw, nil
+}
+
+// Add streams one object into the container
+func (aw *Writer) Add(name string, r io.Reader) error {
+	if len(name) == 0 || len(name) > 0xffff {
+		return fmt.Errorf("arcx: invalid entry name %q", name)
+	}
+
+	var pre bytes.Buffer
+	pre.WriteByte(entryMarker)
+	binary.Write(&pre, binary.BigEndian, uint16(len(name)))
+	pre.WriteString(name)
+	if _, err := aw.w.Write(pre.Bytes()); err != nil {
+		return err
+	}
+
+	entrySum := sha256.New()
+	var size int64
+	var lenBuf [4]byte
+
+	for {
+		n, err := io.ReadFull(r, aw.buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(lenBuf[:], uint32(n))
+			if _, werr := aw.w.Write(lenBuf[:]); werr != nil {
+				return werr
+			}
+			if _, werr := aw.w.Write(aw.buf[:n]); werr != nil {
+				return werr
+			}
+			entrySum.Write(aw.buf[:n])
+			size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	binary.BigEndian.PutUint32(lenBuf[:], 0)
+	if _, err := aw.w.Write(lenBuf[:]); err != nil {
+		return err
+	}
+
+	aw.index = append(aw.index, IndexEntry{
+		Name:   name,
+		Size:   size,
+		SHA256: hex.EncodeToString(entrySum.Sum(nil)),
+	})
+	return nil
+}
+
+// Close writes the index and trailer. It does not close the underlying writer.
+func (aw *Writer) Close() error {
+	indexJSON, err := json.Marshal(aw.index)
+	if err != nil {
+		return err
+	}
+
+	var pre bytes.Buffer
+	pre.WriteByte(indexMarker)
+	binary.Write(&pre, binary.BigEndian, uint32(len(indexJSON)))
+	pre.Write(indexJSON)
+	if _, err := aw.w.Write(pre.Bytes()); err != nil {
+		return err
+	}
+
+	// The stream hash must not cover itself, so it bypasses the hashing writer
+	if _, err := aw.out.Write(aw.sum.Sum(nil)); err != nil {
+		return err
+	}
+	_, err = aw.out.Write(trailer)
+	return err
+}
+
+// Reader streams objects out of a container
+type Reader struct {
+	r      io.Reader
+	sum    hash.Hash
+	header Header
+
+	current  *entryReader
+	index    []IndexEntry
+	finished bool
+}
+
+// NewReader reads and validates the container preamble
+func NewReader(r io.Reader) (*Reader, error) {
+	sum := sha256.New()
+	ar := &Reader{r: io.TeeReader(r, sum), sum: sum}
+
+	var pre [4 + 2 + 4]byte
+	if _, err := io.ReadFull(ar.r, pre[:]); err != nil {
+		return nil, fmt.Errorf("arcx: failed to read header: %w", err)
+	}
+	if !bytes.Equal(pre[:4], magic) {
+		return nil, errors.New("arcx: not an arcx file")
+	}
+	if version := binary.BigEndian.Uint16(pre[4:6]); version != Version {
+		return nil, fmt.Errorf("arcx: unsupported format version %d", version)
+	}
+
+	headerLen := binary.BigEndian.Uint32(pre[6:10])
+	if headerLen > maxHeaderSize {
+		return nil, ErrCorrupt
+	}
+	headerJSON := make([]byte, headerLen)
+	if _, err := io.ReadFull(ar.r, headerJSON); err != nil {
+		return nil, fmt.Errorf("arcx: failed to read header: %w", err)
+	}
+	if err := json.Unmarshal(headerJSON, &ar.header); err != nil {
+		return nil, fmt.Errorf("arcx: invalid header: %w", err)
+	}
+
+	return ar, nil
+}
+
+// Header returns the container header
+func (ar *Reader) Header() Header {
+	return ar.header
+}
+
+// Next advances to the next object and returns its name and content. The
+// content reader is only valid until the following call to Next. Once all
+// objects have been read, Next checks the trailing index and stream hash
+// and returns io.EOF if everything matches.
+func (ar *Reader) Next() (string, io.Reader, error) {
+	if ar.finished {
+		return "", nil, io.EOF
+	}
+
+	if ar.current != nil {
+		if _, err := io.Copy(io.Discard, ar.current); err != nil {
+			return "", nil, err
+		}
+		ar.index = append(ar.index, ar.current.entry())
+		ar.current = nil
+	}
+
+	var marker [1]byte
+	if _, err := io.ReadFull(ar.r, marker[:]); err != nil {
+		return "", nil, fmt.Errorf("%w: unexpected end of file", ErrCorrupt)
+	}
+
+	switch marker[0] {
+	case entryMarker:
+		var nameLen [2]byte
+		if _, err := io.ReadFull(ar.r, nameLen[:]); err != nil {
+			return "", nil, ErrCorrupt
+		}
+		name := make([]byte, binary.BigEndian.Uint16(nameLen[:]))
+		if _, err := io.ReadFull(ar.r, name); err != nil {
+			return "", nil, ErrCorrupt
+		}
+
+		ar.current = &entryReader{r: ar.r, name: string(name), sum: sha256.New()}
+		return ar.current.name, ar.current, nil
+
+	case indexMarker:
+		if err := ar.verify(); err != nil {
+			return "", nil, err
+		}
+		ar.finished = true
+		return "", nil, io.EOF
+
+	default:
+		return "", nil, fmt.Errorf("%w: unknown marker %q", ErrCorrupt, marker[0])
+	}
+}
+
+// verify compares what was read against the stored index and stream hash
+func (ar *Reader) verify() error {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(ar.r, lenBuf[:]); err != nil {
+		return ErrCorrupt
+	}
+	indexLen := binary.BigEndian.Uint32(lenBuf[:])
+	if indexLen > maxIndexSize {
+		return fmt.Errorf("%w: index too large", ErrCorrupt)
+	}
+	indexJSON := make([]byte, indexLen)
+	if _, err := io.ReadFull(ar.r, indexJSON); err != nil {
+		return ErrCorrupt
+	}
+
+	expectedSum := ar.sum.Sum(nil)
+
+	var stored []IndexEntry
+	if err := json.Unmarshal(indexJSON, &stored); err != nil {
+		return fmt.Errorf("%w: invalid index", ErrCorrupt)
+	}
+
+	// The stream hash and trailer are not part of the hashed data
+	var tail [sha256.Size + 8]byte
+	if _, err := io.ReadFull(ar.r, tail[:]); err != nil {
+		return fmt.Errorf("%w: missing trailer", ErrCorrupt)
+	}
+	if !bytes.Equal(tail[:sha256.Size], expectedSum) || !bytes.Equal(tail[sha256.Size:], trailer) {
+		return fmt.Errorf("%w: stream checksum mismatch", ErrCorrupt)
+	}
+
+	if len(stored) != len(ar.index) {
+		return fmt.Errorf("%w: index lists %d objects, found %d", ErrCorrupt, len(stored), len(ar.index))
+	}
+	for i := range stored {
+		if stored[i] != ar.index[i] {
+			return fmt.Errorf("%w: object %s does not match index", ErrCorrupt, ar.index[i].Name)
+		}
+	}
+
+	return nil
+}
+
+// entryReader reads the chunked content of one object
+type entryReader struct {
+	r         io.Reader
+	name      string
+	sum       hash.Hash
+	size      int64
+	remaining uint32
+	done      bool
+}
+
+func (er *entryReader) Read(p []byte) (int, error) {
+	if er.done {
+		return 0, io.EOF
+	}
+
+	if er.remaining == 0 {
+		var lenBuf [4]byte
+		if _, err := io.ReadFull(er.r, lenBuf[:]); err != nil {
+			return 0, fmt.Errorf("%w: truncated object %s", ErrCorrupt, er.name)
+		}
+		er.remaining = binary.BigEndian.Uint32(lenBuf[:])
+		if er.remaining == 0 {
+			er.done = true
+			return 0, io.EOF
+		}
+	}
+
+	if uint32(len(p)) > er.remaining {
+		p = p[:er.remaining]
+	}
+	n, err := er.r.Read(p)
+	er.remaining -= uint32(n)
+	er.size += int64(n)
+	er.sum.Write(p[:n])
+	if err == io.EOF {
+		err = fmt.Errorf("%w: truncated object %s", ErrCorrupt, er.name)
+	}
+	return n, err
+}
+
+func (er *entryReader) entry() IndexEntry {
+	return IndexEntry{
+		Name:   er.name,
+		Size:   er.size,
+		SHA256: hex.EncodeToString(er.sum.Sum(nil)),
+	}
+}
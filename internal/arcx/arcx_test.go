@@ -0,0 +1,124 @@
+package arcx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func writeContainer(t *testing.T, objects map[string]string, order []string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{ArcID: "id", Name: "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range order {
+		if err := w.Add(name, strings.NewReader(objects[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// readContainer reads every object, returning the first error
+func readContainer(data []byte) (map[string]string, error) {
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	objects := make(map[string]string)
+	for {
+		name, content, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return nil, err
+		}
+		objects[name] = string(data)
+	}
+}
+
+// indexLengthAt finds the offset of the index length field
+func indexLengthAt(t *testing.T, data []byte) int {
+	t.Helper()
+	for p := len(data) - 44; p > 0; p-- {
+		if data[p-1] == indexMarker && int(binary.BigEndian.Uint32(data[p:])) == len(data)-p-4-40 {
+			return p
+		}
+	}
+	t.Fatal("index not found")
+	return 0
+}
+
+func TestRoundTrip(t *testing.T) {
+	objects := map[string]string{
+		"arc.sec":      "security",
+		"arc.meta":     strings.Repeat("m", 3*chunkSize+17),
+		"documents/a":  "",
+		"packs/x.pack": "pack",
+	}
+	order := []string{"arc.sec", "arc.meta", "documents/a", "packs/x.pack"}
+
+	got, err := readContainer(writeContainer(t, objects, order))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(got) != len(objects) {
+		t.Fatalf("read %d objects, want %d", len(got), len(objects))
+	}
+	for name, want := range objects {
+		if got[name] != want {
+			t.Errorf("%s: read %d bytes, want %d", name, len(got[name]), len(want))
+		}
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	objects := map[string]string{"a": "first object", "b": "second object"}
+	valid := writeContainer(t, objects, []string{"a", "b"})
+
+	tests := []struct {
+		name   string
+		modify func(data []byte) []byte
+	}{
+		{"flipped content byte", func(data []byte) []byte {
+			i := bytes.Index(data, []byte("first"))
+			data[i] ^= 1
+			return data
+		}},
+		{"truncated", func(data []byte) []byte { return data[:len(data)-20] }},
+		{"missing trailer", func(data []byte) []byte { return data[:len(data)-8] }},
+		{"huge index length", func(data []byte) []byte {
+			binary.BigEndian.PutUint32(data[indexLengthAt(t, data):], 0xFFFFFFFF)
+			return data
+		}},
+		{"index length past the cap", func(data []byte) []byte {
+			binary.BigEndian.PutUint32(data[indexLengthAt(t, data):], maxIndexSize+1)
+			return data
+		}},
+		{"huge header length", func(data []byte) []byte {
+			binary.BigEndian.PutUint32(data[6:], 0xFFFFFFFF)
+			return data
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readContainer(tt.modify(bytes.Clone(valid)))
+			if !errors.Is(err, ErrCorrupt) {
+				t.Fatalf("error = %v, want ErrCorrupt", err)
+			}
+		})
+	}
+}
@@ -2,8 +2,12 @@
 package auth
 
 import (
+	"bufio"
 	"fmt"
-	"syscall"
+	"io"
+	"os"
+	"runtime"
+	"strings"
 	"time"
 
 	"github.com/ViniTamanhao/arcadio/internal/keyring"
@@ -13,16 +17,34 @@ import (
 type Manager struct {
 	keyStore *keyring.KeyStore
 	sessionCache *keyring.SessionCache
+	config Config
+
+	supply   func() (string, error) // reads --password-file or --password-fd
+	supplied *string                // the supplied password, once read
+	noPrompt bool
 }
 
 func NewManager(cacheDir string) *Manager {
+	config, err := LoadConfig(cacheDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+
 	return &Manager{
 		keyStore: keyring.NewKeyStore(),
 		sessionCache: keyring.NewSessionCache(cacheDir, 15*time.Minute),
+		config: config,
 	}
 }
 
+// GetPassword returns the password of an arc. A password supplied with a
+// file, file descriptor or ARC_PASSWORD wins; otherwise the session cache,
+// the keyring and password_command are tried before prompting.
 func (m *Manager) GetPassword(arcID, arcName string, allowPrompt bool) (string, error) {
+	if password, ok, err := m.SuppliedPassword(); ok || err != nil {
+		return password, err
+	}
+
 	if password, ok := m.sessionCache.Get(arcID); ok {
 		return password, nil
 	}
@@ -32,8 +54,15 @@ func (m *Manager) GetPassword(arcID, arcName string, allowPrompt bool) (string,
 		return password, nil
 	}
 
-	if !allowPrompt {
-		return "", fmt.Errorf("password not found and prompting disabled")
+	if password, ok, err := m.commandPassword(arcID, arcName); ok || err != nil {
+		if ok {
+			m.sessionCache.Set(arcID, password)
+		}
+		return password, err
+	}
+
+	if !allowPrompt || m.noPrompt {
+		return "", noPasswordError(arcName)
 	}
 
 	password, err := m.promptPassword(arcName)
@@ -43,9 +72,9 @@ func (m *Manager) GetPassword(arcID, arcName string, allowPrompt bool) (string,
 
 	if m.askSavePassword() {
 		if err := m.keyStore.SavePassword(arcID, password); err != nil {
-			fmt.Printf("Warning: Failed to save password to keyring: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Warning: Failed to save password to keyring: %v\n", err)
 		} else {
-			fmt.Println("Password saved to system keyring")
+			fmt.Fprintln(os.Stderr, "Password saved to system keyring")
 		}
 	}
 
@@ -54,6 +83,59 @@ func (m *Manager) GetPassword(arcID, arcName string, allowPrompt bool) (string,
 	return password, nil
 }
 
+// ReadPassword asks for an arc's password without looking in the session
+// cache or keyring, e.g. to check it before saving it
+func (m *Manager) ReadPassword(arcID, arcName string) (string, error) {
+	if password, ok, err := m.SuppliedPassword(); ok || err != nil {
+		return password, err
+	}
+	if password, ok, err := m.commandPassword(arcID, arcName); ok || err != nil {
+		return password, err
+	}
+	if m.noPrompt {
+		return "", noPasswordError(arcName)
+	}
+	return m.promptPassword(arcName)
+}
+
+// noPasswordError explains how to give a password without a prompt
+func noPasswordError(arcName string) error {
+	return fmt.Errorf("no password for arc %s: %w (use --password-file, --password-fd, %s or password_command)", arcName, ErrNoPrompt, PasswordEnv)
+}
+
+// Prompt asks a question on the terminal and returns the answer
+func (m *Manager) Prompt(label string) (string, error) {
+	tty, err := m.openPrompt()
+	if err != nil {
+		return "", err
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty.out, label)
+	answer, err := bufio.NewReader(tty.in).ReadString('\n')
+	if err != nil && answer == "" {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+// PromptSecret asks for a secret on the terminal without echoing it
+func (m *Manager) PromptSecret(label string) (string, error) {
+	tty, err := m.openPrompt()
+	if err != nil {
+		return "", err
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty.out, label)
+	secret, err := term.ReadPassword(int(tty.in.Fd()))
+	fmt.Fprintln(tty.out)
+	if err != nil {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	return string(secret), nil
+}
+
 func (m *Manager) SavePassword(arcID, password string) error {
 	return m.keyStore.SavePassword(arcID, password)
 }
@@ -71,23 +153,83 @@ func (m *Manager) ClearSession() {
 	m.sessionCache.ClearAll()
 }
 
+// promptPassword reads a password from the terminal. The prompt and input
+// use the controlling terminal, so stdin and stdout stay free for data, as in
+// "arc cat <arc> <doc> > out" or "arc add <arc> -".
 func (m *Manager) promptPassword(arcName string) (string, error) {
-	fmt.Printf("Unlocking arc: %s\n", arcName)
-	fmt.Print("Enter password: ")
-	
-	passwordBytes, err := term.ReadPassword(int(syscall.Stdin))
+	tty, err := m.openPrompt()
+	if err != nil {
+		return "", err
+	}
+	defer tty.Close()
+
+	fmt.Fprintf(tty.out, "Unlocking arc: %s\n", arcName)
+	fmt.Fprint(tty.out, "Enter password: ")
+
+	passwordBytes, err := term.ReadPassword(int(tty.in.Fd()))
 	if err != nil {
 		return "", fmt.Errorf("failed to read password: %w", err)
 	}
-	fmt.Println()
+	fmt.Fprintln(tty.out)
 
 	return string(passwordBytes), nil
 }
 
-// askSavePassword asks user if they want to save the password
+// openPrompt opens the terminal for a prompt, unless prompting is disabled
+func (m *Manager) openPrompt() (*terminal, error) {
+	if m.noPrompt {
+		return nil, ErrNoPrompt
+	}
+	return openTerminal()
+}
+
+// askSavePassword asks user if they want to save the password. Without a
+// terminal the answer is no.
 func (m *Manager) askSavePassword() bool {
-	fmt.Print("Save password to system keyring? [y/N]: ")
-	var response string
-	fmt.Scanln(&response)
+	tty, err := m.openPrompt()
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty.out, "Save password to system keyring? [y/N]: ")
+	response, _ := bufio.NewReader(tty.in).ReadString('\n')
+	response = strings.TrimSpace(response)
 	return response == "y" || response == "Y" || response == "yes"
 }
+
+// terminal is where prompts are written and answers read
+type terminal struct {
+	in    *os.File
+	out   io.Writer
+	close func()
+}
+
+func (t *terminal) Close() {
+	t.close()
+}
+
+// openTerminal opens the controlling terminal of the process, falling back
+// to stdin and stderr when there is none but stdin is interactive
+func openTerminal() (*terminal, error) {
+	path := "/dev/tty"
+	if runtime.GOOS == "windows" {
+		path = "CONIN$"
+	}
+
+	if tty, err := os.OpenFile(path, os.O_RDWR, 0); err == nil {
+		if term.IsTerminal(int(tty.Fd())) {
+			out := io.Writer(tty)
+			if runtime.GOOS == "windows" {
+				out = os.Stderr
+			}
+			return &terminal{in: tty, out: out, close: func() { tty.Close() }}, nil
+		}
+		tty.Close()
+	}
+
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return &terminal{in: os.Stdin, out: os.Stderr, close: func() {}}, nil
+	}
+	return nil, fmt.Errorf("no terminal available to prompt for the password")
+}
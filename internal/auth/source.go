@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// PasswordEnv is the environment variable read for the password
+const PasswordEnv = "ARC_PASSWORD"
+
+// ErrNoPrompt is returned when a password is needed but prompting is disabled
+var ErrNoPrompt = errors.New("prompting is disabled")
+
+// Config holds the settings read from config.json in the config directory
+type Config struct {
+	// PasswordCommand is run through the shell to print an arc's password,
+	// e.g. "pass show arc/$ARC_NAME". ARC_ID and ARC_NAME are set for it.
+	PasswordCommand string `json:"password_command,omitempty"`
+}
+
+// LoadConfig reads config.json from dir. A missing file is an empty config.
+func LoadConfig(dir string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return cfg, nil
+}
+
+// SetPasswordFile makes every arc use the password stored in a file
+func (m *Manager) SetPasswordFile(path string) {
+	m.supply = func() (string, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password file: %w", err)
+		}
+		return trimPassword(data), nil
+	}
+}
+
+// SetPasswordFD makes every arc use the password read from an open file
+// descriptor, e.g. 3 for "arc ... --password-fd 3 3<secret"
+func (m *Manager) SetPasswordFD(fd int) {
+	m.supply = func() (string, error) {
+		f := os.NewFile(uintptr(fd), "fd"+strconv.Itoa(fd))
+		if f == nil {
+			return "", fmt.Errorf("invalid password file descriptor: %d", fd)
+		}
+		defer f.Close()
+
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return "", fmt.Errorf("failed to read password from file descriptor %d: %w", fd, err)
+		}
+		return trimPassword(data), nil
+	}
+}
+
+// SetNoPrompt makes a missing password an error instead of a prompt
+func (m *Manager) SetNoPrompt(noPrompt bool) {
+	m.noPrompt = noPrompt
+}
+
+// SuppliedPassword returns the password given with SetPasswordFile,
+// SetPasswordFD or ARC_PASSWORD, reading it only once. ok is false when
+// none was given.
+func (m *Manager) SuppliedPassword() (password string, ok bool, err error) {
+	if m.supplied != nil {
+		return *m.supplied, true, nil
+	}
+
+	if m.supply != nil {
+		if password, err = m.supply(); err != nil {
+			return "", false, err
+		}
+	} else if env, set := os.LookupEnv(PasswordEnv); set {
+		fmt.Fprintf(os.Stderr, "Warning: using the password in %s, which other processes may be able to read; prefer --password-file or --password-fd\n", PasswordEnv)
+		password = env
+	} else {
+		return "", false, nil
+	}
+
+	if password == "" {
+		return "", false, fmt.Errorf("the supplied password is empty")
+	}
+	m.supplied = &password
+	return password, true, nil
+}
+
+// commandPassword runs the configured password_command for an arc. ok is
+// false when no command is configured.
+func (m *Manager) commandPassword(arcID, arcName string) (password string, ok bool, err error) {
+	if m.config.PasswordCommand == "" {
+		return "", false, nil
+	}
+
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(shell, flag, m.config.PasswordCommand)
+	cmd.Env = append(os.Environ(), "ARC_ID="+arcID, "ARC_NAME="+arcName)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", false, fmt.Errorf("password_command failed: %w: %s", err, msg)
+		}
+		return "", false, fmt.Errorf("password_command failed: %w", err)
+	}
+
+	password = trimPassword(out)
+	if password == "" {
+		return "", false, fmt.Errorf("password_command printed no password")
+	}
+	return password, true, nil
+}
+
+// trimPassword drops the line ending a password file or command ends with
+func trimPassword(data []byte) string {
+	return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+}
@@ -0,0 +1,169 @@
+// Package extract pulls searchable plain text out of documents. It handles
+// plain text, Markdown, HTML and the text layer of PDFs; other content
+// yields no text.
+package extract
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxText is the most text the search index keeps per document. Decoding
+// limits are multiples of it.
+const MaxText = 1 << 20
+
+// Text returns the readable text of a document of the given content type.
+// Unsupported types return "" without an error.
+func Text(contentType string, data []byte) (string, error) {
+	switch {
+	case contentType == "application/pdf":
+		text, err := PDF(data)
+		return collapseSpace(text), err
+	case contentType == "text/markdown":
+		return collapseSpace(Markdown(toUTF8(data))), nil
+	case contentType == "text/html", contentType == "application/xhtml+xml":
+		return collapseSpace(HTML(toUTF8(data))), nil
+	case Supported(contentType):
+		return collapseSpace(toUTF8(data)), nil
+	}
+	return "", nil
+}
+
+// Supported reports whether text can be extracted from a content type
+func Supported(contentType string) bool {
+	if strings.HasPrefix(contentType, "text/") {
+		return true
+	}
+	switch contentType {
+	case "application/pdf", "application/xhtml+xml", "application/json", "application/yaml",
+		"application/toml", "application/xml", "application/x-sh":
+		return true
+	}
+	return false
+}
+
+// toUTF8 decodes data as UTF-8, reading it as Latin-1 when it is not valid
+// UTF-8
+func toUTF8(data []byte) string {
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+var (
+	mdImage    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	mdRefLink  = regexp.MustCompile(`(?m)^\s*\[[^\]]+\]:\s+\S+.*$`)
+	mdLineMark = regexp.MustCompile(`(?m)^\s{0,3}(#{1,6}\s+|>\s?|[-*+]\s+|\d+[.)]\s+)`)
+	mdFence    = regexp.MustCompile("(?m)^\\s*(```|~~~).*$")
+	mdEmphasis = regexp.MustCompile("\\*\\*|__|~~|`|\\*")
+)
+
+// Markdown strips Markdown syntax, keeping link and image text
+func Markdown(src string) string {
+	src = mdFence.ReplaceAllString(src, "")
+	src = mdRefLink.ReplaceAllString(src, "")
+	src = mdImage.ReplaceAllString(src, "$1")
+	src = mdLink.ReplaceAllString(src, "$1")
+	src = mdLineMark.ReplaceAllString(src, "")
+	return mdEmphasis.ReplaceAllString(src, "")
+}
+
+// blockTags end a line of text in HTML
+var blockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "td": true, "th": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"title": true, "section": true, "article": true, "header": true, "footer": true,
+	"blockquote": true, "pre": true, "table": true, "ul": true, "ol": true, "hr": true,
+}
+
+// HTML returns the text of an HTML document, dropping tags, comments,
+// scripts and styles
+func HTML(src string) string {
+	var b strings.Builder
+	for len(src) > 0 {
+		lt := strings.IndexByte(src, '<')
+		if lt < 0 {
+			b.WriteString(html.UnescapeString(src))
+			break
+		}
+		b.WriteString(html.UnescapeString(src[:lt]))
+		src = src[lt:]
+
+		if strings.HasPrefix(src, "<!--") {
+			end := strings.Index(src, "-->")
+			if end < 0 {
+				break
+			}
+			src = src[end+3:]
+			continue
+		}
+
+		gt := strings.IndexByte(src, '>')
+		if gt < 0 {
+			break
+		}
+		closing := strings.HasPrefix(src, "</")
+		name := tagName(src[1:gt])
+		src = src[gt+1:]
+
+		if !closing && (name == "script" || name == "style") {
+			end := strings.Index(strings.ToLower(src), "</"+name)
+			if end < 0 {
+				break
+			}
+			src = src[end:]
+			continue
+		}
+		if blockTags[name] {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// tagName returns the lowercase element name of the inside of a tag
+func tagName(tag string) string {
+	tag = strings.TrimPrefix(tag, "/")
+	end := strings.IndexFunc(tag, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '/'
+	})
+	if end >= 0 {
+		tag = tag[:end]
+	}
+	return strings.ToLower(tag)
+}
+
+// collapseSpace reduces runs of whitespace to a single space, or to a
+// newline when the run spans lines
+func collapseSpace(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	pending := rune(0)
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == 0 {
+			if r == '\n' || pending == 0 {
+				pending = r
+			}
+			continue
+		}
+		if pending != 0 && b.Len() > 0 {
+			if pending == '\n' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(' ')
+			}
+		}
+		pending = 0
+		b.WriteRune(r)
+	}
+	return b.String()
+}
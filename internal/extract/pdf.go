@@ -0,0 +1,970 @@
+package extract
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrEncryptedPDF is returned for PDFs whose content is encrypted
+var ErrEncryptedPDF = errors.New("PDF is encrypted")
+
+// Limits that keep a malformed or hostile PDF from exhausting memory or the
+// stack
+const (
+	maxStreamSize  = 16 * MaxText // decoded bytes per stream
+	maxDecodedSize = 64 * MaxText // decoded bytes per file
+	maxNesting     = 128          // depth of nested arrays and dictionaries
+	maxPageNodes   = 1 << 16      // page tree nodes visited
+	maxCMapCodes   = 1 << 17      // character codes mapped by one CMap
+)
+
+// PDF values, as parsed from the file
+type (
+	pdfName  string
+	pdfOp    string // a bare keyword: an operator in content streams
+	pdfArray []any
+	pdfDict  map[pdfName]any
+	pdfRef   struct{ num, gen int }
+)
+
+// pdfObject is one indirect object and its raw (still encoded) stream
+type pdfObject struct {
+	value  any
+	stream []byte
+}
+
+// pdfFile holds the indirect objects of a PDF
+type pdfFile struct {
+	objects map[int]*pdfObject
+	decoded int // bytes decoded so far, bounded by maxDecodedSize
+}
+
+var objHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// PDF extracts the text layer of a PDF. Scanned pages without a text layer
+// yield no text. A file the parser cannot cope with is reported as an error,
+// never a panic.
+func PDF(data []byte) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\f\r "), []byte("%PDF")) {
+		return "", fmt.Errorf("not a PDF file")
+	}
+
+	f := &pdfFile{objects: make(map[int]*pdfObject)}
+	f.scanObjects(data)
+	f.expandObjectStreams()
+
+	if bytes.Contains(data, []byte("/Encrypt")) && f.encrypted(data) {
+		return "", ErrEncryptedPDF
+	}
+
+	var b strings.Builder
+	for _, page := range f.pages() {
+		f.pageText(&b, page)
+		b.WriteByte('\n')
+	}
+	return b.String(), nil
+}
+
+// scanObjects finds every "N G obj ... endobj" in the file. Objects that
+// appear again later, as in incrementally updated files, replace the
+// earlier version.
+func (f *pdfFile) scanObjects(data []byte) {
+	pos := 0
+	for {
+		loc := objHeader.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			return
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		lx := &pdfLexer{data: data, pos: pos + loc[1]}
+		pos += loc[1]
+
+		value, err := lx.value()
+		if err != nil {
+			continue
+		}
+		obj := &pdfObject{value: value}
+
+		if lx.keyword("stream") {
+			start := lx.pos
+			if start < len(data) && data[start] == '\r' {
+				start++
+			}
+			if start < len(data) && data[start] == '\n' {
+				start++
+			}
+			end := -1
+			if dict, ok := value.(pdfDict); ok {
+				if n, ok := dict["Length"].(float64); ok && n >= 0 && n <= float64(len(data)-start) &&
+					bytes.HasPrefix(bytes.TrimLeft(data[start+int(n):], "\r\n "), []byte("endstream")) {
+					end = start + int(n)
+				}
+			}
+			if end < 0 {
+				i := bytes.Index(data[start:], []byte("endstream"))
+				if i < 0 {
+					continue
+				}
+				end = start + i
+				for end > start && (data[end-1] == '\n' || data[end-1] == '\r') {
+					end--
+				}
+			}
+			obj.stream = data[start:end]
+			pos = end
+		} else {
+			pos = lx.pos
+		}
+		f.objects[num] = obj
+	}
+}
+
+// expandObjectStreams parses the objects packed into object streams
+func (f *pdfFile) expandObjectStreams() {
+	var streams []*pdfObject
+	for _, obj := range f.objects {
+		if dict, ok := obj.value.(pdfDict); ok && dict["Type"] == pdfName("ObjStm") {
+			streams = append(streams, obj)
+		}
+	}
+
+	for _, obj := range streams {
+		dict := obj.value.(pdfDict)
+		data, err := f.decode(dict, obj.stream)
+		if err != nil {
+			continue
+		}
+		count, _ := dict["N"].(float64)
+		first, _ := dict["First"].(float64)
+		if first < 0 || first > float64(len(data)) {
+			continue
+		}
+
+		lx := &pdfLexer{data: data[:int(first)]}
+		for i := 0; i < int(count); i++ {
+			num, err1 := lx.value()
+			offset, err2 := lx.value()
+			n, ok1 := num.(float64)
+			o, ok2 := offset.(float64)
+			if err1 != nil || err2 != nil || !ok1 || !ok2 {
+				break
+			}
+			if _, exists := f.objects[int(n)]; exists {
+				continue
+			}
+			if o < 0 || o >= float64(len(data)) {
+				continue
+			}
+			at := int(first) + int(o)
+			if at < 0 || at >= len(data) {
+				continue
+			}
+			value, err := (&pdfLexer{data: data, pos: at}).value()
+			if err == nil {
+				f.objects[int(n)] = &pdfObject{value: value}
+			}
+		}
+	}
+}
+
+// resolve follows indirect references
+func (f *pdfFile) resolve(v any) any {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		obj, exists := f.objects[ref.num]
+		if !exists {
+			return nil
+		}
+		v = obj.value
+	}
+	return nil
+}
+
+// dict resolves v to a dictionary, or nil
+func (f *pdfFile) dict(v any) pdfDict {
+	d, _ := f.resolve(v).(pdfDict)
+	return d
+}
+
+// streamData returns the decoded stream of the object v refers to
+func (f *pdfFile) streamData(v any) []byte {
+	ref, ok := v.(pdfRef)
+	if !ok {
+		return nil
+	}
+	obj, exists := f.objects[ref.num]
+	if !exists || obj.stream == nil {
+		return nil
+	}
+	dict, _ := obj.value.(pdfDict)
+	data, err := f.decode(dict, obj.stream)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// decode applies the stream's filters
+func (f *pdfFile) decode(dict pdfDict, data []byte) ([]byte, error) {
+	var filters []any
+	switch filter := f.resolve(dict["Filter"]).(type) {
+	case pdfName:
+		filters = []any{filter}
+	case pdfArray:
+		filters = filter
+	}
+
+	for _, filter := range filters {
+		var err error
+		switch f.resolve(filter) {
+		case pdfName("FlateDecode"), pdfName("Fl"):
+			data, err = inflate(data, min(maxStreamSize, maxDecodedSize-f.decoded))
+		case pdfName("ASCIIHexDecode"), pdfName("AHx"):
+			data, err = hexDecode(data)
+		default:
+			err = fmt.Errorf("unsupported filter %v", filter)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	f.decoded += len(data)
+	if f.decoded > maxDecodedSize {
+		return nil, fmt.Errorf("PDF streams exceed %d bytes", maxDecodedSize)
+	}
+	return data, nil
+}
+
+// inflate decompresses a zlib stream, keeping what could be read from a
+// truncated one. Output beyond limit bytes is dropped.
+func inflate(data []byte, limit int) ([]byte, error) {
+	var r io.Reader
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		r = flate.NewReader(bytes.NewReader(data))
+	} else {
+		r = zr
+	}
+	out, err := io.ReadAll(io.LimitReader(r, int64(max(limit, 0))))
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+// hexDecode decodes an ASCIIHexDecode stream
+func hexDecode(data []byte) ([]byte, error) {
+	var digits []byte
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		if isHexDigit(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	return hex.DecodeString(string(digits))
+}
+
+// encrypted reports whether a trailer names an encryption dictionary
+func (f *pdfFile) encrypted(data []byte) bool {
+	for _, obj := range f.objects {
+		if dict, ok := obj.value.(pdfDict); ok && dict["Type"] == pdfName("XRef") && dict["Encrypt"] != nil {
+			return true
+		}
+	}
+	for pos := 0; ; {
+		i := bytes.Index(data[pos:], []byte("trailer"))
+		if i < 0 {
+			return false
+		}
+		lx := &pdfLexer{data: data, pos: pos + i + len("trailer")}
+		if trailer, ok := valueOrNil(lx).(pdfDict); ok && trailer["Encrypt"] != nil {
+			return true
+		}
+		pos = lx.pos
+	}
+}
+
+// pages returns the page dictionaries in document order, each with the
+// resources it inherits
+func (f *pdfFile) pages() []pdfDict {
+	var pages []pdfDict
+	visited := 0
+	var walk func(node pdfDict, resources any, depth int)
+	walk = func(node pdfDict, resources any, depth int) {
+		// Kids can point back up the tree, so the walk is bounded
+		if node == nil || depth > 64 || visited >= maxPageNodes {
+			return
+		}
+		visited++
+		if r, ok := node["Resources"]; ok {
+			resources = r
+		}
+		if node["Type"] == pdfName("Page") {
+			page := make(pdfDict, len(node)+1)
+			for k, v := range node {
+				page[k] = v
+			}
+			page["Resources"] = resources
+			pages = append(pages, page)
+			return
+		}
+		kids, _ := f.resolve(node["Kids"]).(pdfArray)
+		for _, kid := range kids {
+			walk(f.dict(kid), resources, depth+1)
+		}
+	}
+
+	for _, num := range f.objectNumbers() {
+		if dict, ok := f.objects[num].value.(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+			walk(f.dict(dict["Pages"]), nil, 0)
+			if len(pages) > 0 {
+				return pages
+			}
+		}
+	}
+
+	// Without a usable page tree, fall back to every page object
+	for _, num := range f.objectNumbers() {
+		if dict, ok := f.objects[num].value.(pdfDict); ok && dict["Type"] == pdfName("Page") {
+			pages = append(pages, dict)
+		}
+	}
+	return pages
+}
+
+// objectNumbers returns the object numbers in ascending order
+func (f *pdfFile) objectNumbers() []int {
+	nums := make([]int, 0, len(f.objects))
+	for num := range f.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// pageText appends the text shown by a page's content streams
+func (f *pdfFile) pageText(b *strings.Builder, page pdfDict) {
+	fonts := make(map[pdfName]*pdfFont)
+	if resources := f.dict(page["Resources"]); resources != nil {
+		for name, font := range f.dict(resources["Font"]) {
+			fonts[name] = f.loadFont(f.dict(font))
+		}
+	}
+
+	var content []byte
+	switch contents := page["Contents"].(type) {
+	case pdfRef:
+		if arr, ok := f.resolve(contents).(pdfArray); ok {
+			for _, part := range arr {
+				content = append(append(content, f.streamData(part)...), '\n')
+			}
+		} else {
+			content = f.streamData(contents)
+		}
+	case pdfArray:
+		for _, part := range contents {
+			content = append(append(content, f.streamData(part)...), '\n')
+		}
+	}
+
+	showText(b, content, fonts)
+}
+
+// showText interprets the text operators of a content stream
+func showText(b *strings.Builder, content []byte, fonts map[pdfName]*pdfFont) {
+	lx := &pdfLexer{data: content}
+	var operands []any
+	var font *pdfFont
+	lastY := 0.0
+
+	for {
+		v, err := lx.value()
+		if err != nil {
+			return
+		}
+		op, isOp := v.(pdfOp)
+		if !isOp {
+			operands = append(operands, v)
+			continue
+		}
+
+		switch op {
+		case "Tf":
+			if len(operands) >= 2 {
+				name, _ := operands[0].(pdfName)
+				font = fonts[name]
+			}
+		case "Tj":
+			if len(operands) >= 1 {
+				font.write(b, operands[len(operands)-1])
+			}
+		case "'", "\"":
+			b.WriteByte('\n')
+			if len(operands) >= 1 {
+				font.write(b, operands[len(operands)-1])
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				arr, _ := operands[len(operands)-1].(pdfArray)
+				for _, item := range arr {
+					if n, ok := item.(float64); ok {
+						// A large negative adjustment is how many generators space words
+						if n < -200 {
+							b.WriteByte(' ')
+						}
+						continue
+					}
+					font.write(b, item)
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, _ := operands[1].(float64); ty != 0 {
+					b.WriteByte('\n')
+				} else {
+					b.WriteByte(' ')
+				}
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				if y, _ := operands[5].(float64); y != lastY {
+					b.WriteByte('\n')
+					lastY = y
+				} else {
+					b.WriteByte(' ')
+				}
+			}
+		case "T*", "ET":
+			b.WriteByte('\n')
+		case "ID":
+			lx.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+}
+
+// pdfFont decodes the strings shown with one font
+type pdfFont struct {
+	codeLen int             // bytes per character code
+	cmap    map[int]string  // character code -> text, from /ToUnicode
+	simple  map[byte]string // overrides from /Differences
+}
+
+// loadFont reads the parts of a font dictionary that map codes to text
+func (f *pdfFile) loadFont(dict pdfDict) *pdfFont {
+	font := &pdfFont{codeLen: 1}
+	if dict == nil {
+		return font
+	}
+	if dict["Subtype"] == pdfName("Type0") {
+		font.codeLen = 2
+	}
+	if data := f.streamData(dict["ToUnicode"]); data != nil {
+		font.cmap, font.codeLen = parseCMap(data, font.codeLen)
+	}
+	if enc := f.dict(dict["Encoding"]); enc != nil {
+		if diffs, ok := f.resolve(enc["Differences"]).(pdfArray); ok {
+			font.simple = make(map[byte]string)
+			code := 0
+			for _, d := range diffs {
+				switch d := d.(type) {
+				case float64:
+					code = int(d)
+				case pdfName:
+					if text := glyphText(string(d)); text != "" && code < 256 {
+						font.simple[byte(code)] = text
+					}
+					code++
+				}
+			}
+		}
+	}
+	return font
+}
+
+// write appends the text of a shown string
+func (font *pdfFont) write(b *strings.Builder, v any) {
+	s, ok := v.(string)
+	if !ok {
+		return
+	}
+	if font == nil {
+		font = &pdfFont{codeLen: 1}
+	}
+
+	for i := 0; i+font.codeLen <= len(s); i += font.codeLen {
+		code := 0
+		for j := 0; j < font.codeLen; j++ {
+			code = code<<8 | int(s[i+j])
+		}
+		if text, ok := font.cmap[code]; ok {
+			b.WriteString(text)
+			continue
+		}
+		if font.codeLen != 1 {
+			continue
+		}
+		if text, ok := font.simple[byte(code)]; ok {
+			b.WriteString(text)
+			continue
+		}
+		b.WriteRune(winAnsi(byte(code)))
+	}
+}
+
+// parseCMap reads the bfchar and bfrange mappings of a ToUnicode CMap
+func parseCMap(data []byte, codeLen int) (map[int]string, int) {
+	cmap := make(map[int]string)
+	lx := &pdfLexer{data: data}
+	var operands []any
+
+	for {
+		v, err := lx.value()
+		if err != nil {
+			break
+		}
+		op, isOp := v.(pdfOp)
+		if !isOp {
+			operands = append(operands, v)
+			continue
+		}
+
+		switch op {
+		case "endcodespacerange":
+			if len(operands) >= 1 {
+				if lo, ok := operands[0].(string); ok && len(lo) > 0 {
+					codeLen = len(lo)
+				}
+			}
+		case "endbfchar":
+			for i := 0; i+1 < len(operands) && len(cmap) < maxCMapCodes; i += 2 {
+				src, _ := operands[i].(string)
+				dst, _ := operands[i+1].(string)
+				cmap[codeOf(src)] = utf16Text(dst)
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, _ := operands[i].(string)
+				hi, _ := operands[i+1].(string)
+				start, end := codeOf(lo), codeOf(hi)
+				if end-start > 0xffff || len(cmap)+end-start > maxCMapCodes {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case string:
+					base := []rune(utf16Text(dst))
+					if len(base) == 0 {
+						continue
+					}
+					for code := start; code <= end; code++ {
+						r := append([]rune(nil), base...)
+						r[len(r)-1] += rune(code - start)
+						cmap[code] = string(r)
+					}
+				case pdfArray:
+					for j, item := range dst {
+						if s, ok := item.(string); ok && start+j <= end {
+							cmap[start+j] = utf16Text(s)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return cmap, codeLen
+}
+
+// codeOf reads a big-endian character code
+func codeOf(s string) int {
+	code := 0
+	for i := 0; i < len(s); i++ {
+		code = code<<8 | int(s[i])
+	}
+	return code
+}
+
+// utf16Text decodes the UTF-16BE text of a CMap destination
+func utf16Text(s string) string {
+	units := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// glyphNames covers the glyph names of /Differences that are not a single
+// character
+var glyphNames = map[string]string{
+	"space": " ", "hyphen": "-", "period": ".", "comma": ",", "colon": ":",
+	"semicolon": ";", "quoteright": "'", "quoteleft": "'", "quotedblleft": "\"",
+	"quotedblright": "\"", "endash": "-", "emdash": "-", "bullet": "-",
+	"fi": "fi", "fl": "fl", "ff": "ff", "ffi": "ffi", "ffl": "ffl",
+	"zero": "0", "one": "1", "two": "2", "three": "3", "four": "4",
+	"five": "5", "six": "6", "seven": "7", "eight": "8", "nine": "9",
+	"parenleft": "(", "parenright": ")", "slash": "/", "at": "@",
+	"ampersand": "&", "percent": "%", "dollar": "$", "question": "?", "exclam": "!",
+}
+
+// glyphText maps a glyph name to its text
+func glyphText(name string) string {
+	if len(name) == 1 {
+		return name
+	}
+	if text, ok := glyphNames[name]; ok {
+		return text
+	}
+	if hexCode, ok := strings.CutPrefix(name, "uni"); ok && len(hexCode) == 4 {
+		if r, err := strconv.ParseUint(hexCode, 16, 32); err == nil {
+			return string(rune(r))
+		}
+	}
+	return ""
+}
+
+// winAnsi maps a byte of a simple font to a rune, reading the 0x80-0x9f
+// range as Windows-1252 and the rest as Latin-1
+func winAnsi(c byte) rune {
+	if c >= 0x80 && c <= 0x9f {
+		if r := cp1252[c-0x80]; r != 0 {
+			return r
+		}
+	}
+	return rune(c)
+}
+
+var cp1252 = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+// pdfLexer reads PDF values from object definitions and content streams
+type pdfLexer struct {
+	data  []byte
+	pos   int
+	depth int // nesting of the array or dictionary being read
+}
+
+var (
+	errPDFEnd     = errors.New("end of PDF data")
+	errPDFNesting = errors.New("PDF values nested too deeply")
+)
+
+// isPDFSpace reports whether c is PDF whitespace
+func isPDFSpace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+// isPDFDelimiter reports whether c ends a name, number or keyword
+func isPDFDelimiter(c byte) bool {
+	return isPDFSpace(c) || strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// skipSpace skips whitespace and comments
+func (lx *pdfLexer) skipSpace() {
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		if c == '%' {
+			for lx.pos < len(lx.data) && lx.data[lx.pos] != '\n' && lx.data[lx.pos] != '\r' {
+				lx.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		lx.pos++
+	}
+}
+
+// keyword consumes kw if it is the next token
+func (lx *pdfLexer) keyword(kw string) bool {
+	lx.skipSpace()
+	end := lx.pos + len(kw)
+	if end > len(lx.data) || string(lx.data[lx.pos:end]) != kw {
+		return false
+	}
+	if end < len(lx.data) && !isPDFDelimiter(lx.data[end]) {
+		return false
+	}
+	lx.pos = end
+	return true
+}
+
+// value reads the next value. Numbers followed by "G R" become references.
+func (lx *pdfLexer) value() (any, error) {
+	lx.skipSpace()
+	if lx.pos >= len(lx.data) {
+		return nil, errPDFEnd
+	}
+
+	c := lx.data[lx.pos]
+	switch {
+	case c == '/':
+		return lx.name(), nil
+	case c == '(':
+		return lx.literalString(), nil
+	case c == '<' && lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '<':
+		if lx.depth >= maxNesting {
+			return nil, errPDFNesting
+		}
+		return lx.dictionary()
+	case c == '<':
+		return lx.hexString(), nil
+	case c == '[':
+		if lx.depth >= maxNesting {
+			return nil, errPDFNesting
+		}
+		return lx.array()
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		lx.pos++
+		if c == '>' && lx.pos < len(lx.data) && lx.data[lx.pos] == '>' {
+			lx.pos++
+			return pdfOp(">>"), nil
+		}
+		return pdfOp(string(c)), nil
+	case c == '+' || c == '-' || c == '.' || c >= '0' && c <= '9':
+		return lx.number()
+	}
+
+	start := lx.pos
+	for lx.pos < len(lx.data) && !isPDFDelimiter(lx.data[lx.pos]) {
+		lx.pos++
+	}
+	if lx.pos == start {
+		lx.pos++
+	}
+	switch word := string(lx.data[start:lx.pos]); word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	default:
+		return pdfOp(word), nil
+	}
+}
+
+// valueOrNil reads the next value, or nil at the end of the data
+func valueOrNil(lx *pdfLexer) any {
+	v, _ := lx.value()
+	return v
+}
+
+// number reads a number, or a reference when followed by "G R"
+func (lx *pdfLexer) number() (any, error) {
+	start := lx.pos
+	lx.pos++
+	for lx.pos < len(lx.data) && !isPDFDelimiter(lx.data[lx.pos]) {
+		lx.pos++
+	}
+	n, err := strconv.ParseFloat(string(lx.data[start:lx.pos]), 64)
+	if err != nil {
+		return pdfOp(lx.data[start:lx.pos]), nil
+	}
+
+	// Look ahead for "gen R" without consuming anything on a mismatch
+	save := lx.pos
+	lx.skipSpace()
+	genStart := lx.pos
+	for lx.pos < len(lx.data) && lx.data[lx.pos] >= '0' && lx.data[lx.pos] <= '9' {
+		lx.pos++
+	}
+	if lx.pos > genStart && lx.pos < len(lx.data) && isPDFSpace(lx.data[lx.pos]) {
+		gen, _ := strconv.Atoi(string(lx.data[genStart:lx.pos]))
+		if lx.keyword("R") && n == float64(int(n)) {
+			return pdfRef{num: int(n), gen: gen}, nil
+		}
+	}
+	lx.pos = save
+	return n, nil
+}
+
+// name reads a name, decoding #xx escapes
+func (lx *pdfLexer) name() pdfName {
+	lx.pos++
+	var b []byte
+	for lx.pos < len(lx.data) && !isPDFDelimiter(lx.data[lx.pos]) {
+		c := lx.data[lx.pos]
+		if c == '#' && lx.pos+2 < len(lx.data) && isHexDigit(lx.data[lx.pos+1]) && isHexDigit(lx.data[lx.pos+2]) {
+			v, _ := strconv.ParseUint(string(lx.data[lx.pos+1:lx.pos+3]), 16, 8)
+			b = append(b, byte(v))
+			lx.pos += 3
+			continue
+		}
+		b = append(b, c)
+		lx.pos++
+	}
+	return pdfName(b)
+}
+
+// literalString reads a (string), handling nesting and escapes
+func (lx *pdfLexer) literalString() string {
+	lx.pos++
+	var b []byte
+	depth := 1
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		lx.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return string(b)
+			}
+		case '\\':
+			if lx.pos >= len(lx.data) {
+				return string(b)
+			}
+			e := lx.data[lx.pos]
+			lx.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if lx.pos < len(lx.data) && lx.data[lx.pos] == '\n' {
+					lx.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && lx.pos < len(lx.data) && lx.data[lx.pos] >= '0' && lx.data[lx.pos] <= '7'; i++ {
+						v = v*8 + int(lx.data[lx.pos]-'0')
+						lx.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+// hexString reads a <hex string>
+func (lx *pdfLexer) hexString() string {
+	lx.pos++
+	end := bytes.IndexByte(lx.data[lx.pos:], '>')
+	if end < 0 {
+		end = len(lx.data) - lx.pos
+	}
+	decoded, _ := hexDecode(lx.data[lx.pos : lx.pos+end])
+	lx.pos = min(lx.pos+end+1, len(lx.data))
+	return string(decoded)
+}
+
+// array reads an [array]
+func (lx *pdfLexer) array() (any, error) {
+	lx.pos++
+	lx.depth++
+	defer func() { lx.depth-- }()
+
+	var arr pdfArray
+	for {
+		v, err := lx.value()
+		if err == errPDFNesting {
+			return nil, err
+		}
+		if err != nil {
+			return arr, nil
+		}
+		if v == pdfOp("]") {
+			return arr, nil
+		}
+		arr = append(arr, v)
+	}
+}
+
+// dictionary reads a <<dictionary>>
+func (lx *pdfLexer) dictionary() (any, error) {
+	lx.pos += 2
+	lx.depth++
+	defer func() { lx.depth-- }()
+
+	dict := make(pdfDict)
+	for {
+		k, err := lx.value()
+		if err == errPDFNesting {
+			return nil, err
+		}
+		if err != nil || k == pdfOp(">>") {
+			return dict, nil
+		}
+		name, ok := k.(pdfName)
+		if !ok {
+			continue
+		}
+		v, err := lx.value()
+		if err == errPDFNesting {
+			return nil, err
+		}
+		if err != nil {
+			return dict, nil
+		}
+		if v == pdfOp(">>") {
+			return dict, nil
+		}
+		dict[name] = v
+	}
+}
+
+// skipInlineImage moves past the binary data of an inline image, which
+// runs from after the ID operator to the EI operator
+func (lx *pdfLexer) skipInlineImage() {
+	lx.pos++
+	for lx.pos+2 <= len(lx.data) {
+		if lx.data[lx.pos] == 'E' && lx.data[lx.pos+1] == 'I' &&
+			lx.pos > 0 && isPDFSpace(lx.data[lx.pos-1]) &&
+			(lx.pos+2 == len(lx.data) || isPDFSpace(lx.data[lx.pos+2])) {
+			lx.pos += 2
+			return
+		}
+		lx.pos++
+	}
+	lx.pos = len(lx.data)
+}
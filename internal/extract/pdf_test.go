@@ -0,0 +1,160 @@
+package extract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF assembles a one-page PDF around a content stream
+func buildPDF(content string) string {
+	return "%PDF-1.4\n" +
+		"1 0 obj <</Type/Catalog/Pages 2 0 R>> endobj\n" +
+		"2 0 obj <</Type/Pages/Kids[3 0 R]/Count 1>> endobj\n" +
+		"3 0 obj <</Type/Page/Parent 2 0 R/Contents 4 0 R>> endobj\n" +
+		fmt.Sprintf("4 0 obj <</Length %d>> stream\n%s\nendstream endobj\n", len(content), content) +
+		"trailer <</Root 1 0 R>>\n%%EOF\n"
+}
+
+func deflate(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestPDFText(t *testing.T) {
+	tests := []struct {
+		name string
+		pdf  string
+		want string
+	}{
+		{"show", buildPDF("BT (Hello world) Tj ET"), "Hello world"},
+		{"array", buildPDF("BT [(Hel) -10 (lo) -500 (there)] TJ ET"), "Hello there"},
+		{"escapes", buildPDF(`BT (a\(b\) \101) Tj ET`), "a(b) A"},
+		{"hex", buildPDF("BT <48690A> Tj ET"), "Hi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := Text("application/pdf", []byte(tt.pdf))
+			if err != nil {
+				t.Fatalf("Text: %v", err)
+			}
+			if !strings.Contains(text, tt.want) {
+				t.Errorf("text = %q, want it to contain %q", text, tt.want)
+			}
+		})
+	}
+}
+
+func TestPDFFlateStream(t *testing.T) {
+	stream := deflate(t, []byte("BT (Compressed text) Tj ET"))
+	pdf := "%PDF-1.5\n" +
+		"1 0 obj <</Type/Catalog/Pages 2 0 R>> endobj\n" +
+		"2 0 obj <</Type/Pages/Kids[3 0 R]/Count 1>> endobj\n" +
+		"3 0 obj <</Type/Page/Parent 2 0 R/Contents 4 0 R>> endobj\n" +
+		fmt.Sprintf("4 0 obj <</Length %d/Filter/FlateDecode>> stream\n", len(stream)) +
+		string(stream) + "\nendstream endobj\n"
+
+	text, err := PDF([]byte(pdf))
+	if err != nil {
+		t.Fatalf("PDF: %v", err)
+	}
+	if !strings.Contains(text, "Compressed text") {
+		t.Errorf("text = %q", text)
+	}
+}
+
+// TestPDFMalformed feeds broken and hostile files to the parser. Errors are
+// fine, but none may come from a recovered panic.
+func TestPDFMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		pdf  string
+	}{
+		{"empty", "%PDF"},
+		{"negative length", "%PDF-1.4\n1 0 obj <</Length -99999>> stream\nabc\nendstream endobj"},
+		{"negative length empty stream", "%PDF-1.4\n1 0 obj <</Length -1>> stream\nendstream endobj"},
+		{"huge length", "%PDF-1.4\n1 0 obj <</Length 1e300>> stream\nabc\nendstream endobj"},
+		{"length past end", "%PDF-1.4\n1 0 obj <</Length 50>> stream\nabc"},
+		{"no endstream", "%PDF-1.4\n1 0 obj <</Length 3>> stream\n"},
+		{"stream at end", "%PDF-1.4\n1 0 obj <<>> stream"},
+		{"negative first", "%PDF-1.5\n1 0 obj <</Type/ObjStm/N 1/First -5/Length 7>> stream\n2 0 <<>\nendstream endobj"},
+		{"first past end", "%PDF-1.5\n1 0 obj <</Type/ObjStm/N 1/First 500/Length 7>> stream\n2 0 <<>\nendstream endobj"},
+		{"negative offset", "%PDF-1.5\n1 0 obj <</Type/ObjStm/N 1/First 4/Length 12>> stream\n2 -9 <<>>  \nendstream endobj"},
+		{"offset past end", "%PDF-1.5\n1 0 obj <</Type/ObjStm/N 1/First 4/Length 12>> stream\n2 99 <<>>  \nendstream endobj"},
+		{"huge count", "%PDF-1.5\n1 0 obj <</Type/ObjStm/N 1e12/First 0/Length 1>> stream\nx\nendstream endobj"},
+		{"unterminated hex in trailer", "%PDF-1.4\n/Encrypt trailer <abc"},
+		{"unterminated string", buildPDF("BT (abc Tj ET")},
+		{"unterminated dict", "%PDF-1.4\n1 0 obj <</Type/Catalog"},
+		{"deep arrays", "%PDF-1.4\n1 0 obj " + strings.Repeat("[", 100000) + " endobj"},
+		{"deep dicts", "%PDF-1.4\n1 0 obj " + strings.Repeat("<</A ", 100000) + " endobj"},
+		{"deep content arrays", buildPDF("BT " + strings.Repeat("[", 100000) + " TJ ET")},
+		{"self-referencing page tree", "%PDF-1.4\n1 0 obj <</Type/Catalog/Pages 2 0 R>> endobj\n" +
+			"2 0 obj <</Type/Pages/Kids[2 0 R 2 0 R 2 0 R]>> endobj"},
+		{"reference cycle", "%PDF-1.4\n1 0 obj 2 0 R endobj\n2 0 obj 1 0 R endobj\n" +
+			"3 0 obj <</Type/Catalog/Pages 1 0 R>> endobj"},
+		{"bad filter", "%PDF-1.4\n1 0 obj <</Type/Page/Contents 2 0 R>> endobj\n" +
+			"2 0 obj <</Length 3/Filter/FlateDecode>> stream\nabc\nendstream endobj"},
+		{"wide cmap range", "%PDF-1.4\n1 0 obj <</Type/Page/Resources<</Font<</F1 2 0 R>>>>/Contents 4 0 R>> endobj\n" +
+			"2 0 obj <</Type/Font/ToUnicode 3 0 R>> endobj\n" +
+			"3 0 obj <</Length 70>> stream\n" + strings.Repeat("<0000> <ffff> <0041> ", 3) + "endbfrange\nendstream endobj\n" +
+			"4 0 obj <</Length 20>> stream\nBT /F1 1 Tf <00> Tj ET\nendstream endobj"},
+		{"inline image at end", buildPDF("BI /W 1 ID")},
+		{"binary garbage", "%PDF-1.4\n\x00\xff\x01 obj 0 0 obj \xfe<<\x00/\x80 [ ( < stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := PDF([]byte(tt.pdf)); err != nil && strings.HasPrefix(err.Error(), "malformed PDF") {
+				t.Fatalf("parser panicked: %v", err)
+			}
+		})
+	}
+}
+
+func TestInflateLimit(t *testing.T) {
+	bomb := deflate(t, make([]byte, 8<<20))
+	out, err := inflate(bomb, 1000)
+	if err != nil {
+		t.Fatalf("inflate: %v", err)
+	}
+	if len(out) != 1000 {
+		t.Errorf("inflated %d bytes, want the 1000 byte limit", len(out))
+	}
+}
+
+func TestPDFDecodedBudget(t *testing.T) {
+	// One stream expanding to maxStreamSize, shown by many pages, must not
+	// be decoded more than the per-file budget allows
+	stream := deflate(t, bytes.Repeat([]byte("BT (x) Tj ET\n"), maxStreamSize/13))
+	var b strings.Builder
+	b.WriteString("%PDF-1.5\n1 0 obj <</Type/Catalog/Pages 2 0 R>> endobj\n2 0 obj <</Type/Pages/Kids[")
+	for i := 0; i < 100; i++ {
+		b.WriteString("3 0 R ")
+	}
+	b.WriteString("]>> endobj\n3 0 obj <</Type/Page/Contents 4 0 R>> endobj\n")
+	fmt.Fprintf(&b, "4 0 obj <</Length %d/Filter/FlateDecode>> stream\n%s\nendstream endobj\n", len(stream), stream)
+
+	if _, err := PDF([]byte(b.String())); err != nil {
+		t.Fatalf("PDF: %v", err)
+	}
+}
+
+func FuzzPDF(f *testing.F) {
+	f.Add([]byte(buildPDF("BT (Hello) Tj ET")))
+	f.Add([]byte("%PDF-1.4\n1 0 obj <</Length -1>> stream\nendstream endobj"))
+	f.Add([]byte("%PDF-1.5\n1 0 obj <</Type/ObjStm/N 1/First -5/Length 7>> stream\n2 0 <<>\nendstream endobj"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if _, err := PDF(data); err != nil && strings.HasPrefix(err.Error(), "malformed PDF") {
+			t.Fatalf("parser panicked: %v", err)
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package securetmp
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// dirPrefix names the private directories so stale ones can be found again
+const dirPrefix = "arc-edit-"
+
+// Dir is a private directory holding decrypted files
+type Dir struct {
+	Path string
+}
+
+// Base returns the directory private temp dirs are created in and whether
+// it is memory-backed. /dev/shm and $XDG_RUNTIME_DIR are tmpfs on most Linux
+// systems, so plaintext never reaches a disk.
+func Base() (string, bool) {
+	candidates := []string{}
+	if runtime.GOOS == "linux" {
+		candidates = append(candidates, "/dev/shm")
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		candidates = append(candidates, dir)
+	}
+
+	for _, dir := range candidates {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() && writable(dir) {
+			return dir, true
+		}
+	}
+	return os.TempDir(), false
+}
+
+// New creates a private directory (mode 0700) under Base
+func New() (*Dir, error) {
+	base, _ := Base()
+	path, err := os.MkdirTemp(base, fmt.Sprintf("%s%d-", dirPrefix, os.Getpid()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create private directory: %w", err)
+	}
+	if err := os.Chmod(path, 0700); err != nil {
+		os.RemoveAll(path)
+		return nil, fmt.Errorf("failed to secure private directory: %w", err)
+	}
+	return &Dir{Path: path}, nil
+}
+
+// WriteFile writes data to a new file (mode 0600) inside the directory
+func (d *Dir) WriteFile(name string, data []byte) (string, error) {
+	path := filepath.Join(d.Path, filepath.Base(name))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
+
+// Shred overwrites every file in the directory, including swap and backup
+// files left by editors, and removes it
+func (d *Dir) Shred() error {
+	return shredDir(d.Path)
+}
+
+// CleanStale shreds private directories left behind by processes that no
+// longer run, e.g. after a crash or power loss
+func CleanStale() {
+	base, _ := Base()
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !strings.HasPrefix(name, dirPrefix) {
+			continue
+		}
+		pidStr, _, _ := strings.Cut(strings.TrimPrefix(name, dirPrefix), "-")
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil || alive(pid) {
+			continue
+		}
+		shredDir(filepath.Join(base, name))
+	}
+}
+
+// shredDir overwrites all regular files under dir and removes it
+func shredDir(dir string) error {
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			shredFile(path)
+		}
+		return nil
+	})
+	return os.RemoveAll(dir)
+}
+
+// shredFile overwrites a file with zeros before it is unlinked
+func shredFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	zeros := make([]byte, 32*1024)
+	for remaining := info.Size(); remaining > 0; {
+		n := int64(len(zeros))
+		if remaining < n {
+			n = remaining
+		}
+		if _, err := f.Write(zeros[:n]); err != nil {
+			return err
+		}
+		remaining -= n
+	}
+	return f.Sync()
+}
+
+// alive reports whether a process with the given pid is still running
+func alive(pid int) bool {
+	if pid == os.Getpid() {
+		return true
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// writable reports whether the current user can create files in dir
+func writable(dir string) bool {
+	f, err := os.CreateTemp(dir, ".arc-probe-")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return true
+}
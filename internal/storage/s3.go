@@ -0,0 +1,518 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// s3PartSize is the buffer used for each upload request; objects larger
+	// than this are sent as a multipart upload so they are never held in memory
+	s3PartSize = 8 << 20
+
+	// s3SmallSize is read first, so small objects never allocate a full part
+	s3SmallSize = 64 << 10
+
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// S3Config holds the settings for an S3-compatible object store
+type S3Config struct {
+	Endpoint        string // e.g. https://s3.amazonaws.com or http://localhost:9000
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// S3 stores objects in an S3-compatible bucket using path-style requests
+type S3 struct {
+	cfg    S3Config
+	client *http.Client
+}
+
+// NewS3 creates an S3 store from an explicit configuration
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3 bucket is required")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	cfg.Prefix = strings.Trim(cfg.Prefix, "/")
+
+	return &S3{cfg: cfg, client: newS3Client()}, nil
+}
+
+// newS3Client returns an HTTP client that gives up on an unreachable or
+// unresponsive endpoint. There is no overall deadline, since downloading a
+// large document may legitimately take long.
+func newS3Client() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: time.Minute,
+			ExpectContinueTimeout: time.Second,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConnsPerHost:   8,
+		},
+	}
+}
+
+// NewS3FromURL parses s3://bucket/prefix and reads credentials and endpoint
+// from the environment (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN, AWS_REGION and ARC_S3_ENDPOINT)
+func NewS3FromURL(location string) (*S3, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 location: %w", err)
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 location: %s (expected s3://bucket/prefix)", location)
+	}
+
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+
+	return NewS3(S3Config{
+		Endpoint:        os.Getenv("ARC_S3_ENDPOINT"),
+		Region:          region,
+		Bucket:          u.Host,
+		Prefix:          u.Path,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	})
+}
+
+// Get downloads an object as a stream
+func (s *S3) Get(key string) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	return s.get(key, "")
+}
+
+// GetRange downloads part of an object using a Range request
+func (s *S3) GetRange(key string, offset, length int64) (io.ReadCloser, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	return s.get(key, fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+}
+
+// get issues a GET for key, optionally limited to a byte range
+func (s *S3) get(key, byteRange string) (io.ReadCloser, error) {
+	var header http.Header
+	if byteRange != "" {
+		header = http.Header{"Range": {byteRange}}
+	}
+
+	resp, err := s.do(http.MethodGet, s.objectKey(key), nil, nil, 0, header)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Put uploads r. Small objects go up in a single request, larger ones are
+// streamed in parts.
+func (s *S3) Put(key string, r io.Reader) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	small := make([]byte, s3SmallSize)
+	n, err := io.ReadFull(r, small)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return s.putObject(s.objectKey(key), small[:n])
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only objects that outgrow the small buffer get a full part
+	first := make([]byte, s3PartSize)
+	copy(first, small)
+	rest, err := io.ReadFull(r, first[n:])
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	if n += rest; n < s3PartSize {
+		return s.putObject(s.objectKey(key), first[:n])
+	}
+
+	return s.putMultipart(s.objectKey(key), first, r)
+}
+
+// Delete removes an object
+func (s *S3) Delete(key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodDelete, s.objectKey(key), nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return checkResponse(resp)
+}
+
+// List pages through ListObjectsV2 for every key under prefix
+func (s *S3) List(prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", s.objectKey(prefix))
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(http.MethodGet, "", query, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkResponse(resp); err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse list response: %w", err)
+		}
+
+		for _, c := range result.Contents {
+			keys = append(keys, s.trimPrefix(c.Key))
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// putObject uploads a buffered object in one request
+func (s *S3) putObject(objectKey string, data []byte) error {
+	resp, err := s.do(http.MethodPut, objectKey, nil, bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// putMultipart streams r to objectKey in s3PartSize chunks, starting with
+// the already buffered first part. The buffer of the first part is reused
+// for the rest.
+func (s *S3) putMultipart(objectKey string, first []byte, r io.Reader) error {
+	query := url.Values{}
+	query.Set("uploads", "")
+	resp, err := s.do(http.MethodPost, objectKey, query, nil, 0)
+	if err != nil {
+		return err
+	}
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+
+	type completedPart struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	var parts []completedPart
+
+	abort := func(cause error) error {
+		q := url.Values{}
+		q.Set("uploadId", initiated.UploadID)
+		if resp, err := s.do(http.MethodDelete, objectKey, q, nil, 0); err == nil {
+			resp.Body.Close()
+		}
+		return cause
+	}
+
+	buf := first
+	for part := 1; ; part++ {
+		q := url.Values{}
+		q.Set("partNumber", strconv.Itoa(part))
+		q.Set("uploadId", initiated.UploadID)
+
+		resp, err := s.do(http.MethodPut, objectKey, q, bytes.NewReader(buf), int64(len(buf)))
+		if err != nil {
+			return abort(err)
+		}
+		resp.Body.Close()
+		if err := checkResponse(resp); err != nil {
+			return abort(err)
+		}
+		parts = append(parts, completedPart{PartNumber: part, ETag: resp.Header.Get("ETag")})
+
+		buf = first[:s3PartSize]
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return abort(err)
+		}
+		if n == 0 {
+			break
+		}
+		buf = buf[:n]
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return abort(err)
+	}
+
+	q := url.Values{}
+	q.Set("uploadId", initiated.UploadID)
+	resp, err = s.do(http.MethodPost, objectKey, q, bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return abort(err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return abort(err)
+	}
+	return nil
+}
+
+// objectKey prepends the configured prefix
+func (s *S3) objectKey(key string) string {
+	if s.cfg.Prefix == "" {
+		return key
+	}
+	return s.cfg.Prefix + "/" + key
+}
+
+// trimPrefix strips the configured prefix from a listed key
+func (s *S3) trimPrefix(objectKey string) string {
+	if s.cfg.Prefix == "" {
+		return objectKey
+	}
+	return strings.TrimPrefix(objectKey, s.cfg.Prefix+"/")
+}
+
+// do builds, signs and sends a request against the bucket
+func (s *S3) do(method, objectKey string, query url.Values, body io.Reader, size int64, header ...http.Header) (*http.Response, error) {
+	path := "/" + s.cfg.Bucket
+	if objectKey != "" {
+		path += "/" + objectKey
+	}
+
+	u, err := url.Parse(s.cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %w", err)
+	}
+	u.Path = path
+	u.RawPath = uriEncode(path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	for _, h := range header {
+		for name, values := range h {
+			req.Header[name] = values
+		}
+	}
+
+	s.sign(req, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// sign adds AWS Signature Version 4 headers to req
+func (s *S3) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+	if s.cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.cfg.SessionToken)
+	}
+
+	if s.cfg.AccessKeyID == "" {
+		return
+	}
+
+	headerNames := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		headerNames = append(headerNames, strings.ToLower(name))
+	}
+	sort.Strings(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.cfg.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, s.cfg.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes query parameters the way SigV4 expects
+func canonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything except unreserved characters
+// (and '/' unless encodeSlash is set)
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// checkResponse turns a non-2xx response into an error
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err := xml.Unmarshal(body, &s3Err); err == nil && s3Err.Code != "" {
+		return fmt.Errorf("s3 error %d %s: %s", resp.StatusCode, s3Err.Code, s3Err.Message)
+	}
+	return errors.New("s3 error: " + resp.Status)
+}
@@ -0,0 +1,343 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+// fakeS3 is a minimal S3 server that checks the SigV4 signature of every
+// request independently of the client code
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	puts    int
+	parts   int
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3) {
+	f := &fakeS3{t: t, objects: make(map[string][]byte), uploads: make(map[string]map[int][]byte)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	s, err := NewS3(S3Config{
+		Endpoint:        srv.URL,
+		Region:          "eu-west-1",
+		Bucket:          "bucket",
+		Prefix:          "/arcs/",
+		AccessKeyID:     testAccessKey,
+		SecretAccessKey: testSecretKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, s
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := verifySignature(r); err != nil {
+		f.t.Errorf("%s %s: %v", r.Method, r.URL, err)
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(w, "<Error><Code>SignatureDoesNotMatch</Code><Message>%s</Message></Error>", err)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/bucket/")
+	if !ok {
+		if r.URL.Path == "/bucket" && r.Method == http.MethodGet {
+			f.list(w, r.URL.Query().Get("prefix"))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	body, _ := io.ReadAll(r.Body)
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		id := strconv.Itoa(len(f.uploads) + 1)
+		f.uploads[id] = make(map[int][]byte)
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		n, _ := strconv.Atoi(query.Get("partNumber"))
+		f.uploads[query.Get("uploadId")][n] = body
+		f.parts++
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, n))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		var complete struct {
+			Parts []struct {
+				PartNumber int
+				ETag       string
+			} `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &complete); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var data []byte
+		for i, p := range complete.Parts {
+			if p.PartNumber != i+1 || p.ETag != fmt.Sprintf(`"etag-%d"`, i+1) {
+				f.t.Errorf("part %d listed as %d with ETag %s", i+1, p.PartNumber, p.ETag)
+			}
+			data = append(data, f.uploads[query.Get("uploadId")][p.PartNumber]...)
+		}
+		f.objects[key] = data
+		delete(f.uploads, query.Get("uploadId"))
+	case r.Method == http.MethodPut:
+		f.objects[key] = body
+		f.puts++
+	case r.Method == http.MethodGet:
+		data, exists := f.objects[key]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>missing</Message></Error>")
+			return
+		}
+		if rng := r.Header.Get("Range"); rng != "" {
+			var from, to int
+			fmt.Sscanf(rng, "bytes=%d-%d", &from, &to)
+			data = data[from : to+1]
+		}
+		w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix string) {
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	fmt.Fprint(w, "<ListBucketResult>")
+	for _, key := range keys {
+		fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", key)
+	}
+	fmt.Fprint(w, "<IsTruncated>false</IsTruncated></ListBucketResult>")
+}
+
+// verifySignature recomputes the SigV4 signature of a request as received
+func verifySignature(r *http.Request) error {
+	auth := r.Header.Get("Authorization")
+	rest, ok := strings.CutPrefix(auth, "AWS4-HMAC-SHA256 ")
+	if !ok {
+		return fmt.Errorf("unsigned request: %q", auth)
+	}
+	fields := make(map[string]string)
+	for _, part := range strings.Split(rest, ", ") {
+		name, value, _ := strings.Cut(part, "=")
+		fields[name] = value
+	}
+
+	credential := strings.SplitN(fields["Credential"], "/", 2)
+	if len(credential) != 2 || credential[0] != testAccessKey {
+		return fmt.Errorf("bad credential: %q", fields["Credential"])
+	}
+	scope := credential[1]
+	date, region, _ := strings.Cut(scope, "/")
+	region, _, _ = strings.Cut(region, "/")
+	if scope != date+"/eu-west-1/s3/aws4_request" {
+		return fmt.Errorf("bad scope: %q", scope)
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, date) {
+		return fmt.Errorf("X-Amz-Date %q does not match the scope date %s", amzDate, date)
+	}
+	payload := r.Header.Get("X-Amz-Content-Sha256")
+
+	var canonicalHeaders strings.Builder
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	for _, name := range []string{"host", "x-amz-date", "x-amz-content-sha256"} {
+		if !containsString(signed, name) {
+			return fmt.Errorf("%s is not signed", name)
+		}
+	}
+	for _, name := range signed {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	// The query must already be in canonical form: sorted and fully encoded
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		canonicalHeaders.String(),
+		fields["SignedHeaders"],
+		payload,
+	}, "\n")
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := []byte("AWS4" + testSecretKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		key = testHMAC(key, part)
+	}
+	if want := hex.EncodeToString(testHMAC(key, stringToSign)); fields["Signature"] != want {
+		return fmt.Errorf("signature %s, want %s", fields["Signature"], want)
+	}
+	return nil
+}
+
+func testHMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		size      int
+		wantParts int
+	}{
+		{"empty", "a/empty.bin", 0, 0},
+		{"small", "a/small.bin", 100, 0},
+		{"past the small buffer", "a/medium.bin", s3SmallSize + 1, 0},
+		{"exactly one part", "a/part.bin", s3PartSize, 1},
+		{"multipart", "a/large.bin", 2*s3PartSize + 123, 3},
+		{"escaped key", "a/name with spaces+(1).bin", 10, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, s := newFakeS3(t)
+			data := make([]byte, tt.size)
+			for i := range data {
+				data[i] = byte(i * 7)
+			}
+
+			if err := s.Put(tt.key, bytes.NewReader(data)); err != nil {
+				t.Fatalf("Put: %v", err)
+			}
+			if f.parts != tt.wantParts {
+				t.Errorf("uploaded %d parts, want %d", f.parts, tt.wantParts)
+			}
+			if _, stored := f.objects["arcs/"+tt.key]; !stored {
+				t.Fatalf("object not stored under the prefix")
+			}
+
+			got, err := ReadAll(s, tt.key)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("read back %d bytes that differ from the %d written", len(got), len(data))
+			}
+
+			if tt.size > 10 {
+				part, err := ReadRange(s, tt.key, 3, 5)
+				if err != nil {
+					t.Fatalf("ReadRange: %v", err)
+				}
+				if !bytes.Equal(part, data[3:8]) {
+					t.Errorf("range = %v, want %v", part, data[3:8])
+				}
+			}
+		})
+	}
+}
+
+func TestS3NotFound(t *testing.T) {
+	_, s := newFakeS3(t)
+
+	if _, err := s.Get("missing/key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.GetRange("missing/key", 0, 10); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetRange error = %v, want ErrNotFound", err)
+	}
+	if exists, err := Exists(s, "missing/key"); exists || err != nil {
+		t.Errorf("Exists = %v, %v", exists, err)
+	}
+	if err := s.Delete("missing/key"); err != nil {
+		t.Errorf("Delete of a missing key: %v", err)
+	}
+}
+
+func TestS3ListAndDelete(t *testing.T) {
+	f, s := newFakeS3(t)
+	for _, key := range []string{"x/2", "x/1", "y/1"} {
+		if err := s.Put(key, strings.NewReader(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.objects["other/x/3"] = nil // outside the prefix
+
+	keys, err := s.List("x/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(keys, ",") != "x/1,x/2" {
+		t.Errorf("List = %v", keys)
+	}
+
+	if err := RemoveAll(s, "x/"); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := s.List(""); strings.Join(keys, ",") != "y/1" {
+		t.Errorf("after RemoveAll, List = %v", keys)
+	}
+}
+
+func TestS3Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, "<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>")
+	}))
+	defer srv.Close()
+
+	s, err := NewS3(S3Config{Endpoint: srv.URL, Bucket: "bucket"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Put("k", strings.NewReader("v"))
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Put error = %v, want AccessDenied", err)
+	}
+	if _, err := s.Get("k"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Get error = %v, want a non-ErrNotFound error", err)
+	}
+}
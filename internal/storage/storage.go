@@ -0,0 +1,185 @@
+// Package storage defines the object stores arcs are kept in
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// Storage is a flat key/value object store. Keys use forward slashes
+// ("<arc-id>/documents/<doc-id>.bin") regardless of the backend.
+type Storage interface {
+	// Get opens an object for reading. The caller must close it.
+	Get(key string) (io.ReadCloser, error)
+	// Put stores the contents of r under key, replacing any existing object
+	Put(key string, r io.Reader) error
+	// Delete removes an object. Deleting a missing object is not an error.
+	Delete(key string) error
+	// List returns every key starting with prefix, in lexical order
+	List(prefix string) ([]string, error)
+}
+
+// prefixDeleter is implemented by backends that can drop a whole prefix at once
+type prefixDeleter interface {
+	DeletePrefix(prefix string) error
+}
+
+// appender is implemented by backends that can grow an object in place
+type appender interface {
+	Append(key string, data []byte) (int64, error)
+}
+
+// rangeReader is implemented by backends that can read part of an object
+type rangeReader interface {
+	GetRange(key string, offset, length int64) (io.ReadCloser, error)
+}
+
+// Open returns the storage for a location: either a local directory or an
+// s3://bucket/prefix URL
+func Open(location string) (Storage, error) {
+	if strings.HasPrefix(location, "s3://") {
+		return NewS3FromURL(location)
+	}
+	return NewLocal(location)
+}
+
+// IsRemote reports whether location refers to a non-local backend
+func IsRemote(location string) bool {
+	return strings.HasPrefix(location, "s3://")
+}
+
+// ReadAll reads a whole object into memory
+func ReadAll(s Storage, key string) ([]byte, error) {
+	rc, err := s.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
+
+// Exists reports whether an object exists
+func Exists(s Storage, key string) (bool, error) {
+	rc, err := s.Get(key)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	rc.Close()
+	return true, nil
+}
+
+// RemoveAll deletes every object under prefix
+func RemoveAll(s Storage, prefix string) error {
+	if pd, ok := s.(prefixDeleter); ok {
+		return pd.DeletePrefix(prefix)
+	}
+
+	keys, err := s.List(prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CanAppend reports whether a backend grows objects in place. On the others
+// Append downloads and rewrites the whole object, so callers appending
+// often should write new objects instead.
+func CanAppend(s Storage) bool {
+	_, ok := s.(appender)
+	return ok
+}
+
+// Append adds data to the end of an object, creating it if needed, and
+// returns the offset the data was written at. Backends without native
+// appends rewrite the whole object.
+func Append(s Storage, key string, data []byte) (int64, error) {
+	if a, ok := s.(appender); ok {
+		return a.Append(key, data)
+	}
+
+	existing, err := ReadAll(s, key)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return 0, err
+	}
+
+	offset := int64(len(existing))
+	combined := io.MultiReader(bytes.NewReader(existing), bytes.NewReader(data))
+	if err := s.Put(key, combined); err != nil {
+		return 0, err
+	}
+	return offset, nil
+}
+
+// ReadRange reads length bytes starting at offset from an object
+func ReadRange(s Storage, key string, offset, length int64) ([]byte, error) {
+	var rc io.ReadCloser
+	var err error
+
+	if rr, ok := s.(rangeReader); ok {
+		rc, err = rr.GetRange(key, offset, length)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		rc, err = s.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.CopyN(io.Discard, rc, offset); err != nil {
+			rc.Close()
+			return nil, fmt.Errorf("%s: range out of bounds: %w", key, err)
+		}
+	}
+	defer rc.Close()
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(rc, data); err != nil {
+		return nil, fmt.Errorf("%s: range out of bounds: %w", key, err)
+	}
+	return data, nil
+}
+
+// Copy streams one object from src to dst
+func Copy(dst Storage, dstKey string, src Storage, srcKey string) error {
+	rc, err := src.Get(srcKey)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return dst.Put(dstKey, rc)
+}
+
+// Join joins key segments with forward slashes
+func Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// validateKey rejects keys that could escape the store root
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return fmt.Errorf("invalid storage key: %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid storage key: %q", key)
+		}
+	}
+	return nil
+}
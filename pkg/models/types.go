@@ -1,7 +1,10 @@
 // Package models defines the types for arcadio
 package models
 
-import "time"
+import (
+	"path"
+	"time"
+)
 
 
 type Arc struct {
@@ -12,16 +15,94 @@ type Arc struct {
 	Documents         map[string]*Document   `json:"documents"`
 	Tags              map[string][]string    `json:"tags"` // doc_id -> tags
 	EncryptionVersion string                 `json:"encryption_version"`
+	Packs             *PackSet               `json:"packs,omitempty"` // nil for the loose layout
+	Journal           *Journal               `json:"journal,omitempty"` // set while a merge or split into this arc is unfinished
+	RetentionRules    []*RetentionRule       `json:"retention_rules,omitempty"`
+	Policy            *Policy                `json:"policy,omitempty"` // write-once rules, nil when unrestricted
+	Collections       []*Collection          `json:"collections,omitempty"`
 }
 
 type Document struct {
-	ID          string    `json:"id"`
-	Filename    string    `json:"filename"`
-	AddedAt     time.Time `json:"added_at"`
-	ModifiedAt  time.Time `json:"modified_at"`
-	Size        int64     `json:"size"`
-	ContentHash string    `json:"content_hash"` // SHA-256
-	Compressed  bool      `json:"compressed"`
+	ID            string               `json:"id"`
+	Filename      string               `json:"filename"`
+	Folder        string               `json:"folder,omitempty"` // virtual folder, "" for the root
+	AddedAt       time.Time            `json:"added_at"`
+	ModifiedAt    time.Time            `json:"modified_at"`
+	Size          int64                `json:"size"`
+	ContentHash   string               `json:"content_hash"` // SHA-256
+	Compressed    bool                 `json:"compressed"`
+	ContentType   string               `json:"content_type,omitempty"` // detected MIME type
+	Properties    map[string]*Property `json:"properties,omitempty"`
+	Origin        string               `json:"origin,omitempty"` // "<arc-id>/<doc-id>" when merged or split from another arc
+	ExpiresAt     *time.Time           `json:"expires_at,omitempty"`
+	LegalHold     bool                 `json:"legal_hold,omitempty"`      // blocks removal and changes while set
+	SourceModTime *time.Time           `json:"source_mod_time,omitempty"` // modification time of the local file at the last sync-dir
+}
+
+// Property types
+const (
+	PropertyString = "string"
+	PropertyNumber = "number"
+	PropertyDate   = "date"
+	PropertyBool   = "bool"
+)
+
+// Property is a typed custom value attached to a document
+type Property struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Path returns the virtual path of the document inside its arc
+func (d *Document) Path() string {
+	return path.Join(d.Folder, d.Filename)
+}
+
+// PackSet tracks the pack files of an arc using the packed layout, where
+// small encrypted documents are appended to shared pack files
+type PackSet struct {
+	Active string                `json:"active,omitempty"` // pack receiving new documents
+	Sizes  map[string]int64      `json:"sizes"`            // pack ID -> bytes written
+	Index  map[string]*PackEntry `json:"index"`            // doc_id -> location
+}
+
+// PackEntry locates one encrypted document inside a pack file
+type PackEntry struct {
+	Pack   string `json:"pack"`
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+}
+
+// RetentionRule expires documents carrying a tag a period after they were
+// added, e.g. tag "temp" after "30d"
+type RetentionRule struct {
+	Tag   string `json:"tag"`
+	After string `json:"after"`
+}
+
+// Collection is a named saved query, evaluated against the current
+// documents whenever it is used
+type Collection struct {
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Policy restricts what may be done to an arc. Tightening it is always
+// allowed; loosening it needs the admin passphrase.
+type Policy struct {
+	ImmutableUntil *time.Time `json:"immutable_until,omitempty"` // documents cannot be removed or changed before this
+	MaxSize        int64      `json:"max_size,omitempty"`        // total document bytes allowed, 0 for no limit
+	AdminSalt      []byte     `json:"admin_salt,omitempty"`
+	AdminHash      []byte     `json:"admin_hash,omitempty"` // Argon2id of the admin passphrase
+}
+
+// Journal records an unfinished merge or split so it can be resumed
+type Journal struct {
+	Op        string    `json:"op"`      // "merge" or "split"
+	Sources   []string  `json:"sources"` // source arc IDs
+	Phase     string    `json:"phase"`   // "copy", then "cleanup" for splits
+	StartedAt time.Time `json:"started_at"`
 }
 
 type SecurityConfig struct {